@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+)
+
+// useReleasePrompt lets lookups that match several releases ask which one to use, unless yes is set or
+// stdin or stdout isn't a terminal, so jobs fail with the list of matches instead of waiting for an answer.
+func useReleasePrompt(yes bool) {
+	if yes || !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		helm.SetReleaseChooser(nil)
+		return
+	}
+	helm.SetReleaseChooser(promptChoice)
+}
+
+// promptChoice lists choices on stderr and reads the number of the chosen one from stdin.
+func promptChoice(question string, choices []string) (int, error) {
+	fmt.Fprintln(os.Stderr, question+":")
+	for i, choice := range choices {
+		fmt.Fprintf(os.Stderr, "  %d) %s\n", i+1, choice)
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprintf(os.Stderr, "Select 1-%d: ", len(choices))
+		line, err := reader.ReadString('\n')
+		if n, convErr := strconv.Atoi(strings.TrimSpace(line)); convErr == nil && n >= 1 && n <= len(choices) {
+			return n - 1, nil
+		}
+		if err != nil {
+			return 0, errors.Wrap(err, "read choice")
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+type clusterConfig struct {
+	Name       string `yaml:"name"`
+	Kubeconfig string `yaml:"kubeconfig"`
+	Context    string `yaml:"context"`
+}
+
+type clustersFile struct {
+	Clusters []clusterConfig `yaml:"clusters"`
+}
+
+func parseClustersFile(data []byte) ([]clusterConfig, error) {
+	file := clustersFile{}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, errors.Wrap(err, "unmarshal clusters file")
+	}
+
+	names := map[string]bool{}
+	for i, cluster := range file.Clusters {
+		if cluster.Name == "" {
+			return nil, errors.Errorf("cluster %d has no name", i)
+		}
+		if cluster.Name != filepath.Base(cluster.Name) || cluster.Name == ".." {
+			return nil, errors.Errorf("cluster name %q can't be used as a directory name", cluster.Name)
+		}
+		if names[cluster.Name] {
+			return nil, errors.Errorf("duplicate cluster name %q", cluster.Name)
+		}
+		names[cluster.Name] = true
+	}
+
+	return file.Clusters, nil
+}
+
+// convertInClusters runs convert once per cluster in clustersFile with the output written to <dest>/<cluster>.
+// Failures are collected and summarized after all clusters have been attempted.
+func convertInClusters(client *helm.Client, clustersFile string, opts helm.ConvertOptions, convert func(client *helm.Client, opts helm.ConvertOptions) error) error {
+	data, err := ioutil.ReadFile(clustersFile)
+	if err != nil {
+		return errors.Wrap(err, "read clusters file")
+	}
+
+	clusters, err := parseClustersFile(data)
+	if err != nil {
+		return errors.Wrap(err, "parse clusters file")
+	}
+
+	results := make([]error, len(clusters))
+	for i, cluster := range clusters {
+		fmt.Fprintf(os.Stderr, "Converting in cluster %s\n", cluster.Name)
+
+		clusterOpts := opts
+		clusterOpts.DestDir = filepath.Join(opts.DestDir, cluster.Name)
+		if opts.ReportFile != "" {
+			clusterOpts.ReportFile = filepath.Join(clusterOpts.DestDir, filepath.Base(opts.ReportFile))
+		}
+
+		results[i] = convert(client.ForCluster(cluster.Kubeconfig, cluster.Context), clusterOpts)
+
+		if results[i] != nil {
+			fmt.Fprintf(os.Stderr, "Failed to convert in cluster %s: %v\n", cluster.Name, results[i])
+		}
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CLUSTER\tRESULT")
+	for i, cluster := range clusters {
+		result := "ok"
+		if results[i] != nil {
+			failed++
+			result = results[i].Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\n", cluster.Name, result)
+	}
+	if err := w.Flush(); err != nil {
+		return errors.Wrap(err, "write summary")
+	}
+
+	if failed > 0 {
+		return errors.Errorf("conversion failed in %d of %d clusters", failed, len(clusters))
+	}
+
+	return nil
+}
+
+func convertClusterRelease(ctx context.Context, client *helm.Client, args []string, namespace string, revisionFlag string, status helmrelease.Status, includePending bool, opts helm.ConvertOptions) error {
+	if len(args) == 0 {
+		return errors.New("release name is required")
+	}
+
+	ref := releaseRef{
+		Namespace: namespace,
+		Name:      args[0],
+	}
+	if revisionFlag != "" {
+		revision, err := resolveRevisionFlag(ctx, client, namespace, ref.Name, revisionFlag, status)
+		if err != nil {
+			return err
+		}
+		ref.Revision = revision
+	}
+
+	revision, err := resolveRevision(ctx, client, ref, status, includePending)
+	if err != nil {
+		return err
+	}
+
+	destDir, err := convertReleaseRef(ctx, client, ref, revision, opts)
+	if err != nil {
+		return err
+	}
+	printConverted(ref, revision, destDir, opts.DryRun)
+
+	return nil
+}
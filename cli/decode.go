@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// maxDecodeArgSize limits the release blob accepted as an argument. Larger releases
+// exceed what shells handle comfortably and should be read from a file instead.
+const maxDecodeArgSize = 1024 * 1024
+
+func DecodeCmd(client *helm.Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "decode [base64 release data]",
+		Short:        "Decode release data passed as an argument",
+		Long:         `Decode the base64 encoded "release" value of a Helm release secret and print a summary, or convert it to a chart with --convert`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+			ctx := cmd.Context()
+
+			data := strings.TrimSpace(args[0])
+			if len(data) > maxDecodeArgSize {
+				return errors.Errorf("release data is %d bytes, the limit for an argument is %d bytes", len(data), maxDecodeArgSize)
+			}
+
+			helmRelease, err := helm.DecodeRelease([]byte(data))
+			if err != nil {
+				return errors.Wrap(err, "malformed release data")
+			}
+
+			if !v.GetBool("convert") {
+				printReleaseSummary(helmRelease)
+				return nil
+			}
+
+			return convertDecodedRelease(ctx, client, v, helmRelease)
+		},
+	}
+
+	addConvertFlags(cmd.Flags())
+	addInstallCommandFlags(cmd.Flags())
+	cmd.Flags().Bool("resource-summary", false, "print the CPU and memory requests and limits the chart's workloads need, including replicas")
+	cmd.Flags().Bool("convert", false, "convert the decoded release to a chart instead of printing a summary")
+
+	return cmd
+}
+
+// convertDecodedRelease converts a release that was decoded without reading it from a cluster and prints the result.
+func convertDecodedRelease(ctx context.Context, client *helm.Client, v *viper.Viper, helmRelease *helmrelease.Release) error {
+	opts, err := convertOptionsFromFlags(v)
+	if err != nil {
+		return errors.Wrap(err, "parse convert options")
+	}
+
+	output, err := outputFromFlags(v)
+	if err != nil {
+		return errors.Wrap(err, "parse output")
+	}
+
+	commandTemplate, err := installCommandTemplateFromFlags(v)
+	if err != nil {
+		return err
+	}
+
+	if err := checkPublishFlags(v, opts); err != nil {
+		return err
+	}
+
+	result, err := client.ConvertRelease(ctx, helmRelease, opts)
+	if err != nil {
+		return errors.Wrap(err, "convert release")
+	}
+	if opts.DryRun {
+		if output != outputText {
+			return printOutput(output, convertOutput{ConversionResult: *result})
+		}
+		if !v.GetBool("quiet") {
+			printReleaseSummary(helmRelease)
+			fmt.Fprintln(os.Stderr, "Dry run, nothing has been written")
+		}
+		return nil
+	}
+
+	return finishConversion(v, result, helmRelease, opts, output, commandTemplate)
+}
+
+func printReleaseSummary(helmRelease *helmrelease.Release) {
+	fmt.Println("Release:", helmRelease.Name)
+	fmt.Println("Namespace:", helmRelease.Namespace)
+	fmt.Println("Revision:", helmRelease.Version)
+	if helmRelease.Info != nil {
+		fmt.Println("Status:", helmRelease.Info.Status)
+	}
+	if helmRelease.Chart != nil && helmRelease.Chart.Metadata != nil {
+		fmt.Println("Chart:", helmRelease.Chart.Metadata.Name)
+		fmt.Println("Chart version:", helmRelease.Chart.Metadata.Version)
+		fmt.Println("App version:", helmRelease.Chart.Metadata.AppVersion)
+		fmt.Println("Templates:", len(helmRelease.Chart.Templates))
+	}
+	fmt.Println("Values keys:", len(helmRelease.Config))
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func DiffCmd(client *helm.Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "diff [release]",
+		Short:        "Compare two revisions of a release",
+		Long:         `Print a unified diff of the values and the deployed manifest of two revisions of a release. By default the latest revision is compared to the one before it`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+			ctx := cmd.Context()
+			releaseName := args[0]
+			namespace, err := client.CurrentNamespace()
+			if err != nil {
+				return err
+			}
+
+			from, to, err := diffRevisions(ctx, client, namespace, releaseName, v.GetInt("from"), v.GetInt("to"))
+			if err != nil {
+				return err
+			}
+
+			diff, err := client.DiffRevisions(ctx, namespace, releaseName, from, to, v.GetString("only"))
+			if err != nil {
+				return errors.Wrap(err, "diff revisions")
+			}
+			if diff == "" {
+				fmt.Fprintf(os.Stderr, "Revisions %d and %d don't differ\n", from, to)
+				return nil
+			}
+
+			fmt.Print(diff)
+			return nil
+		},
+	}
+
+	cmd.Flags().Int("from", 0, "revision to compare from (defaults to the revision before --to)")
+	cmd.Flags().Int("to", 0, "revision to compare to (defaults to the latest revision)")
+	cmd.Flags().String("only", "", "compare only the values or the manifest")
+
+	return cmd
+}
+
+// diffRevisions fills in the default revisions: the latest revision for to, and the revision before to for from.
+func diffRevisions(ctx context.Context, client *helm.Client, namespace string, releaseName string, from int, to int) (int, int, error) {
+	if from != 0 && to != 0 {
+		return from, to, nil
+	}
+
+	revisions, err := client.ListReleaseRevisions(ctx, namespace, releaseName)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "list release revisions")
+	}
+	if len(revisions) == 0 {
+		return 0, 0, errors.Wrapf(helm.ErrReleaseNotFound, "no revisions of %s in namespace %s", releaseName, namespace)
+	}
+
+	if to == 0 {
+		to = revisions[len(revisions)-1]
+	}
+	if from == 0 {
+		// history can have gaps, use the newest revision older than to
+		for _, revision := range revisions {
+			if revision < to {
+				from = revision
+			}
+		}
+		if from == 0 {
+			return 0, 0, errors.Wrapf(helm.ErrRevisionNotFound, "no revision of %s before %d", releaseName, to)
+		}
+	}
+
+	return from, to, nil
+}
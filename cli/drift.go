@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+)
+
+func printClusterDrift(ctx context.Context, client *helm.Client, namespace string, releaseName string, revision int) error {
+	drifts, err := client.CompareWithCluster(ctx, namespace, releaseName, revision)
+	if err != nil {
+		return errors.Wrap(err, "compare with cluster")
+	}
+
+	for _, drift := range drifts {
+		fmt.Printf("%s: %s\n", drift.Resource, drift.Status)
+		for _, change := range drift.Changes {
+			switch change.Change {
+			case helm.ValueRemoved:
+				fmt.Printf("  - %s: %v (not set in cluster)\n", change.Path, change.Old)
+			case helm.ValueChanged:
+				fmt.Printf("  ~ %s: %v (manifest has %v)\n", change.Path, change.New, change.Old)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+)
+
+func checkExpectedValues(ctx context.Context, client *helm.Client, namespace string, releaseName string, revision int, ref string) error {
+	expected, err := client.LoadExpectedValues(ctx, namespace, ref)
+	if err != nil {
+		return errors.Wrap(err, "load expected values")
+	}
+
+	helmRelease, err := client.GetRelease(ctx, namespace, releaseName, revision)
+	if err != nil {
+		return errors.Wrap(err, "get release")
+	}
+
+	diffs := helm.DiffValues(expected, helmRelease.Config)
+	for _, diff := range diffs {
+		switch diff.Change {
+		case helm.ValueAdded:
+			fmt.Printf("+ %s: %v (not expected)\n", diff.Path, diff.New)
+		case helm.ValueRemoved:
+			fmt.Printf("- %s: %v (expected, not set)\n", diff.Path, diff.Old)
+		case helm.ValueChanged:
+			fmt.Printf("~ %s: %v (expected %v)\n", diff.Path, diff.New, diff.Old)
+		}
+	}
+
+	if len(diffs) > 0 {
+		fmt.Println("FAIL")
+		return errors.Errorf("release values differ from %s in %d places", ref, len(diffs))
+	}
+
+	fmt.Println("PASS")
+	return nil
+}
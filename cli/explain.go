@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+)
+
+// printRevisionSelection prints the --explain table to stderr, stdout is left to the conversion output.
+func printRevisionSelection(selection *helm.RevisionSelection) {
+	fmt.Fprintln(os.Stderr, "Revision selection:")
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tSTATUS\tCREATED\tDECISION")
+	for _, candidate := range selection.Candidates {
+		created := ""
+		if !candidate.Created.IsZero() {
+			created = candidate.Created.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", candidate.Revision, candidate.Status, created, candidate.Note)
+	}
+	w.Flush()
+
+	if selection.Status.IsPending() && selection.LastDeployedRevision != 0 {
+		fmt.Fprintf(os.Stderr, "Revision %d is %s, the last deployed revision is %d\n", selection.Revision, selection.Status, selection.LastDeployedRevision)
+	}
+	fmt.Fprintln(os.Stderr, "")
+}
@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+type releaseRef struct {
+	Namespace string
+	Name      string
+	Revision  int
+}
+
+func (r releaseRef) String() string {
+	s := r.Namespace + "/" + r.Name
+	if r.Revision != 0 {
+		s += "@" + strconv.Itoa(r.Revision)
+	}
+	return s
+}
+
+// parseReleaseList parses either a YAML list or plain lines of namespace/release[@revision] entries.
+// Blank lines and lines starting with # are ignored.
+func parseReleaseList(data []byte, defaultNamespace string) ([]releaseRef, error) {
+	entries := []string{}
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		entries = []string{}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			entries = append(entries, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, errors.Wrap(err, "read lines")
+		}
+	}
+
+	refs := []releaseRef{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		ref, err := parseReleaseRef(entry, defaultNamespace)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse entry %q", entry)
+		}
+		refs = append(refs, ref)
+	}
+
+	return refs, nil
+}
+
+func parseReleaseRef(entry string, defaultNamespace string) (releaseRef, error) {
+	ref := releaseRef{
+		Namespace: defaultNamespace,
+	}
+
+	if i := strings.LastIndex(entry, "@"); i != -1 {
+		revision, err := strconv.Atoi(entry[i+1:])
+		if err != nil {
+			return releaseRef{}, errors.Wrap(err, "parse revision")
+		}
+		ref.Revision = revision
+		entry = entry[:i]
+	}
+
+	parts := strings.Split(entry, "/")
+	switch len(parts) {
+	case 1:
+		ref.Name = parts[0]
+	case 2:
+		ref.Namespace = parts[0]
+		ref.Name = parts[1]
+	default:
+		return releaseRef{}, errors.New("expected namespace/release[@revision]")
+	}
+
+	if ref.Name == "" {
+		return releaseRef{}, errors.New("release name is required")
+	}
+
+	return ref, nil
+}
+
+// convertReleaseList converts every release in listFile into its own <namespace>/<release> directory.
+// If updatedSince is set, releases last deployed before that window are skipped.
+// Failures are collected and reported after all entries have been attempted.
+func convertReleaseList(ctx context.Context, client *helm.Client, listFile string, defaultNamespace string, status helmrelease.Status, includePending bool, updatedSince time.Duration, opts helm.ConvertOptions) error {
+	data, err := ioutil.ReadFile(listFile)
+	if err != nil {
+		return errors.Wrap(err, "read release list")
+	}
+
+	refs, err := parseReleaseList(data, defaultNamespace)
+	if err != nil {
+		return errors.Wrap(err, "parse release list")
+	}
+
+	failed := 0
+	skipped := 0
+	progress := newProgress(len(refs), false)
+	for i, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(err, "convert releases")
+		}
+
+		progress.step(i+1, ref.String())
+
+		revision, err := resolveRevision(ctx, client, ref, status, includePending)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "Failed to convert %s: %v\n", ref, err)
+			continue
+		}
+
+		if updatedSince > 0 {
+			recent, err := deployedSince(ctx, client, ref, revision, time.Now().Add(-updatedSince))
+			if err != nil {
+				failed++
+				fmt.Fprintf(os.Stderr, "Failed to convert %s: %v\n", ref, err)
+				continue
+			}
+			if !recent {
+				skipped++
+				continue
+			}
+		}
+
+		destDir, err := convertReleaseRef(ctx, client, ref, revision, opts)
+		if err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "Failed to convert %s: %v\n", ref, err)
+			continue
+		}
+		printConverted(ref, revision, destDir, opts.DryRun)
+	}
+
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d of %d releases not deployed in the last %s\n", skipped, len(refs), updatedSince)
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d of %d conversions failed", failed, len(refs))
+	}
+
+	return nil
+}
+
+func resolveRevision(ctx context.Context, client *helm.Client, ref releaseRef, status helmrelease.Status, includePending bool) (int, error) {
+	return client.ResolveRevision(ctx, helm.ReleaseTarget{
+		Namespace:      ref.Namespace,
+		Name:           ref.Name,
+		Revision:       ref.Revision,
+		Status:         status,
+		IncludePending: includePending,
+	})
+}
+
+// deployedSince reports whether the revision was last deployed after since.
+func deployedSince(ctx context.Context, client *helm.Client, ref releaseRef, revision int, since time.Time) (bool, error) {
+	release, err := client.GetRelease(ctx, ref.Namespace, ref.Name, revision)
+	if err != nil {
+		return false, errors.Wrap(err, "get release")
+	}
+
+	if release.Info == nil {
+		return false, nil
+	}
+
+	return release.Info.LastDeployed.Time.After(since), nil
+}
+
+func convertReleaseRef(ctx context.Context, client *helm.Client, ref releaseRef, revision int, opts helm.ConvertOptions) (string, error) {
+	opts.DestDir = filepath.Join(opts.DestDir, ref.Namespace, ref.Name)
+	if !opts.DryRun {
+		if err := os.MkdirAll(opts.DestDir, 0755); err != nil {
+			return "", errors.Wrap(err, "create output dir")
+		}
+	}
+
+	if opts.ReportFile != "" {
+		opts.ReportFile = filepath.Join(opts.DestDir, filepath.Base(opts.ReportFile))
+	}
+
+	if _, err := client.ConvertReleaseVersion(ctx, ref.Namespace, ref.Name, revision, opts); err != nil {
+		return "", errors.Wrap(err, "convert release")
+	}
+
+	return opts.DestDir, nil
+}
+
+func printConverted(ref releaseRef, revision int, destDir string, dryRun bool) {
+	if dryRun {
+		fmt.Printf("Revision %d of %s can be converted\n", revision, ref)
+		return
+	}
+	fmt.Printf("Converted %s to %s\n", ref, destDir)
+}
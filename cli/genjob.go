@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func GenJobCmd(client *helm.Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gen-job [release] [-- release2chart flags]",
+		Short: "Print a Job manifest that converts a release in the cluster",
+		Long: `Print a ServiceAccount, Role, RoleBinding and Job that run release2chart in the release namespace with
+the permissions reading the release needs. Flags after -- are passed to release2chart in the Job. Nothing is sent to the cluster`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if cmd.ArgsLenAtDash() > 1 || (cmd.ArgsLenAtDash() == -1 && len(args) > 1) {
+				return errors.New("only one release name is accepted, pass release2chart flags after --")
+			}
+
+			namespace := v.GetString("namespace")
+			if namespace == "" {
+				namespace = "default"
+			}
+
+			manifest, err := helm.ConversionJobManifest(helm.JobOptions{
+				Namespace:   namespace,
+				ReleaseName: args[0],
+				Image:       v.GetString("image"),
+				ClaimName:   v.GetString("pvc"),
+				Args:        args[1:],
+				Storage:     client.Storage,
+			})
+			if err != nil {
+				return errors.Wrap(err, "generate job manifest")
+			}
+
+			if v.GetString("pvc") == "" {
+				fmt.Fprintf(os.Stderr, "Warning: without --pvc the chart is written to an emptyDir and lost when the pod ends\n")
+			}
+			fmt.Print(string(manifest))
+			return nil
+		},
+	}
+
+	cmd.Flags().String("image", "", "release2chart image the Job runs")
+	cmd.Flags().String("pvc", "", "PersistentVolumeClaim the chart is written to, mounted at "+helm.JobOutputDir)
+	cmd.MarkFlagRequired("image")
+
+	return cmd
+}
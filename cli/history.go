@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"context"
+	"io/ioutil"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+type valuesHistory struct {
+	Release   string                    `yaml:"release"`
+	Namespace string                    `yaml:"namespace"`
+	Revisions []helm.ValuesHistoryEntry `yaml:"revisions"`
+}
+
+func writeValuesHistory(ctx context.Context, client *helm.Client, namespace string, releaseName string, fileName string, parallelism int) error {
+	revisions, err := client.ValuesHistory(ctx, namespace, releaseName, parallelism)
+	if err != nil {
+		return errors.Wrap(err, "get values history")
+	}
+
+	data, err := yaml.Marshal(valuesHistory{
+		Release:   releaseName,
+		Namespace: namespace,
+		Revisions: revisions,
+	})
+	if err != nil {
+		return errors.Wrap(err, "marshal values history")
+	}
+
+	if err := ioutil.WriteFile(fileName, data, 0600); err != nil {
+		return errors.Wrap(err, "write values history")
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func InspectCmd(client *helm.Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "inspect [release]",
+		Short:        "Summarize a release revision without converting it",
+		Long:         `Print the chart, status, deploy times, number of templates and files, dependencies and top-level value keys of a release revision, by default the latest one`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+			ctx := cmd.Context()
+			releaseName := args[0]
+
+			output, err := outputFromFlags(v)
+			if err != nil {
+				return errors.Wrap(err, "parse output")
+			}
+
+			namespace, err := client.CurrentNamespace()
+			if err != nil {
+				return err
+			}
+
+			revision := 0
+			if v.GetString("revision") != "" {
+				revision, err = resolveRevisionFlag(ctx, client, namespace, releaseName, v.GetString("revision"), "")
+			} else {
+				revision, err = client.ResolveRevision(ctx, helm.ReleaseTarget{Namespace: namespace, Name: releaseName})
+			}
+			if err != nil {
+				return err
+			}
+
+			release, err := client.GetRelease(ctx, namespace, releaseName, revision)
+			if err != nil {
+				return errors.Wrap(err, "get release")
+			}
+
+			summary := helm.SummarizeRelease(release)
+			if output != outputText {
+				return printOutput(output, summary)
+			}
+			return printInspection(summary)
+		},
+	}
+
+	cmd.Flags().String("revision", "", "release revision to inspect, or a negative offset from the latest revision, e.g. -1 for the one before it")
+
+	return cmd
+}
+
+func printInspection(summary *helm.ReleaseSummary) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", summary.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", summary.Namespace)
+	fmt.Fprintf(w, "Revision:\t%d\n", summary.Revision)
+	fmt.Fprintf(w, "Status:\t%s\n", summary.Status)
+	fmt.Fprintf(w, "Chart:\t%s-%s\n", summary.Chart, summary.ChartVersion)
+	fmt.Fprintf(w, "App version:\t%s\n", summary.AppVersion)
+	fmt.Fprintf(w, "First deployed:\t%s\n", formatSummaryTime(summary.FirstDeployed))
+	fmt.Fprintf(w, "Last deployed:\t%s\n", formatSummaryTime(summary.LastDeployed))
+	fmt.Fprintf(w, "Description:\t%s\n", summary.Description)
+	fmt.Fprintf(w, "Templates:\t%d\n", summary.Templates)
+	fmt.Fprintf(w, "Files:\t%d\n", summary.Files)
+	fmt.Fprintf(w, "Dependencies:\t%s\n", strings.Join(summary.Dependencies, ", "))
+	fmt.Fprintf(w, "Values:\t%s\n", strings.Join(summary.ValueKeys, ", "))
+	fmt.Fprintf(w, "Chart default values:\t%s\n", strings.Join(summary.DefaultValueKeys, ", "))
+	return w.Flush()
+}
+
+func formatSummaryTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// installCommandData are the fields of an --install-command-template. Paths and names are not quoted,
+// the quote function quotes them for the shell.
+type installCommandData struct {
+	ReleaseName string
+	ChartFile   string
+	// ValuesFile is empty if the release has no values or they are passed with --set.
+	ValuesFile  string
+	Namespace   string
+	KubeContext string
+	// Command is the default install command, quoted for the shell.
+	Command string
+}
+
+// installCommandTemplateFromFlags parses --install-command-template. It returns nil if the flag is not set.
+func installCommandTemplateFromFlags(v *viper.Viper) (*template.Template, error) {
+	text := v.GetString("install-command-template")
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("install-command").Funcs(template.FuncMap{
+		"quote": func(arg string) string {
+			return shellJoin([]string{arg})
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse install command template")
+	}
+	// unknown fields only fail on execution, which would be after the chart is written
+	if err := tmpl.Execute(ioutil.Discard, installCommandData{}); err != nil {
+		return nil, errors.Wrap(err, "check install command template")
+	}
+	return tmpl, nil
+}
+
+// formatInstallCommand renders the install command with tmpl, or shell quotes command if tmpl is nil.
+func formatInstallCommand(tmpl *template.Template, command []string, data installCommandData) (string, error) {
+	data.Command = shellJoin(command)
+	if tmpl == nil {
+		return data.Command, nil
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", errors.Wrap(err, "render install command template")
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// printPlannedInstallCommand prints the install command for the files a conversion of helmRelease would write.
+func printPlannedInstallCommand(v *viper.Viper, helmRelease *helmrelease.Release, opts helm.ConvertOptions, output string, tmpl *template.Template) error {
+	result, err := helm.PlanConversion(helmRelease, opts)
+	if err != nil {
+		return errors.Wrap(err, "plan conversion")
+	}
+
+	data := installCommandData{
+		ReleaseName: installReleaseName(result, opts),
+		ChartFile:   result.ChartPath,
+		ValuesFile:  result.ValuesPath,
+		Namespace:   installNamespace(v, result.Namespace),
+		KubeContext: v.GetString("target-context"),
+	}
+	command := installCommand(data.ReleaseName, data.Namespace, data.ChartFile, data.ValuesFile, data.KubeContext)
+
+	if output != outputText {
+		return printOutput(output, convertOutput{ConversionResult: *result, InstallCommand: command})
+	}
+	formatted, err := formatInstallCommand(tmpl, command, data)
+	if err != nil {
+		return err
+	}
+	fmt.Println(formatted)
+	return nil
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func ListCmd(client *helm.Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "list",
+		Short:        "List releases",
+		Long:         `List the latest revision of every Helm release in --namespace, or in all namespaces if no namespace is set`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			output, err := outputFromFlags(v)
+			if err != nil {
+				return errors.Wrap(err, "parse output")
+			}
+
+			releases, err := client.ListReleases(cmd.Context(), v.GetString("namespace"))
+			if err != nil {
+				return errors.Wrap(err, "list releases")
+			}
+
+			if output != outputText {
+				return printOutput(output, releases)
+			}
+
+			return printReleases(releases)
+		},
+	}
+
+	return cmd
+}
+
+func printReleases(releases []helm.ReleaseInfo) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tREVISION\tSTATUS\tCHART\tUPDATED")
+	for _, release := range releases {
+		updated := ""
+		if !release.Updated.IsZero() {
+			updated = release.Updated.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s-%s\t%s\n", release.Namespace, release.Name, release.Revision, release.Status, release.Chart, release.ChartVersion, updated)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+func ConvertManyCmd(client *helm.Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "convert-many",
+		Short:        "Convert many releases of a namespace concurrently",
+		Long:         `Convert the latest revision of every release named in --file, one name per line, into its own <output-dir>/<release> directory`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			opts, err := convertOptionsFromFlags(v)
+			if err != nil {
+				return errors.Wrap(err, "parse convert options")
+			}
+
+			status, err := releaseStatusFromFlags(v)
+			if err != nil {
+				return errors.Wrap(err, "parse status")
+			}
+
+			output, err := outputFromFlags(v)
+			if err != nil {
+				return errors.Wrap(err, "parse output")
+			}
+
+			data, err := ioutil.ReadFile(v.GetString("file"))
+			if err != nil {
+				return errors.Wrap(err, "read release names")
+			}
+			names, err := parseReleaseNames(data)
+			if err != nil {
+				return errors.Wrap(err, "parse release names")
+			}
+
+			namespace, err := client.CurrentNamespace()
+			if err != nil {
+				return err
+			}
+
+			progress := newProgress(len(names), v.GetBool("quiet"))
+			opts.Progress = func(name string, current int, total int) {
+				progress.step(current, "release "+name)
+			}
+
+			results, errs := client.ConvertReleases(cmd.Context(), namespace, names, status, v.GetBool("include-pending"), v.GetInt("parallelism"), opts)
+			return printConvertedReleases(names, results, errs, output, v.GetBool("quiet"), opts.DryRun)
+		},
+	}
+
+	cmd.Flags().String("file", "", "file with the names of the releases to convert, one per line")
+	cmd.MarkFlagRequired("file")
+	cmd.Flags().Int("parallelism", 4, "number of releases converted concurrently")
+	cmd.Flags().String("status", string(helmrelease.StatusDeployed), "convert the latest revision with this status: deployed, failed, superseded, or any for the latest revision regardless of status")
+	cmd.Flags().Bool("include-pending", false, "also consider revisions with a pending status, to convert the chart and values of a stuck install, upgrade or rollback")
+	addConvertFlags(cmd.Flags())
+
+	return cmd
+}
+
+// parseReleaseNames returns the release names of data, one per line. Blank lines and lines starting with # are ignored.
+func parseReleaseNames(data []byte) ([]string, error) {
+	names := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		if strings.ContainsAny(name, "/@") {
+			return nil, errors.Errorf("%q is not a release name, use --from-list for namespace/release[@revision] entries", name)
+		}
+		names = append(names, name)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "read lines")
+	}
+	return names, nil
+}
+
+func printConvertedReleases(names []string, results []*helm.ConversionResult, errs []error, output string, quiet bool, dryRun bool) error {
+	converted := []convertOutput{}
+	failed := 0
+	for i, name := range names {
+		if errs[i] != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "Failed to convert %s: %v\n", name, errs[i])
+			continue
+		}
+
+		result := results[i]
+		switch {
+		case output != outputText:
+			converted = append(converted, convertOutput{ConversionResult: *result})
+		case dryRun && !quiet:
+			fmt.Printf("Revision %d of %s can be converted\n", result.Revision, name)
+		case quiet && !dryRun:
+			fmt.Println(result.ChartPath)
+		case !quiet:
+			fmt.Printf("Converted %s to %s\n", name, result.ChartPath)
+		}
+	}
+
+	if output != outputText {
+		if err := printOutput(output, converted); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return errors.Errorf("%d of %d conversions failed", failed, len(names))
+	}
+	return nil
+}
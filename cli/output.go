@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+// convertOutput is printed with --output json or yaml.
+type convertOutput struct {
+	helm.ConversionResult `yaml:",inline"`
+	InstallCommand        []string `json:"installCommand,omitempty" yaml:"installCommand,omitempty"`
+}
+
+// machineOutputConflicts are flags that print text of their own and can't be combined with json or yaml output
+// or --quiet.
+var machineOutputConflicts = []string{
+	"clusters-file", "from-list", "all-revisions", "expected-values", "compare-with-cluster", "diff-upstream",
+}
+
+func outputFromFlags(v *viper.Viper) (string, error) {
+	output := v.GetString("output")
+	switch output {
+	case outputText, outputJSON, outputYAML:
+	default:
+		return "", errors.Errorf("unsupported output %q, use %s, %s or %s", output, outputText, outputJSON, outputYAML)
+	}
+
+	if output != outputText {
+		if v.GetBool("quiet") {
+			return "", errors.Errorf("--output %s can't be combined with --quiet", output)
+		}
+		for _, flag := range machineOutputConflicts {
+			if v.IsSet(flag) {
+				return "", errors.Errorf("--output %s can't be combined with --%s", output, flag)
+			}
+		}
+	}
+
+	if v.GetBool("quiet") {
+		for _, flag := range machineOutputConflicts {
+			if v.IsSet(flag) {
+				return "", errors.Errorf("--quiet can't be combined with --%s", flag)
+			}
+		}
+	}
+
+	return output, nil
+}
+
+func printOutput(output string, value interface{}) error {
+	var data []byte
+	var err error
+	switch output {
+	case outputJSON:
+		data, err = json.MarshalIndent(value, "", "  ")
+	case outputYAML:
+		data, err = yaml.Marshal(value)
+	default:
+		return errors.Errorf("unsupported output %q", output)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "marshal %s output", output)
+	}
+
+	fmt.Println(string(bytes.TrimSuffix(data, []byte("\n"))))
+	return nil
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// progress prints which of several conversions is running to stderr. It prints nothing with --quiet or
+// if stderr isn't a terminal, so logs of batch jobs don't fill up with counters.
+type progress struct {
+	enabled bool
+	total   int
+}
+
+func newProgress(total int, quiet bool) *progress {
+	return &progress{
+		enabled: !quiet && term.IsTerminal(int(os.Stderr.Fd())),
+		total:   total,
+	}
+}
+
+// step reports that conversion current of total, described by what, e.g. "revision 5", has started.
+func (p *progress) step(current int, what string) {
+	if p.enabled {
+		fmt.Fprintf(os.Stderr, "Converting %s (%d/%d)...\n", what, current, p.total)
+	}
+}
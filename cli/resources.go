@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// printResourceSummary prints the --resource-summary table to stderr, stdout is left to the conversion output.
+func printResourceSummary(manifest string) error {
+	workloads, err := helm.ResourceSummary(manifest)
+	if err != nil {
+		return errors.Wrap(err, "summarize resources")
+	}
+
+	requests, limits := corev1.ResourceList{}, corev1.ResourceList{}
+	nodeRequests, nodeLimits := corev1.ResourceList{}, corev1.ResourceList{}
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "WORKLOAD\tPODS\tCPU REQUESTS\tCPU LIMITS\tMEMORY REQUESTS\tMEMORY LIMITS")
+	for _, workload := range workloads {
+		pods := fmt.Sprint(workload.Pods)
+		if workload.PerNode {
+			pods += " per node"
+			addResources(nodeRequests, workload.Requests)
+			addResources(nodeLimits, workload.Limits)
+		} else {
+			addResources(requests, workload.Requests)
+			addResources(limits, workload.Limits)
+		}
+		fmt.Fprintf(w, "%s/%s\t%s\t%s\n", workload.Kind, workload.Name, pods, resourceColumns(workload.Requests, workload.Limits))
+	}
+	fmt.Fprintf(w, "TOTAL\t\t%s\n", resourceColumns(requests, limits))
+	if len(nodeRequests) > 0 || len(nodeLimits) > 0 {
+		fmt.Fprintf(w, "PER NODE\t\t%s\n", resourceColumns(nodeRequests, nodeLimits))
+	}
+
+	return w.Flush()
+}
+
+func resourceColumns(requests corev1.ResourceList, limits corev1.ResourceList) string {
+	return fmt.Sprintf("%s\t%s\t%s\t%s",
+		quantityString(requests, corev1.ResourceCPU), quantityString(limits, corev1.ResourceCPU),
+		quantityString(requests, corev1.ResourceMemory), quantityString(limits, corev1.ResourceMemory))
+}
+
+func quantityString(resources corev1.ResourceList, name corev1.ResourceName) string {
+	quantity, ok := resources[name]
+	if !ok {
+		return "-"
+	}
+	return quantity.String()
+}
+
+func addResources(total corev1.ResourceList, resources corev1.ResourceList) {
+	for name, quantity := range resources {
+		current := total[name]
+		current.Add(quantity)
+		total[name] = current
+	}
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// resolveRevisionFlag returns the revision --revision selects. A negative value counts back from the latest
+// revision with status, so -1 is the revision before it.
+func resolveRevisionFlag(ctx context.Context, client *helm.Client, namespace string, releaseName string, flag string, status helmrelease.Status) (int, error) {
+	revision, err := strconv.Atoi(flag)
+	if err != nil {
+		return 0, errors.Wrap(err, "parse revision")
+	}
+	if revision == 0 {
+		return 0, errors.New("revision must be a revision number or a negative offset from the latest revision")
+	}
+
+	return client.ResolveRevision(ctx, helm.ReleaseTarget{
+		Namespace: namespace,
+		Name:      releaseName,
+		Revision:  revision,
+		Status:    status,
+	})
+}
+
+// convertAllRevisions converts every revision of the release to <release>-v<revision>.tgz and values-v<revision>.yaml.
+// Failures are collected and reported after all revisions have been attempted.
+func convertAllRevisions(ctx context.Context, client *helm.Client, namespace string, releaseName string, opts helm.ConvertOptions) error {
+	revisions, err := client.ListReleaseRevisions(ctx, namespace, releaseName)
+	if err != nil {
+		return errors.Wrap(err, "list release revisions")
+	}
+	if len(revisions) == 0 {
+		return errors.Wrapf(helm.ErrReleaseNotFound, "no revisions of %s in namespace %s", releaseName, namespace)
+	}
+
+	converted := []string{}
+	failed := []string{}
+	progress := newProgress(len(revisions), false)
+	for i, revision := range revisions {
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(err, "convert revisions")
+		}
+
+		progress.step(i+1, fmt.Sprintf("revision %d", revision))
+
+		if err := convertRevision(ctx, client, namespace, releaseName, revision, opts); err != nil {
+			failed = append(failed, fmt.Sprint(revision))
+			fmt.Fprintf(os.Stderr, "Failed to convert revision %d: %v\n", revision, err)
+			continue
+		}
+		converted = append(converted, fmt.Sprint(revision))
+	}
+
+	if len(converted) > 0 && opts.DryRun {
+		fmt.Printf("Revisions %s can be converted\n", strings.Join(converted, ", "))
+	} else if len(converted) > 0 {
+		fmt.Printf("Converted revisions %s\n", strings.Join(converted, ", "))
+	}
+	if len(failed) > 0 {
+		return errors.Errorf("failed to convert revisions %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+func convertRevision(ctx context.Context, client *helm.Client, namespace string, releaseName string, revision int, opts helm.ConvertOptions) error {
+	if opts.ReportFile != "" {
+		ext := filepath.Ext(opts.ReportFile)
+		opts.ReportFile = fmt.Sprintf("%s-v%d%s", strings.TrimSuffix(opts.ReportFile, ext), revision, ext)
+	}
+
+	revisionChartFile := filepath.Join(opts.DestDir, fmt.Sprintf("%s-v%d.tgz", releaseName, revision))
+	revisionValuesFile := filepath.Join(opts.DestDir, fmt.Sprintf("values-v%d.yaml", revision))
+	revisionNotesFile := filepath.Join(opts.DestDir, fmt.Sprintf("NOTES-v%d.rendered.txt", revision))
+	if !opts.Overwrite && !opts.DryRun {
+		for _, file := range []string{revisionChartFile, revisionValuesFile, revisionChartFile + ".prov", revisionNotesFile} {
+			if _, err := os.Stat(file); err == nil {
+				return errors.Errorf("%s already exists, use --overwrite to replace it", file)
+			}
+		}
+	}
+
+	result, err := client.ConvertReleaseVersion(ctx, namespace, releaseName, revision, opts)
+	if err != nil {
+		return errors.Wrap(err, "convert release")
+	}
+	if opts.DryRun {
+		return nil
+	}
+
+	if result.ChartPath != "" {
+		if err := os.Rename(result.ChartPath, revisionChartFile); err != nil {
+			return errors.Wrap(err, "rename chart file")
+		}
+	}
+	if result.ProvenancePath != "" {
+		if err := os.Rename(result.ProvenancePath, revisionChartFile+".prov"); err != nil {
+			return errors.Wrap(err, "rename provenance file")
+		}
+	}
+
+	if result.ValuesPath != "" {
+		if err := os.Rename(result.ValuesPath, revisionValuesFile); err != nil {
+			return errors.Wrap(err, "rename values file")
+		}
+	}
+
+	if result.NotesPath != "" {
+		if err := os.Rename(result.NotesPath, revisionNotesFile); err != nil {
+			return errors.Wrap(err, "rename notes file")
+		}
+	}
+
+	return nil
+}
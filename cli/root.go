@@ -1,88 +1,824 @@
 package cli
 
 import (
+	"compress/gzip"
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
+	"text/template"
 
+	"github.com/divolgin/release2chart/pkg/chartsign"
+	"github.com/divolgin/release2chart/pkg/gitpush"
 	"github.com/divolgin/release2chart/pkg/helm"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func InitAndExecute() {
-	if err := RootCmd().Execute(); err != nil {
-		os.Exit(1)
+	// cancel API calls in progress on Ctrl-C instead of waiting for them to finish
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := RootCmd().ExecuteContext(ctx); err != nil {
+		stop()
+		os.Exit(exitCode(err))
+	}
+}
+
+// Exit codes let scripts tell a missing release from a cluster that can't be used.
+const (
+	exitError        = 1
+	exitNotFound     = 2
+	exitClusterError = 3
+)
+
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, helm.ErrReleaseNotFound), errors.Is(err, helm.ErrRevisionNotFound):
+		return exitNotFound
+	case helm.IsClusterError(err):
+		return exitClusterError
 	}
+	return exitError
 }
 
 func RootCmd() *cobra.Command {
+	client := helm.NewClient()
 	cmd := &cobra.Command{
 		Use:          "release2chart [release]",
 		Short:        "Convert a Helm release to a Helm chart",
 		Long:         `Convert a Helm release to a Helm chart`,
+		Args:         cobra.ArbitraryArgs,
 		SilenceUsage: true,
 		PreRun: func(cmd *cobra.Command, args []string) {
 			viper.BindPFlags(cmd.Flags())
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			v := viper.GetViper()
+			ctx := cmd.Context()
+
+			opts, err := convertOptionsFromFlags(v)
+			if err != nil {
+				return errors.Wrap(err, "parse convert options")
+			}
+
+			status, err := releaseStatusFromFlags(v)
+			if err != nil {
+				return errors.Wrap(err, "parse status")
+			}
+			includePending := v.GetBool("include-pending")
+			if includePending && v.GetString("revision") != "" {
+				return errors.New("--include-pending can't be combined with --revision")
+			}
+
+			output, err := outputFromFlags(v)
+			if err != nil {
+				return errors.Wrap(err, "parse output")
+			}
+			quiet := v.GetBool("quiet")
+			useReleasePrompt(v.GetBool("yes"))
+
+			commandTemplate, err := installCommandTemplateFromFlags(v)
+			if err != nil {
+				return err
+			}
+
+			secretFile, fromStdin, secretName := v.GetString("from-secret-file"), v.GetBool("from-stdin"), v.GetString("secret-name")
+			if secretFile != "" || fromStdin || secretName != "" {
+				source := "--from-secret-file"
+				if fromStdin {
+					source = "--from-stdin"
+				} else if secretName != "" {
+					source = "--secret-name"
+				}
+				if len(args) > 0 {
+					return errors.Errorf("a release name can't be combined with %s", source)
+				}
+				for _, flag := range []string{"from-secret-file", "secret-name", "from-list", "clusters-file", "chart-name", "revision", "all-revisions", "all-namespaces", "flux",
+					"status", "include-pending", "explain", "show-install-only", "values-history", "expected-values", "compare-with-cluster", "diff-upstream"} {
+					if v.IsSet(flag) && "--"+flag != source {
+						return errors.Errorf("%s can't be combined with --%s", source, flag)
+					}
+				}
 
-			if len(args) == 0 {
+				var helmRelease *helmrelease.Release
+				if secretName != "" {
+					namespace, err := client.CurrentNamespace()
+					if err != nil {
+						return err
+					}
+					helmRelease, err = client.GetStoredRelease(ctx, namespace, secretName)
+					if err != nil {
+						return errors.Wrapf(err, "get release from %s", secretName)
+					}
+				} else if fromStdin {
+					if term.IsTerminal(int(os.Stdin.Fd())) {
+						return errors.New("--from-stdin reads the release data from a pipe, e.g. from kubectl get secret -o jsonpath='{.data.release}'")
+					}
+					helmRelease, err = client.ReadRelease(os.Stdin)
+					if err != nil {
+						return errors.Wrap(err, "read stdin")
+					}
+				} else {
+					helmRelease, err = client.ReadReleaseFile(secretFile)
+					if err != nil {
+						return errors.Wrapf(err, "read %s", secretFile)
+					}
+				}
+				return convertDecodedRelease(ctx, client, v, helmRelease)
+			}
+
+			if clustersFile := v.GetString("clusters-file"); clustersFile != "" {
+				return convertInClusters(client, clustersFile, opts, func(client *helm.Client, opts helm.ConvertOptions) error {
+					// every cluster's context can select a different namespace
+					namespace, err := client.CurrentNamespace()
+					if err != nil {
+						return err
+					}
+					if listFile := v.GetString("from-list"); listFile != "" {
+						return convertReleaseList(ctx, client, listFile, namespace, status, includePending, v.GetDuration("updated-since"), opts)
+					}
+					return convertClusterRelease(ctx, client, args, namespace, v.GetString("revision"), status, includePending, opts)
+				})
+			}
+
+			if listFile := v.GetString("from-list"); listFile != "" {
+				if opts.Rename != "" {
+					return errors.New("--rename can't be combined with --from-list")
+				}
+				namespace, err := client.CurrentNamespace()
+				if err != nil {
+					return err
+				}
+				return convertReleaseList(ctx, client, listFile, namespace, status, includePending, v.GetDuration("updated-since"), opts)
+			}
+
+			chartName := v.GetString("chart-name")
+			if len(args) == 0 && chartName == "" {
 				return errors.New("release name is required")
 			}
 
-			namespace := v.GetString("namespace")
-			releaseName := args[0]
+			namespace, err := client.CurrentNamespace()
+			if err != nil {
+				return err
+			}
+			releaseName := ""
 			revision := 0
 
+			if chartName != "" {
+				if len(args) > 0 {
+					return errors.New("a release name can't be combined with --chart-name")
+				}
+				if v.GetBool("flux") {
+					return errors.New("--chart-name can't be combined with --flux")
+				}
+				// without --namespace, every namespace is searched
+				searchNamespace := v.GetString("namespace")
+				if v.GetBool("all-namespaces") {
+					searchNamespace = ""
+				}
+				release, err := client.FindReleaseByChart(ctx, searchNamespace, chartName)
+				if err != nil {
+					return errors.Wrap(err, "find release by chart")
+				}
+				namespace, releaseName = release.Namespace, release.Name
+				fmt.Fprintf(os.Stderr, "Found release %s in namespace %s installed from chart %s\n", releaseName, namespace, chartName)
+			} else {
+				releaseName = args[0]
+				if v.GetBool("all-namespaces") {
+					if v.GetBool("flux") {
+						return errors.New("--all-namespaces can't be combined with --flux")
+					}
+					namespace, err = client.FindReleaseNamespace(ctx, releaseName)
+					if err != nil {
+						return errors.Wrap(err, "find release namespace")
+					}
+					fmt.Fprintf(os.Stderr, "Found release %s in namespace %s\n", releaseName, namespace)
+				}
+			}
+
+			if v.GetBool("flux") {
+				storageNamespace, storageName, err := client.ResolveFluxHelmRelease(ctx, namespace, releaseName)
+				if err != nil {
+					return errors.Wrap(err, "resolve flux HelmRelease")
+				}
+				fmt.Fprintf(os.Stderr, "HelmRelease %s/%s manages Helm release %s/%s\n", namespace, releaseName, storageNamespace, storageName)
+				namespace, releaseName = storageNamespace, storageName
+			}
+
+			if historyFile := v.GetString("values-history"); historyFile != "" {
+				return writeValuesHistory(ctx, client, namespace, releaseName, historyFile, v.GetInt("parallelism"))
+			}
+
+			if v.GetBool("all-revisions") {
+				if v.GetString("revision") != "" || v.IsSet("status") || includePending {
+					return errors.New("--all-revisions can't be combined with --revision, --status or --include-pending")
+				}
+				if opts.Bundle || opts.ChartDir {
+					return errors.Errorf("--all-revisions can't be combined with --format %s", outFormatName(opts))
+				}
+				return convertAllRevisions(ctx, client, namespace, releaseName, opts)
+			}
+
 			if v.GetString("revision") != "" {
-				r, err := strconv.Atoi(v.GetString("revision"))
+				r, err := resolveRevisionFlag(ctx, client, namespace, releaseName, v.GetString("revision"), status)
 				if err != nil {
-					return errors.Wrap(err, "parse revision")
+					return err
 				}
 				revision = r
+				if v.GetBool("explain") {
+					fmt.Fprintf(os.Stderr, "Revision %d was requested with --revision\n", revision)
+				}
+			} else if v.GetBool("explain") {
+				selection, err := client.SelectLatestRevision(ctx, namespace, releaseName, status, includePending)
+				if err != nil {
+					return errors.Wrap(err, "select latest revision")
+				}
+				printRevisionSelection(selection)
+				revision = selection.Revision
 			} else {
-				r, err := helm.FindLatestReleaseVersion(namespace, releaseName)
+				r, err := client.ResolveRevision(ctx, helm.ReleaseTarget{
+					Namespace:      namespace,
+					Name:           releaseName,
+					Status:         status,
+					IncludePending: includePending,
+				})
 				if err != nil {
-					return errors.Wrap(err, "find latest revision")
+					return err
 				}
 				revision = r
 			}
 
-			chartFile, valuesFile, err := helm.ConvertReleaseVersion(namespace, releaseName, revision)
-			if err != nil {
-				return errors.Wrap(err, "convert release")
+			if ref := v.GetString("expected-values"); ref != "" {
+				return checkExpectedValues(ctx, client, namespace, releaseName, revision, ref)
+			}
+
+			if v.GetBool("compare-with-cluster") {
+				return printClusterDrift(ctx, client, namespace, releaseName, revision)
 			}
 
-			command := []string{"helm", "install", releaseName, chartFile}
-			if valuesFile != "" {
-				command = append(command, "--values", valuesFile)
+			if repoURL := v.GetString("diff-upstream"); repoURL != "" {
+				return printUpstreamDiff(ctx, client, namespace, releaseName, revision, repoURL)
 			}
-			command = append(command, "--namespace", namespace)
 
-			fmt.Println("Chart has been saved to", chartFile)
-			fmt.Println("To install the chart, run the following command:")
-			fmt.Println("")
-			fmt.Println(strings.Join(command, " "))
-			fmt.Println("")
+			if err := checkPublishFlags(v, opts); err != nil {
+				return err
+			}
 
-			return nil
+			if v.GetBool("show-install-only") {
+				if opts.ValuesOnly || opts.DryRun || v.GetBool("as-set") {
+					return errors.New("--show-install-only can't be combined with --values-only, --dry-run or --as-set")
+				}
+				helmRelease, err := client.GetRelease(ctx, namespace, releaseName, revision)
+				if err != nil {
+					return errors.Wrap(err, "get release")
+				}
+				return printPlannedInstallCommand(v, helmRelease, opts, output, commandTemplate)
+			}
+
+			if opts.DryRun {
+				helmRelease, err := client.GetRelease(ctx, namespace, releaseName, revision)
+				if err != nil {
+					return errors.Wrap(err, "get release")
+				}
+				result, err := client.ConvertRelease(ctx, helmRelease, opts)
+				if err != nil {
+					return errors.Wrap(err, "check release")
+				}
+				if output != outputText {
+					return printOutput(output, convertOutput{ConversionResult: *result})
+				}
+				if !quiet {
+					printReleaseSummary(helmRelease)
+					fmt.Fprintln(os.Stderr, "Dry run, nothing has been written")
+				}
+				return nil
+			}
+
+			result, err := client.Convert(ctx, helm.ReleaseTarget{Namespace: namespace, Name: releaseName, Revision: revision}, opts)
+			if err != nil {
+				return errors.Wrap(err, "convert release")
+			}
+
+			var helmRelease *helmrelease.Release
+			if v.GetBool("resource-summary") {
+				helmRelease, err = client.GetRelease(ctx, namespace, releaseName, revision)
+				if err != nil {
+					return errors.Wrap(err, "get release")
+				}
+			}
+			return finishConversion(v, result, helmRelease, opts, output, commandTemplate)
 		},
 	}
 
-	cobra.OnInitialize(func() {
-		// viper.SetEnvPrefix("KOTS")
-		// viper.AutomaticEnv()
-	})
-	helm.AddFlags(cmd.PersistentFlags())
+	client.AddFlags(cmd.PersistentFlags())
+	cmd.PersistentFlags().String("output", outputText, "output format: text, json or yaml")
+	cmd.PersistentFlags().BoolP("quiet", "q", false, "only print the path of the written chart, or nothing with --dry-run. Messages and warnings still go to stderr")
+	cmd.Flags().Bool("show-install-only", false, "decode the release and print only the install command, without packaging or writing any files")
+
+	cmd.AddCommand(DecodeCmd(client))
+	cmd.AddCommand(UnbundleCmd())
+	cmd.AddCommand(ListCmd(client))
+	cmd.AddCommand(ConvertManyCmd(client))
+	cmd.AddCommand(DiffCmd(client))
+	cmd.AddCommand(InspectCmd(client))
+	cmd.AddCommand(GenJobCmd(client))
 
-	cmd.Flags().String("revision", "", "release revision to convert")
+	cmd.Flags().String("revision", "", "release revision to convert, or a negative offset from the latest revision, e.g. -1 for the one before it")
+	cmd.Flags().Bool("all-revisions", false, "convert every revision of the release to <release>-v<revision>.tgz and values-v<revision>.yaml")
+	addConvertFlags(cmd.Flags())
+	addInstallCommandFlags(cmd.Flags())
+	cmd.Flags().BoolP("yes", "y", false, "never prompt: fail listing the matches if the release is found in several namespaces, used by several releases with --chart-name or stored more than once with --strict, and don't ask before --cosign-sign uploads to the transparency log")
+	cmd.Flags().BoolP("all-namespaces", "A", false, "find the release in all namespaces, fails if more than one namespace has a release with this name")
+	cmd.Flags().String("chart-name", "", "instead of a release name, convert the release installed from this chart, fails if more than one release uses it")
+	cmd.Flags().Bool("flux", false, "the release argument is a Flux HelmRelease in --namespace, convert the Helm release it manages")
+	cmd.Flags().Bool("explain", false, "print which revisions were considered and why one was selected")
+	cmd.Flags().String("status", string(helmrelease.StatusDeployed), "convert the latest revision with this status: deployed, failed, superseded, or any for the latest revision regardless of status (ignored with --revision)")
+	cmd.Flags().Bool("include-pending", false, "also consider revisions with a pending status, to convert the chart and values of a stuck install, upgrade or rollback")
+	cmd.Flags().Bool("compare-with-cluster", false, "instead of converting, report how live objects differ from the deployed manifest")
+	cmd.Flags().String("diff-upstream", "", "instead of converting, list which files differ from the same chart version in this chart repository")
+	cmd.Flags().String("values-history", "", "instead of converting, write how values changed across all revisions to this YAML file")
+	cmd.Flags().Int("parallelism", 4, "number of revisions decoded concurrently by --values-history")
+	cmd.Flags().String("expected-values", "", "instead of converting, compare release values with configmap/<name>[:key] or secret/<name>[:key]")
+	cmd.Flags().Bool("resource-summary", false, "print the CPU and memory requests and limits the chart's workloads need, including replicas")
+	cmd.Flags().Bool("as-set", false, "use --set arguments instead of the values file in the suggested install command")
+	cmd.Flags().String("chartmuseum-url", "", "upload the converted chart to this ChartMuseum server")
+	cmd.Flags().String("chartmuseum-username", "", "ChartMuseum basic auth username")
+	cmd.Flags().String("chartmuseum-password", "", "ChartMuseum basic auth password")
+	cmd.Flags().Bool("chartmuseum-force", false, "overwrite the chart version if it already exists in ChartMuseum")
+	cmd.Flags().String("push", "", "push the converted chart to this OCI registry repository, e.g. oci://registry.example.com/charts")
+	cmd.Flags().String("registry-username", "", "OCI registry username, defaults to the credentials stored by helm registry login")
+	cmd.Flags().String("registry-password", "", "OCI registry password")
+	cmd.Flags().Bool("cosign-sign", false, "sign the chart pushed with --push with cosign, keyless with an OIDC identity unless --cosign-key is set")
+	cmd.Flags().String("cosign-key", "", "cosign private key file or KMS URI to sign with instead of keyless signing")
+	cmd.Flags().Bool("install-to-cache", false, "copy the converted chart to the Helm repository cache ($HELM_REPOSITORY_CACHE)")
+	cmd.Flags().String("git-push", "", "commit the unpacked chart to this git repository and push it")
+	cmd.Flags().String("git-branch", "", "git branch to push to (defaults to the repository default branch)")
+	cmd.Flags().String("git-path", "", "directory in the git repository to write the chart to (defaults to the release name)")
+	cmd.Flags().String("git-ssh-key", "", "private key file for ssh git repositories")
+	cmd.Flags().String("git-token", "", "access token for https git repositories")
+	cmd.Flags().String("from-list", "", "file with namespace/release[@revision] entries to convert")
+	cmd.Flags().String("from-secret-file", "", "convert the release in this Secret or ConfigMap manifest, or file with its base64 \"release\" value, without connecting to a cluster")
+	cmd.Flags().Bool("from-stdin", false, "like --from-secret-file, but read the release from stdin")
+	cmd.Flags().String("secret-name", "", "convert the release in the secret with this name in --namespace, ignoring its labels (a configmap with --storage configmap)")
+	cmd.Flags().String("clusters-file", "", "YAML file listing clusters (name, kubeconfig, context) to convert the release or --from-list in, each into its own directory")
+	cmd.Flags().Duration("updated-since", 0, "with --from-list, only convert releases deployed within this duration, e.g. 24h")
 
 	viper.BindPFlags(cmd.Flags())
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 
 	return cmd
 }
+
+// addInstallCommandFlags adds the flags that change the suggested install command.
+func addInstallCommandFlags(flags *pflag.FlagSet) {
+	flags.String("install-namespace", "", "namespace for the suggested install command, if the release targets a different namespace than the one its secrets are stored in")
+	flags.String("target-context", "", "kube context of the cluster the chart will be installed to, added to the suggested install command")
+	flags.String("install-command-template", "", "Go template of the suggested install command, with .ReleaseName, .ChartFile, .ValuesFile, .Namespace, .KubeContext, .Command (the default command) and a quote function")
+}
+
+// addConvertFlags adds the flags that control how a decoded release is written.
+func addConvertFlags(flags *pflag.FlagSet) {
+	flags.Bool("dry-run", false, "decode the release and print a summary without writing any files")
+	flags.StringP("output-dir", "o", "", "directory to write the chart and values file to, created if missing (defaults to the current directory)")
+	flags.Bool("normalize-values", false, "write values.yaml with sorted keys and consistent formatting for clean diffs")
+	flags.Bool("annotate-overrides", false, "mark the keys of the values file that were set at install or upgrade with a # user-override comment, needs --values-mode computed")
+	flags.String("values-mode", helm.ValuesModeUser, "values written to the values file: user (the values passed at install or upgrade, which with the chart reproduce the deployed state), computed (chart defaults merged with the user values, a snapshot that won't pick up new chart defaults) or none")
+	flags.Bool("sign", false, "write a provenance file signed with --key from --keyring next to the chart, like helm package --sign")
+	flags.String("key", "", "name of the key to sign with")
+	flags.String("keyring", defaultKeyring(), "secret keyring with the signing key")
+	flags.String("passphrase-file", "", "file with the passphrase of the signing key, - for stdin (prompted for if not set)")
+	flags.Bool("reproducible", false, "write byte-identical archives for the same release, with entry times from SOURCE_DATE_EPOCH or the Unix epoch")
+	flags.Int("compression-level", gzip.DefaultCompression, "gzip level of the chart archive, from 0 (no compression) to 9 (smallest), -1 for the default level")
+	flags.String("expect-digest", "", "fail if the digest of the chart archive is not this sha256:<hex> digest, implies --reproducible")
+	flags.Bool("lint", false, "fail if the converted chart has helm lint errors, print lint warnings")
+	flags.String("dump-release", "", "write the decoded release to this file as JSON, for debugging conversions")
+	flags.Bool("redact-values", false, "replace string values of the user supplied values with *** in the --dump-release file")
+	flags.String("output-permissions", "0600", "file mode of the extracted values file")
+	flags.String("file-mode", "0644", "file mode of the files in the chart")
+	flags.String("max-size", "", "fail before packaging if the chart files add up to more than this size, e.g. 50Mi, listing the largest files")
+	flags.StringSlice("include", nil, "only write chart files and templates whose path in the chart matches one of these globs, e.g. 'templates/*'. Chart.yaml and values.yaml are always written")
+	flags.StringSlice("exclude", nil, "don't write chart files and templates whose path in the chart matches one of these globs, e.g. 'files/*'")
+	flags.Bool("executable-scripts", false, "make *.sh files and files under scripts/ and bin/ dirs in the chart executable")
+	flags.String("report", "", "write a summary of the converted release to this file")
+	flags.String("report-format", helm.ReportFormatMarkdown, "format of the report file: md or txt")
+	flags.String("temp-dir", "", "directory for intermediate files (defaults to the OS temp dir)")
+	flags.String("keep-temp", helm.KeepTempNever, "keep the intermediate chart dir and print its path: never, on-failure or always")
+	flags.String("archive-root", "", "top-level directory name inside the chart archive (defaults to the chart name)")
+	flags.Bool("values-from-live", false, "copy fields changed in the live objects since the release was deployed, e.g. replicas, back into the values")
+	flags.StringSlice("live-fields", helm.DefaultLiveValueFields, "fields copied by --values-from-live, matched by the end of their path, e.g. replicas or containers.image")
+	flags.Bool("pin-images", false, "pin images in values and templates to the digests currently running in the cluster")
+	flags.Bool("render-check", false, "fail if the converted chart doesn't render to the deployed manifest")
+	flags.Bool("artifacthub", false, "add Artifact Hub annotations derived from the release to Chart.yaml")
+	flags.Bool("repo-index", false, "create or update a chart repository index.yaml in the output directory")
+	flags.Bool("strict-roundtrip", false, "fail if a dependency declared by the chart is not bundled in the converted chart")
+	flags.Bool("rebuild-deps", false, "download chart dependencies from their repositories instead of using the subcharts stored in the release")
+	flags.Bool("dependency-update", false, "update chart dependencies before packaging, replacing the subcharts stored in the release")
+	flags.String("format", outFormatChart, "output format: chart or tgz (chart archive and values file), dir (unpacked chart dir and values file) or release-bundle (single tar with chart, values and release metadata)")
+	flags.String("out-format", outFormatChart, "same as --format")
+	flags.Bool("stream-package", false, "write the chart archive directly from the release without unpacking it to a temp dir")
+	flags.Bool("canonical", false, "write the chart in a stable, diff-friendly form for git: sorted keys, LF line endings, templates ordered by name")
+	flags.String("build-metadata", "", "semver build metadata appended to the chart version, e.g. a CI run ID (1.2.3 becomes 1.2.3+<metadata>)")
+	flags.String("chart-version", "", "replace the version of the converted chart, e.g. 1.2.3-recovered, must be a valid SemVer")
+	flags.String("app-version", "", "replace the app version of the converted chart")
+	flags.String("metadata-patch", "", "YAML file of Chart.yaml fields, e.g. name, home or maintainers, merged onto the chart metadata")
+	flags.String("rename", "", "release name the chart will be installed under, replaces the old name in fullnameOverride and nameOverride values and the install command")
+	flags.Bool("migrate-apiversion", false, "upgrade a chart with the Helm 2 apiVersion v1 to v2, moving its dependencies from requirements.yaml to Chart.yaml")
+	flags.Bool("security-check", false, "warn about PodSecurityPolicy and other removed security APIs used by the deployed manifest")
+	flags.StringArray("unset", nil, "remove this dotted path, e.g. a.b[0].c, from the values file (can be repeated)")
+	flags.String("subchart", "", "convert only this subchart of the release as a standalone chart (downloads the chart dependencies)")
+	flags.String("split-manifest", "", "write each resource of the deployed manifest to <kind>-<name>.yaml in this directory")
+	flags.Bool("overwrite", false, "replace existing chart, values, provenance and bundle files instead of failing")
+	flags.Bool("always-write-values", false, "write values.yaml and add it to the install command even if the release has no values")
+	flags.Bool("values-only", false, "only write the values file, without unpacking or packaging the chart")
+	flags.String("dump-manifest", "", "write the deployed manifest of the release verbatim to this file, written with --output-permissions")
+	flags.String("list-images", "", "write the images deployed by the release manifest and hooks to this file, one per line, - for stdout")
+	flags.Bool("dump-notes", false, "write the rendered NOTES.txt of the release to NOTES.rendered.txt in the output dir, written with --output-permissions")
+	flags.String("hooks-dir", "", "write the rendered manifest of each release hook to <kind>-<name>.yaml in this directory")
+	flags.Bool("stdout", false, "write the chart archive to stdout instead of a file, e.g. to pipe it to an upload; messages go to stderr and the values file isn't written")
+	flags.Bool("no-values", false, "leave the chart's own values.yaml with its defaults out of the chart; the release values file next to the chart is controlled by --values-mode")
+	flags.Bool("no-schema", false, "leave values.schema.json out of the chart")
+	flags.Bool("strip-hooks", false, "remove templates annotated with helm.sh/hook from the chart, so reinstalling it doesn't run the hooks again")
+}
+
+func convertOptionsFromFlags(v *viper.Viper) (helm.ConvertOptions, error) {
+	valuesFileMode, err := strconv.ParseUint(v.GetString("output-permissions"), 8, 32)
+	if err != nil {
+		return helm.ConvertOptions{}, errors.Wrap(err, "parse output permissions")
+	}
+
+	fileMode, err := strconv.ParseUint(v.GetString("file-mode"), 8, 32)
+	if err != nil {
+		return helm.ConvertOptions{}, errors.Wrap(err, "parse file mode")
+	}
+
+	maxChartSize := int64(0)
+	if v.GetString("max-size") != "" {
+		quantity, err := resource.ParseQuantity(v.GetString("max-size"))
+		if err != nil {
+			return helm.ConvertOptions{}, errors.Wrap(err, "parse max size")
+		}
+		maxChartSize = quantity.Value()
+	}
+
+	var compressionLevel *int
+	if level := v.GetInt("compression-level"); level != gzip.DefaultCompression {
+		compressionLevel = &level
+	}
+
+	switch v.GetString("values-mode") {
+	case helm.ValuesModeUser, helm.ValuesModeComputed, helm.ValuesModeNone:
+	default:
+		return helm.ConvertOptions{}, errors.Errorf("unknown values mode %q", v.GetString("values-mode"))
+	}
+
+	outFormat, err := outFormatFromFlags(v)
+	if err != nil {
+		return helm.ConvertOptions{}, err
+	}
+
+	liveValueFields := []string{}
+	if v.GetBool("values-from-live") {
+		liveValueFields = v.GetStringSlice("live-fields")
+	}
+
+	opts := helm.ConvertOptions{
+		DestDir:           v.GetString("output-dir"),
+		ValuesFileMode:    os.FileMode(valuesFileMode),
+		ValuesMode:        v.GetString("values-mode"),
+		Sign:              v.GetBool("sign"),
+		SignKey:           v.GetString("key"),
+		Keyring:           v.GetString("keyring"),
+		PassphraseFile:    v.GetString("passphrase-file"),
+		Reproducible:      v.GetBool("reproducible"),
+		CompressionLevel:  compressionLevel,
+		ExpectDigest:      v.GetString("expect-digest"),
+		DryRun:            v.GetBool("dry-run"),
+		Lint:              v.GetBool("lint"),
+		DumpReleaseFile:   v.GetString("dump-release"),
+		RedactDumpValues:  v.GetBool("redact-values"),
+		FileMode:          os.FileMode(fileMode),
+		ExecutableScripts: v.GetBool("executable-scripts"),
+		ReportFile:        v.GetString("report"),
+		ReportFormat:      v.GetString("report-format"),
+		TempDir:           v.GetString("temp-dir"),
+		KeepTemp:          v.GetString("keep-temp"),
+		ArchiveRoot:       v.GetString("archive-root"),
+		PinImages:         v.GetBool("pin-images"),
+		RenderCheck:       v.GetBool("render-check"),
+		ArtifactHub:       v.GetBool("artifacthub"),
+		RepoIndex:         v.GetBool("repo-index"),
+		StrictRoundtrip:   v.GetBool("strict-roundtrip"),
+		SplitManifestDir:  v.GetString("split-manifest"),
+		Overwrite:         v.GetBool("overwrite"),
+		AlwaysWriteValues: v.GetBool("always-write-values"),
+		ValuesOnly:        v.GetBool("values-only"),
+		ManifestFile:      v.GetString("dump-manifest"),
+		ImageListFile:     v.GetString("list-images"),
+		DumpNotes:         v.GetBool("dump-notes"),
+		MaxChartSize:      maxChartSize,
+		IncludeFiles:      v.GetStringSlice("include"),
+		ExcludeFiles:      v.GetStringSlice("exclude"),
+		MetadataPatchFile: v.GetString("metadata-patch"),
+		HooksDir:          v.GetString("hooks-dir"),
+		StripHooks:        v.GetBool("strip-hooks"),
+		NoChartValues:     v.GetBool("no-values"),
+		NoSchema:          v.GetBool("no-schema"),
+		NormalizeValues:   v.GetBool("normalize-values"),
+		AnnotateOverrides: v.GetBool("annotate-overrides"),
+		RebuildDeps:       v.GetBool("rebuild-deps"),
+		DependencyUpdate:  v.GetBool("dependency-update"),
+		Bundle:            outFormat == outFormatBundle,
+		ChartDir:          outFormat == outFormatDir,
+		Subchart:          v.GetString("subchart"),
+		UnsetValues:       v.GetStringSlice("unset"),
+		SecurityCheck:     v.GetBool("security-check"),
+		BuildMetadata:     v.GetString("build-metadata"),
+		ChartVersion:      v.GetString("chart-version"),
+		AppVersion:        v.GetString("app-version"),
+		Rename:            v.GetString("rename"),
+		MigrateAPIVersion: v.GetBool("migrate-apiversion"),
+		Canonical:         v.GetBool("canonical"),
+		StreamPackage:     v.GetBool("stream-package"),
+		LiveValueFields:   liveValueFields,
+	}
+
+	if v.GetBool("stdout") {
+		if err := useStdout(v, &opts); err != nil {
+			return helm.ConvertOptions{}, err
+		}
+	}
+
+	return opts, nil
+}
+
+// finishConversion publishes the converted chart to the repositories and registries set by flags and prints
+// the result. helmRelease is only used by --resource-summary, which is skipped if it is nil.
+func finishConversion(v *viper.Viper, result *helm.ConversionResult, helmRelease *helmrelease.Release, opts helm.ConvertOptions, output string, commandTemplate *template.Template) error {
+	if v.GetBool("resource-summary") && helmRelease != nil {
+		if err := printResourceSummary(helmRelease.Manifest); err != nil {
+			return errors.Wrap(err, "print resource summary")
+		}
+		fmt.Fprintln(os.Stderr, "")
+	}
+
+	if v.GetBool("stdout") {
+		return writeChartToStdout(opts, result)
+	}
+	quiet := v.GetBool("quiet")
+	chartFile, valuesFile := result.ChartPath, result.ValuesPath
+
+	if opts.ValuesOnly {
+		return printValuesOnly(output, result)
+	}
+
+	if opts.Bundle {
+		if output != outputText {
+			return printOutput(output, convertOutput{ConversionResult: *result})
+		}
+		if quiet {
+			fmt.Println(chartFile)
+			return nil
+		}
+		printBundleSaved(chartFile)
+		return nil
+	}
+
+	commandData := installCommandData{
+		ReleaseName: installReleaseName(result, opts),
+		ChartFile:   chartFile,
+		ValuesFile:  valuesFile,
+		Namespace:   installNamespace(v, result.Namespace),
+		KubeContext: v.GetString("target-context"),
+	}
+	command := installCommand(commandData.ReleaseName, commandData.Namespace, chartFile, valuesFile, commandData.KubeContext)
+	if v.GetBool("as-set") && valuesFile != "" {
+		setArgs, err := setArgsFromValuesFile(valuesFile)
+		if err != nil {
+			return errors.Wrap(err, "convert values to --set arguments")
+		}
+		command = append(installCommand(commandData.ReleaseName, commandData.Namespace, chartFile, "", commandData.KubeContext), setArgs...)
+		commandData.ValuesFile = ""
+	}
+
+	if chartMuseumURL := v.GetString("chartmuseum-url"); chartMuseumURL != "" {
+		response, err := helm.UploadToChartMuseum(chartFile, helm.ChartMuseumOptions{
+			URL:      chartMuseumURL,
+			Username: v.GetString("chartmuseum-username"),
+			Password: v.GetString("chartmuseum-password"),
+			Force:    v.GetBool("chartmuseum-force"),
+		})
+		if err != nil {
+			return errors.Wrap(err, "upload to chartmuseum")
+		}
+		fmt.Fprintln(os.Stderr, "Chart has been uploaded to", chartMuseumURL, response)
+	}
+
+	if registryURL := v.GetString("push"); registryURL != "" {
+		registryOpts := helm.RegistryOptions{
+			URL:      registryURL,
+			Username: v.GetString("registry-username"),
+			Password: v.GetString("registry-password"),
+		}
+		pushed, err := helm.PushToRegistry(chartFile, registryOpts)
+		if err != nil {
+			return errors.Wrap(err, "push to registry")
+		}
+		fmt.Fprintln(os.Stderr, "Chart has been pushed to", pushed.Ref)
+		fmt.Fprintln(os.Stderr, "Digest:", pushed.Digest)
+
+		if v.GetBool("cosign-sign") {
+			signature, err := chartsign.Sign(pushed.Ref, pushed.Digest, chartsign.Options{
+				Key:              v.GetString("cosign-key"),
+				SkipConfirmation: v.GetBool("yes"),
+				Username:         registryOpts.Username,
+				Password:         registryOpts.Password,
+			})
+			if err != nil {
+				return errors.Wrap(err, "sign with cosign")
+			}
+			fmt.Fprintln(os.Stderr, "Signature has been pushed to", signature)
+		}
+	}
+
+	if v.GetBool("install-to-cache") {
+		cachedFile, err := helm.InstallToCache(chartFile)
+		if err != nil {
+			return errors.Wrap(err, "install to cache")
+		}
+		fmt.Fprintln(os.Stderr, "Chart has been copied to", cachedFile)
+	}
+
+	if repoURL := v.GetString("git-push"); repoURL != "" {
+		gitPath := v.GetString("git-path")
+		if gitPath == "" {
+			gitPath = result.ReleaseName
+		}
+
+		err := gitpush.Push(chartFile, valuesFile, gitpush.Options{
+			RepoURL:        repoURL,
+			Branch:         v.GetString("git-branch"),
+			Path:           gitPath,
+			SSHKeyFile:     v.GetString("git-ssh-key"),
+			Token:          v.GetString("git-token"),
+			Message:        fmt.Sprintf("Convert release %s/%s revision %d", result.Namespace, result.ReleaseName, result.Revision),
+			ValuesFileMode: opts.ValuesFileMode,
+		})
+		if err != nil {
+			return errors.Wrap(err, "push to git")
+		}
+		fmt.Fprintln(os.Stderr, "Chart has been pushed to", repoURL)
+	}
+
+	if output != outputText {
+		return printOutput(output, convertOutput{ConversionResult: *result, InstallCommand: command})
+	}
+	if quiet {
+		fmt.Println(chartFile)
+		return nil
+	}
+
+	formattedCommand, err := formatInstallCommand(commandTemplate, command, commandData)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Chart has been saved to", chartFile)
+	printChartDigest(result)
+	fmt.Println("To install the chart, run the following command:")
+	fmt.Println("")
+	fmt.Println(formattedCommand)
+	fmt.Println("")
+
+	return nil
+}
+
+// outFormatFromFlags returns the output format selected with --format, or with --out-format, its older name.
+func outFormatFromFlags(v *viper.Viper) (string, error) {
+	format := v.GetString("format")
+	if v.IsSet("out-format") {
+		if v.IsSet("format") && v.GetString("out-format") != format {
+			return "", errors.New("--format and --out-format select different output formats")
+		}
+		format = v.GetString("out-format")
+	}
+
+	switch format {
+	case outFormatTgz:
+		return outFormatChart, nil
+	case outFormatChart, outFormatBundle, outFormatDir:
+		return format, nil
+	}
+	return "", errors.Errorf("unknown output format %q", format)
+}
+
+// outFormatName returns the output format opts write, for error messages.
+func outFormatName(opts helm.ConvertOptions) string {
+	switch {
+	case opts.Bundle:
+		return outFormatBundle
+	case opts.ChartDir:
+		return outFormatDir
+	}
+	return outFormatChart
+}
+
+// checkPublishFlags fails if the chart is published to a repository or registry, but opts writes no chart archive.
+func checkPublishFlags(v *viper.Viper, opts helm.ConvertOptions) error {
+	publish := v.GetString("chartmuseum-url") != "" || v.GetString("push") != "" || v.GetBool("install-to-cache") || v.GetString("git-push") != ""
+	if publish && (opts.Bundle || opts.ChartDir) {
+		return errors.Errorf("--format %s can't be combined with --chartmuseum-url, --push, --install-to-cache or --git-push", outFormatName(opts))
+	}
+	if publish && opts.ValuesOnly {
+		return errors.New("--values-only can't be combined with --chartmuseum-url, --push, --install-to-cache or --git-push")
+	}
+	if v.GetBool("cosign-sign") && v.GetString("push") == "" {
+		return errors.New("--cosign-sign signs the pushed chart and requires --push")
+	}
+	if v.GetString("cosign-key") != "" && !v.GetBool("cosign-sign") {
+		return errors.New("--cosign-key requires --cosign-sign")
+	}
+	return nil
+}
+
+func releaseStatusFromFlags(v *viper.Viper) (helmrelease.Status, error) {
+	if v.GetString("status") == "" || v.GetString("status") == "any" {
+		return "", nil
+	}
+	return helm.ParseReleaseStatus(v.GetString("status"))
+}
+
+// printValuesOnly prints the path of the values file written by --values-only.
+func printValuesOnly(output string, result *helm.ConversionResult) error {
+	if output != outputText {
+		return printOutput(output, convertOutput{ConversionResult: *result})
+	}
+	if result.ValuesPath == "" {
+		fmt.Fprintln(os.Stderr, "Release has no values, nothing has been written")
+		return nil
+	}
+	fmt.Println(result.ValuesPath)
+	return nil
+}
+
+func printChartDigest(result *helm.ConversionResult) {
+	if result.Digest != "" {
+		fmt.Println("Chart digest:", result.Digest)
+	}
+	if result.ProvenancePath != "" {
+		fmt.Println("Provenance file has been saved to", result.ProvenancePath)
+	}
+	if result.NotesPath != "" {
+		fmt.Println("Release notes have been saved to", result.NotesPath)
+	}
+}
+
+// defaultKeyring is the keyring helm package --sign uses by default.
+func defaultKeyring() string {
+	if gnupgHome := os.Getenv("GNUPGHOME"); gnupgHome != "" {
+		return filepath.Join(gnupgHome, "pubring.gpg")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".gnupg", "pubring.gpg")
+}
+
+// installNamespace returns the namespace of the suggested install command, --install-namespace if it is set.
+func installNamespace(v *viper.Viper, releaseNamespace string) string {
+	if namespace := v.GetString("install-namespace"); namespace != "" {
+		return namespace
+	}
+	return releaseNamespace
+}
+
+// installReleaseName is the release name of the suggested install command, the --rename name if it is set.
+func installReleaseName(result *helm.ConversionResult, opts helm.ConvertOptions) string {
+	if opts.Rename != "" {
+		return opts.Rename
+	}
+	return result.ReleaseName
+}
+
+func installCommand(releaseName string, namespace string, chartFile string, valuesFile string, kubeContext string) []string {
+	command := []string{"helm", "install", releaseName, chartFile}
+	if valuesFile != "" {
+		command = append(command, "--values", valuesFile)
+	}
+	command = append(command, "--namespace", namespace)
+	if kubeContext != "" {
+		command = append(command, "--kube-context", kubeContext)
+	}
+	return command
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestConvertOptionsDependencyUpdate(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{args: []string{}, want: false},
+		{args: []string{"--dependency-update"}, want: true},
+	}
+	for _, test := range tests {
+		flags := pflag.NewFlagSet("release2chart", pflag.ContinueOnError)
+		addConvertFlags(flags)
+		if err := flags.Parse(test.args); err != nil {
+			t.Fatalf("parse %v: %v", test.args, err)
+		}
+		v := viper.New()
+		if err := v.BindPFlags(flags); err != nil {
+			t.Fatalf("bind flags: %v", err)
+		}
+
+		opts, err := convertOptionsFromFlags(v)
+		if err != nil {
+			t.Fatalf("convertOptionsFromFlags %v: %v", test.args, err)
+		}
+		if opts.DependencyUpdate != test.want {
+			t.Errorf("%v: got DependencyUpdate %t, want %t", test.args, opts.DependencyUpdate, test.want)
+		}
+	}
+}
+
+func TestConvertOptionsFormat(t *testing.T) {
+	tests := []struct {
+		args     []string
+		bundle   bool
+		chartDir bool
+		err      bool
+	}{
+		{args: []string{}},
+		{args: []string{"--format", "tgz"}},
+		{args: []string{"--format", "dir"}, chartDir: true},
+		{args: []string{"--out-format", "dir"}, chartDir: true},
+		{args: []string{"--format", "release-bundle", "--out-format", "release-bundle"}, bundle: true},
+		{args: []string{"--format", "dir", "--out-format", "release-bundle"}, err: true},
+		{args: []string{"--format", "zip"}, err: true},
+	}
+	for _, test := range tests {
+		flags := pflag.NewFlagSet("release2chart", pflag.ContinueOnError)
+		addConvertFlags(flags)
+		if err := flags.Parse(test.args); err != nil {
+			t.Fatalf("parse %v: %v", test.args, err)
+		}
+		v := viper.New()
+		if err := v.BindPFlags(flags); err != nil {
+			t.Fatalf("bind flags: %v", err)
+		}
+
+		opts, err := convertOptionsFromFlags(v)
+		if test.err {
+			if err == nil {
+				t.Errorf("%v: got no error", test.args)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("convertOptionsFromFlags %v: %v", test.args, err)
+		}
+		if opts.Bundle != test.bundle || opts.ChartDir != test.chartDir {
+			t.Errorf("%v: got Bundle %t and ChartDir %t, want %t and %t", test.args, opts.Bundle, opts.ChartDir, test.bundle, test.chartDir)
+		}
+	}
+}
+
+func TestInstallCommandFlagsNotInherited(t *testing.T) {
+	root := RootCmd()
+	flags := []string{"dry-run", "install-namespace", "target-context", "install-command-template"}
+	for _, name := range flags {
+		if root.Flags().Lookup(name) == nil {
+			t.Errorf("release2chart has no --%s flag", name)
+		}
+	}
+
+	// only the commands that convert a release or print an install command have them
+	want := map[string][]string{
+		"decode":       flags,
+		"convert-many": {"dry-run"},
+		"unbundle":     {"install-namespace", "target-context", "install-command-template"},
+	}
+	for _, cmd := range root.Commands() {
+		has := map[string]bool{}
+		for _, name := range want[cmd.Name()] {
+			has[name] = true
+		}
+		for _, name := range flags {
+			found := cmd.Flags().Lookup(name) != nil || cmd.InheritedFlags().Lookup(name) != nil
+			if found != has[name] {
+				t.Errorf("%s: got --%s %t, want %t", cmd.Name(), name, found, has[name])
+			}
+		}
+	}
+}
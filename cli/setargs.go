@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_./=:@%+-]+$`)
+
+// setArgsFromValuesFile converts a values file to --set arguments, warning about values that can't be converted.
+func setArgsFromValuesFile(valuesFile string) ([]string, error) {
+	values, err := chartutil.ReadValuesFile(valuesFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "read values file")
+	}
+
+	args, warnings := helm.ValuesToSetArgs(values)
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, "Warning:", warning)
+	}
+	if len(warnings) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: some values can't be passed with --set, use --values %s instead\n", valuesFile)
+	}
+
+	return args, nil
+}
+
+// shellJoin joins a command line, quoting arguments that the shell would otherwise interpret.
+func shellJoin(command []string) string {
+	quoted := []string{}
+	for _, arg := range command {
+		if shellSafe.MatchString(arg) {
+			quoted = append(quoted, arg)
+		} else {
+			quoted = append(quoted, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+		}
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// stdoutConflicts are flags that write more than the chart archive or need it on disk, and can't be
+// combined with --stdout.
+var stdoutConflicts = []string{
+	"from-list", "clusters-file", "all-revisions", "values-only", "dry-run", "show-install-only", "sign", "repo-index",
+	"values-history", "expected-values", "compare-with-cluster", "diff-upstream",
+	"chartmuseum-url", "push", "install-to-cache", "git-push", "as-set",
+}
+
+// useStdout makes opts convert into memory, so writeChartToStdout can stream the archive.
+func useStdout(v *viper.Viper, opts *helm.ConvertOptions) error {
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		return errors.New("--stdout writes a binary chart archive, redirect it to a file or pipe it to another command")
+	}
+	for _, flag := range stdoutConflicts {
+		if v.IsSet(flag) {
+			return errors.Errorf("--stdout can't be combined with --%s", flag)
+		}
+	}
+	if v.GetString("output") != outputText {
+		return errors.New("--stdout can't be combined with --output")
+	}
+	if opts.Bundle || opts.ChartDir {
+		return errors.Errorf("--stdout can't be combined with --format %s", outFormatName(*opts))
+	}
+	if opts.ImageListFile == "-" {
+		return errors.New("--stdout can't be combined with --list-images -")
+	}
+
+	opts.OutputFs = afero.NewMemMapFs()
+	opts.Overwrite = true
+	return nil
+}
+
+// writeChartToStdout copies the chart archive of result from the in-memory output of useStdout to stdout.
+// The values file isn't streamed.
+func writeChartToStdout(opts helm.ConvertOptions, result *helm.ConversionResult) error {
+	if result.ValuesPath != "" {
+		fmt.Fprintln(os.Stderr, "Warning: the release values are not written with --stdout, recover them with --values-only")
+	}
+
+	f, err := opts.OutputFs.Open(result.ChartPath)
+	if err != nil {
+		return errors.Wrap(err, "open chart archive")
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(os.Stdout, f); err != nil {
+		return errors.Wrap(err, "write chart archive to stdout")
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	outFormatChart  = "chart"
+	outFormatTgz    = "tgz"
+	outFormatBundle = "release-bundle"
+	outFormatDir    = "dir"
+)
+
+func UnbundleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "unbundle [bundle file]",
+		Short:        "Extract a release bundle",
+		Long:         `Extract the chart archive, values file and notes of a bundle written with --format release-bundle`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			commandTemplate, err := installCommandTemplateFromFlags(v)
+			if err != nil {
+				return err
+			}
+
+			destDir := v.GetString("dest-dir")
+			manifest, err := helm.Unbundle(args[0], destDir)
+			if err != nil {
+				return errors.Wrap(err, "unbundle")
+			}
+
+			fmt.Printf("Release %s/%s revision %d has been extracted to %s\n", manifest.Release.Namespace, manifest.Release.Name, manifest.Release.Revision, destDir)
+
+			valuesFile := ""
+			if manifest.Values != "" {
+				valuesFile = filepath.Join(destDir, manifest.Values)
+			}
+			commandData := installCommandData{
+				ReleaseName: manifest.Release.Name,
+				ChartFile:   filepath.Join(destDir, manifest.Chart.File),
+				ValuesFile:  valuesFile,
+				Namespace:   installNamespace(v, manifest.Release.Namespace),
+				KubeContext: v.GetString("target-context"),
+			}
+			command := installCommand(commandData.ReleaseName, commandData.Namespace, commandData.ChartFile, valuesFile, commandData.KubeContext)
+			formattedCommand, err := formatInstallCommand(commandTemplate, command, commandData)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println("To install the chart, run the following command:")
+			fmt.Println("")
+			fmt.Println(formattedCommand)
+			fmt.Println("")
+
+			return nil
+		},
+	}
+
+	cmd.Flags().String("dest-dir", ".", "directory to extract the bundle to")
+	addInstallCommandFlags(cmd.Flags())
+
+	return cmd
+}
+
+func printBundleSaved(bundleFile string) {
+	fmt.Println("Release bundle has been saved to", bundleFile)
+	fmt.Println("To extract it, run the following command:")
+	fmt.Println("")
+	fmt.Println(shellJoin([]string{"release2chart", "unbundle", bundleFile}))
+	fmt.Println("")
+}
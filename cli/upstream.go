@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/divolgin/release2chart/pkg/helm"
+	"github.com/pkg/errors"
+)
+
+func printUpstreamDiff(ctx context.Context, client *helm.Client, namespace string, releaseName string, revision int, repoURL string) error {
+	diffs, err := client.DiffWithUpstream(ctx, namespace, releaseName, revision, repoURL)
+	if err != nil {
+		return errors.Wrap(err, "diff with upstream")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tSTATUS")
+	for _, diff := range diffs {
+		fmt.Fprintf(w, "%s\t%s\n", diff.Name, diff.Status)
+	}
+
+	return w.Flush()
+}
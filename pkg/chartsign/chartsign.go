@@ -0,0 +1,306 @@
+// Package chartsign signs charts pushed to OCI registries with cosign. It is kept out of pkg/helm, so only
+// programs that sign charts depend on the sigstore libraries.
+package chartsign
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	dockerconfig "github.com/docker/cli/cli/config"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pkg/errors"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	cbundle "github.com/sigstore/cosign/v2/pkg/cosign/bundle"
+	"github.com/sigstore/cosign/v2/pkg/oci/mutate"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	"github.com/sigstore/cosign/v2/pkg/oci/static"
+	"github.com/sigstore/cosign/v2/pkg/providers"
+	_ "github.com/sigstore/cosign/v2/pkg/providers/all"
+	sigs "github.com/sigstore/cosign/v2/pkg/signature"
+	fulcioapi "github.com/sigstore/fulcio/pkg/api"
+	rekorclient "github.com/sigstore/rekor/pkg/client"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/oauthflow"
+	"github.com/sigstore/sigstore/pkg/signature"
+	_ "github.com/sigstore/sigstore/pkg/signature/kms/aws"
+	_ "github.com/sigstore/sigstore/pkg/signature/kms/azure"
+	_ "github.com/sigstore/sigstore/pkg/signature/kms/gcp"
+	_ "github.com/sigstore/sigstore/pkg/signature/kms/hashivault"
+	"github.com/sigstore/sigstore/pkg/signature/payload"
+	"golang.org/x/term"
+	"helm.sh/helm/v3/pkg/cli"
+)
+
+const (
+	fulcioURL    = "https://fulcio.sigstore.dev"
+	rekorURL     = "https://rekor.sigstore.dev"
+	oidcIssuer   = "https://oauth2.sigstore.dev/auth"
+	oidcClientID = "sigstore"
+
+	signTimeout = 3 * time.Minute
+)
+
+type Options struct {
+	// Key is a cosign private key, a file or a KMS URI. If it is empty, the chart is signed keyless with
+	// a Fulcio certificate for the OIDC identity of the user, found in the environment, e.g. in CI, or by
+	// logging in with a browser.
+	Key string
+	// SkipConfirmation doesn't ask before publishing the identity to the Rekor transparency log.
+	SkipConfirmation bool
+	// Username and Password authenticate with the registry. Without them the credentials Helm stored with
+	// helm registry login are used, and then the docker credentials.
+	Username string
+	Password string
+}
+
+// Sign signs the chart manifest with digest pushed to ref, records the signature in the Rekor transparency log
+// and uploads it next to the chart, like cosign sign does. It returns the tag of the signature.
+func Sign(ref string, digest string, opts Options) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		return "", errors.Wrapf(err, "parse chart reference %s", ref)
+	}
+	// sign the pushed manifest, a tag could be moved to another one in the meantime
+	signed := parsed.Context().Digest(digest)
+
+	remoteOpts := ociremote.WithRemoteOptions(
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(registryKeychain{username: opts.Username, password: opts.Password}),
+	)
+	entity, err := ociremote.SignedEntity(signed, remoteOpts)
+	if err != nil {
+		return "", errors.Wrap(err, "get pushed chart")
+	}
+
+	if !opts.SkipConfirmation {
+		if err := confirmTlogUpload(); err != nil {
+			return "", err
+		}
+	}
+
+	signer, cert, chain, err := newSigner(ctx, opts)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := (&payload.Cosign{Image: signed}).MarshalJSON()
+	if err != nil {
+		return "", errors.Wrap(err, "marshal payload")
+	}
+	sig, err := signer.SignMessage(bytes.NewReader(data))
+	if err != nil {
+		return "", errors.Wrap(err, "sign payload")
+	}
+
+	bundle, err := uploadToTlog(ctx, signer, cert, data, sig)
+	if err != nil {
+		return "", errors.Wrap(err, "upload to transparency log")
+	}
+
+	ociSig, err := static.NewSignature(data, base64.StdEncoding.EncodeToString(sig), static.WithCertChain(cert, chain), static.WithBundle(bundle))
+	if err != nil {
+		return "", errors.Wrap(err, "create signature")
+	}
+	entity, err = mutate.AttachSignatureToEntity(entity, ociSig)
+	if err != nil {
+		return "", errors.Wrap(err, "attach signature")
+	}
+	if err := ociremote.WriteSignatures(signed.Repository, entity, remoteOpts); err != nil {
+		return "", errors.Wrap(err, "push signature")
+	}
+
+	tag, err := ociremote.SignatureTag(signed, remoteOpts)
+	if err != nil {
+		return "", errors.Wrap(err, "get signature tag")
+	}
+	return tag.String(), nil
+}
+
+// newSigner loads opts.Key, or creates an ephemeral key and gets a Fulcio certificate for it, which is returned
+// with its chain.
+func newSigner(ctx context.Context, opts Options) (signature.SignerVerifier, []byte, []byte, error) {
+	if opts.Key != "" {
+		signer, err := sigs.SignerVerifierFromKeyRef(ctx, opts.Key, keyPassword)
+		if err != nil {
+			return nil, nil, nil, errors.Wrap(err, "load key")
+		}
+		return signer, nil, nil, nil
+	}
+
+	key, err := cosign.GeneratePrivateKey()
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "generate ephemeral key")
+	}
+	signer, err := signature.LoadECDSASignerVerifier(key, crypto.SHA256)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "load ephemeral key")
+	}
+
+	token, err := oidcToken(ctx)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "get OIDC token")
+	}
+
+	publicKey, err := cryptoutils.MarshalPublicKeyToPEM(key.Public())
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "marshal public key")
+	}
+	// Fulcio checks the key belongs to the identity by the signed subject
+	proof, err := signer.SignMessage(strings.NewReader(token.Subject))
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "sign subject")
+	}
+
+	fulcioServer, err := url.Parse(fulcioURL)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "parse Fulcio url")
+	}
+	fmt.Fprintln(os.Stderr, "Retrieving signing certificate for", token.Subject)
+	cert, err := fulcioapi.NewClient(fulcioServer).SigningCert(fulcioapi.CertificateRequest{
+		PublicKey:          fulcioapi.Key{Content: publicKey},
+		SignedEmailAddress: proof,
+	}, token.RawString)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "get signing certificate")
+	}
+	return signer, cert.CertPEM, cert.ChainPEM, nil
+}
+
+// oidcToken finds an OIDC token in the environment, e.g. of GitHub Actions. Without one, the user logs in with
+// a browser, or with a device code if there is no terminal.
+func oidcToken(ctx context.Context) (*oauthflow.OIDCIDToken, error) {
+	var getter oauthflow.TokenGetter
+	switch {
+	case providers.Enabled(ctx):
+		raw, err := providers.Provide(ctx, oidcClientID)
+		if err != nil {
+			return nil, errors.Wrap(err, "get ambient credentials")
+		}
+		getter = &oauthflow.StaticTokenGetter{RawToken: raw}
+	case !term.IsTerminal(int(os.Stdin.Fd())):
+		getter = oauthflow.NewDeviceFlowTokenGetterForIssuer(oidcIssuer)
+	default:
+		getter = oauthflow.DefaultIDTokenGetter
+	}
+	return oauthflow.OIDConnect(oidcIssuer, oidcClientID, "", "", getter)
+}
+
+// uploadToTlog records the signature in Rekor with the certificate, or the public key of a signing key, and
+// returns the bundle that lets the signature be verified offline.
+func uploadToTlog(ctx context.Context, signer signature.SignerVerifier, cert []byte, data []byte, sig []byte) (*cbundle.RekorBundle, error) {
+	verifier := cert
+	if verifier == nil {
+		publicKey, err := signer.PublicKey()
+		if err != nil {
+			return nil, errors.Wrap(err, "get public key")
+		}
+		verifier, err = cryptoutils.MarshalPublicKeyToPEM(publicKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "marshal public key")
+		}
+	}
+
+	client, err := rekorclient.GetRekorClient(rekorURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "create Rekor client")
+	}
+	checksum := sha256.New()
+	checksum.Write(data)
+	entry, err := cosign.TLogUpload(ctx, client, sig, checksum, verifier)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(os.Stderr, "Transparency log entry created with index", *entry.LogIndex)
+	return cbundle.EntryToBundle(entry), nil
+}
+
+// confirmTlogUpload asks before the identity or public key is published in the transparency log, where it can't
+// be removed. Without a terminal, e.g. in CI, there is no one to ask.
+func confirmTlogUpload() error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "The signature, with your OIDC identity or public key, will be recorded in the public transparency log at %s and can't be removed. Continue? [y/N] ", rekorURL)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return errors.Wrap(err, "read confirmation")
+	}
+	if answer := strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return errors.New("transparency log upload was not confirmed")
+	}
+	return nil
+}
+
+// keyPassword returns the password of an encrypted key from COSIGN_PASSWORD, like cosign, or asks for it.
+func keyPassword(bool) ([]byte, error) {
+	if password, ok := os.LookupEnv("COSIGN_PASSWORD"); ok {
+		return []byte(password), nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, errors.New("set COSIGN_PASSWORD to the password of the cosign key")
+	}
+	fmt.Fprint(os.Stderr, "Enter password for the cosign key: ")
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, errors.Wrap(err, "read password")
+	}
+	return password, nil
+}
+
+// registryKeychain finds registry credentials the way helm.PushToRegistry does: the username and password, or
+// the credentials Helm stored with helm registry login. Registries without either fall back to the docker
+// credentials.
+type registryKeychain struct {
+	username string
+	password string
+}
+
+func (k registryKeychain) Resolve(resource authn.Resource) (authn.Authenticator, error) {
+	if k.username != "" || k.password != "" {
+		return authn.FromConfig(authn.AuthConfig{Username: k.username, Password: k.password}), nil
+	}
+
+	f, err := os.Open(cli.New().RegistryConfig)
+	if os.IsNotExist(err) {
+		return authn.DefaultKeychain.Resolve(resource)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "open registry config")
+	}
+	defer f.Close()
+
+	credentials, err := dockerconfig.LoadFromReader(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "load registry config")
+	}
+	auth, err := credentials.GetAuthConfig(resource.RegistryStr())
+	if err != nil {
+		return nil, errors.Wrapf(err, "get credentials of %s", resource.RegistryStr())
+	}
+	if auth.Username == "" && auth.Password == "" && auth.Auth == "" && auth.IdentityToken == "" && auth.RegistryToken == "" {
+		return authn.DefaultKeychain.Resolve(resource)
+	}
+
+	return authn.FromConfig(authn.AuthConfig{
+		Username:      auth.Username,
+		Password:      auth.Password,
+		Auth:          auth.Auth,
+		IdentityToken: auth.IdentityToken,
+		RegistryToken: auth.RegistryToken,
+	}), nil
+}
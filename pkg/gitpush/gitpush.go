@@ -0,0 +1,188 @@
+// Package gitpush commits converted charts to a git repository.
+package gitpush
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// ReleaseValuesFile is the file the release values are written to next to the chart dir. It is not named
+// values.yaml, which holds the chart defaults in the chart dir.
+const ReleaseValuesFile = "values-release.yaml"
+
+type Options struct {
+	RepoURL string
+	Branch  string
+	// Path is the directory in the repository the chart is written to.
+	Path string
+	// SSHKeyFile is the private key used for ssh remotes.
+	SSHKeyFile string
+	// Token is used as the password for https remotes.
+	Token   string
+	Message string
+	// ValuesFileMode is the mode of ReleaseValuesFile in the clone, 0600 if not set.
+	ValuesFileMode os.FileMode
+}
+
+// Push clones the repository, replaces Path with the unpacked chart in a dir named after the chart and the
+// release values in ReleaseValuesFile, commits and pushes the result.
+func Push(chartFile string, valuesFile string, opts Options) error {
+	if opts.Path == "" || filepath.IsAbs(opts.Path) || strings.HasPrefix(filepath.Clean(opts.Path), "..") {
+		return errors.Errorf("invalid repository path %q", opts.Path)
+	}
+
+	ch, err := loader.Load(chartFile)
+	if err != nil {
+		return errors.Wrap(err, "load chart")
+	}
+
+	auth, err := repoAuth(opts)
+	if err != nil {
+		return err
+	}
+
+	cloneDir, err := ioutil.TempDir("", "release2chart-git-")
+	if err != nil {
+		return errors.Wrap(err, "create temp dir")
+	}
+	defer os.RemoveAll(cloneDir)
+
+	cloneOpts := &git.CloneOptions{
+		URL:   opts.RepoURL,
+		Auth:  auth,
+		Depth: 1,
+	}
+	if opts.Branch != "" {
+		cloneOpts.ReferenceName = plumbing.NewBranchReferenceName(opts.Branch)
+		cloneOpts.SingleBranch = true
+	}
+	repo, err := git.PlainClone(cloneDir, false, cloneOpts)
+	if err != nil {
+		return errors.Wrap(err, "clone repository")
+	}
+
+	destDir := filepath.Join(cloneDir, opts.Path)
+	if err := os.RemoveAll(destDir); err != nil {
+		return errors.Wrap(err, "remove previous chart")
+	}
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return errors.Wrap(err, "create destination dir")
+	}
+
+	// the chart is written to a dir named after it, the release values next to it
+	if err := chartutil.SaveDir(ch, destDir); err != nil {
+		return errors.Wrap(err, "save unpacked chart")
+	}
+
+	if valuesFile != "" {
+		data, err := ioutil.ReadFile(valuesFile)
+		if err != nil {
+			return errors.Wrap(err, "read values file")
+		}
+		mode := opts.ValuesFileMode
+		if mode == 0 {
+			mode = 0600
+		}
+		if err := ioutil.WriteFile(filepath.Join(destDir, ReleaseValuesFile), data, mode); err != nil {
+			return errors.Wrap(err, "write values file")
+		}
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return errors.Wrap(err, "open worktree")
+	}
+	// adding the dir also stages the files of the previous chart that were removed
+	if _, err := worktree.Add(filepath.ToSlash(opts.Path)); err != nil {
+		return errors.Wrap(err, "add chart")
+	}
+
+	status, err := worktree.Status()
+	if err != nil {
+		return errors.Wrap(err, "get worktree status")
+	}
+	if !hasStagedChanges(status) {
+		return nil
+	}
+
+	if _, err := worktree.Commit(opts.Message, &git.CommitOptions{Author: commitAuthor()}); err != nil {
+		return errors.Wrap(err, "commit chart")
+	}
+
+	if err := repo.Push(&git.PushOptions{Auth: auth}); err != nil {
+		return errors.Wrap(err, "push")
+	}
+
+	return nil
+}
+
+// repoAuth returns the credentials for the repository, or nil to use the defaults of the transport,
+// such as the ssh agent.
+func repoAuth(opts Options) (transport.AuthMethod, error) {
+	if opts.Token != "" {
+		u, err := url.Parse(opts.RepoURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse repository url")
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, errors.Errorf("token authentication requires an http(s) repository url, got %q", u.Scheme)
+		}
+		return &githttp.BasicAuth{Username: "x-access-token", Password: opts.Token}, nil
+	}
+
+	if opts.SSHKeyFile != "" {
+		endpoint, err := transport.NewEndpoint(opts.RepoURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse repository url")
+		}
+		user := endpoint.User
+		if user == "" {
+			user = "git"
+		}
+		auth, err := gitssh.NewPublicKeysFromFile(user, opts.SSHKeyFile, "")
+		if err != nil {
+			return nil, errors.Wrap(err, "load ssh key")
+		}
+		return auth, nil
+	}
+
+	return nil, nil
+}
+
+func hasStagedChanges(status git.Status) bool {
+	for _, file := range status {
+		if file.Staging != git.Unmodified && file.Staging != git.Untracked {
+			return true
+		}
+	}
+	return false
+}
+
+// commitAuthor returns the user of the global git config, like the git command line client would commit as.
+func commitAuthor() *object.Signature {
+	author := &object.Signature{Name: "release2chart", Email: "release2chart@localhost", When: time.Now()}
+	if cfg, err := config.LoadConfig(config.GlobalScope); err == nil {
+		if cfg.User.Name != "" {
+			author.Name = cfg.User.Name
+		}
+		if cfg.User.Email != "" {
+			author.Email = cfg.User.Email
+		}
+	}
+	return author
+}
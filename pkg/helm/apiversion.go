@@ -0,0 +1,26 @@
+package helm
+
+import (
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// isV1Chart returns true if c has the Helm 2 apiVersion v1. Charts without an apiVersion are v1 as well.
+func isV1Chart(c *chart.Chart) bool {
+	return c.Metadata.APIVersion == "" || c.Metadata.APIVersion == chart.APIVersionV1
+}
+
+// migrateChartAPIVersion upgrades a v1 chart to apiVersion v2. The dependencies are already part of the chart
+// metadata written to Chart.yaml, so requirements.yaml is dropped. requirements.lock is dropped as well,
+// converted charts don't include a Chart.lock either.
+func migrateChartAPIVersion(c *chart.Chart) {
+	c.Metadata.APIVersion = chart.APIVersionV2
+
+	files := []*chart.File{}
+	for _, file := range c.Files {
+		if file.Name == "requirements.yaml" || file.Name == "requirements.lock" {
+			continue
+		}
+		files = append(files, file)
+	}
+	c.Files = files
+}
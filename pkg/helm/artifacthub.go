@@ -0,0 +1,71 @@
+package helm
+
+import (
+	"path"
+	"strconv"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+type artifactHubImage struct {
+	Name  string `yaml:"name"`
+	Image string `yaml:"image"`
+}
+
+type artifactHubChange struct {
+	Kind        string `yaml:"kind"`
+	Description string `yaml:"description"`
+}
+
+// addArtifactHubAnnotations adds the Artifact Hub annotations that can be derived from the release
+// to the chart metadata: artifacthub.io/images from the manifest, artifacthub.io/changes from the
+// release description and artifacthub.io/prerelease from the chart version.
+// Annotations already present in the chart are kept.
+func addArtifactHubAnnotations(release *helmrelease.Release) error {
+	metadata := release.Chart.Metadata
+	if metadata.Annotations == nil {
+		metadata.Annotations = map[string]string{}
+	}
+
+	setAnnotation := func(key string, value string) {
+		if _, ok := metadata.Annotations[key]; !ok {
+			metadata.Annotations[key] = value
+		}
+	}
+
+	images, err := manifestImages(release.Manifest)
+	if err != nil {
+		return errors.Wrap(err, "find manifest images")
+	}
+	if len(images) > 0 {
+		hubImages := []artifactHubImage{}
+		for _, image := range images {
+			hubImages = append(hubImages, artifactHubImage{
+				Name:  path.Base(imageRepository(image)),
+				Image: image,
+			})
+		}
+		data, err := yaml.Marshal(hubImages)
+		if err != nil {
+			return errors.Wrap(err, "marshal images annotation")
+		}
+		setAnnotation("artifacthub.io/images", string(data))
+	}
+
+	if release.Info != nil && release.Info.Description != "" {
+		data, err := yaml.Marshal([]artifactHubChange{{Kind: "changed", Description: release.Info.Description}})
+		if err != nil {
+			return errors.Wrap(err, "marshal changes annotation")
+		}
+		setAnnotation("artifacthub.io/changes", string(data))
+	}
+
+	if version, err := semver.NewVersion(metadata.Version); err == nil {
+		setAnnotation("artifacthub.io/prerelease", strconv.FormatBool(version.Prerelease() != ""))
+	}
+
+	return nil
+}
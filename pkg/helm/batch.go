@@ -0,0 +1,61 @@
+package helm
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// ConvertReleases converts the latest revision with the given status, or a pending one if includePending is set,
+// of every named release in the namespace, at most parallelism at a time. Each release is written to its own <DestDir>/<release> directory, and so are
+// the other files opts asks for. Results and errors are returned in the order of names; a failed release has
+// a nil result and a non-nil error.
+func (c *Client) ConvertReleases(ctx context.Context, namespace string, names []string, status helmrelease.Status, includePending bool, parallelism int, opts ConvertOptions) ([]*ConversionResult, []error) {
+	results := make([]*ConversionResult, len(names))
+	errs := make([]error, len(names))
+	if opts.Rename != "" && len(names) > 1 {
+		for i := range names {
+			errs[i] = errors.New("rename applies to a single release")
+		}
+		return results, errs
+	}
+
+	seen := map[string]bool{}
+	for i, name := range names {
+		if seen[name] {
+			// converting it twice would write to the same directory concurrently
+			errs[i] = errors.Errorf("release %s is listed more than once", name)
+		}
+		seen[name] = true
+	}
+
+	forEachParallel(len(names), parallelism, func(i int) error {
+		if errs[i] != nil {
+			return nil
+		}
+		if opts.Progress != nil {
+			opts.Progress(names[i], i+1, len(names))
+		}
+		results[i], errs[i] = c.convertNamedRelease(ctx, namespace, names[i], status, includePending, opts)
+		return nil
+	})
+
+	return results, errs
+}
+
+func (c *Client) convertNamedRelease(ctx context.Context, namespace string, releaseName string, status helmrelease.Status, includePending bool, opts ConvertOptions) (*ConversionResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	opts.DestDir = filepath.Join(opts.DestDir, releaseName)
+	for _, file := range []*string{&opts.ReportFile, &opts.DumpReleaseFile, &opts.ManifestFile, &opts.SplitManifestDir, &opts.HooksDir} {
+		if *file != "" {
+			*file = filepath.Join(opts.DestDir, filepath.Base(*file))
+		}
+	}
+
+	return c.Convert(ctx, ReleaseTarget{Namespace: namespace, Name: releaseName, Status: status, IncludePending: includePending}, opts)
+}
@@ -0,0 +1,46 @@
+package helm
+
+import (
+	"path/filepath"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// setBuildMetadata replaces the build metadata of the chart version in chartDir and in metadata,
+// e.g. 1.2.3 becomes 1.2.3+build.42.
+func setBuildMetadata(chartDir string, metadata *chart.Metadata, buildMetadata string) error {
+	version, err := versionWithBuildMetadata(metadata.Version, buildMetadata)
+	if err != nil {
+		return err
+	}
+
+	chartfile := filepath.Join(chartDir, chartutil.ChartfileName)
+	chartMetadata, err := chartutil.LoadChartfile(chartfile)
+	if err != nil {
+		return errors.Wrap(err, "load Chart.yaml")
+	}
+	chartMetadata.Version = version
+	if err := chartutil.SaveChartfile(chartfile, chartMetadata); err != nil {
+		return errors.Wrap(err, "save Chart.yaml")
+	}
+
+	metadata.Version = version
+	return nil
+}
+
+func versionWithBuildMetadata(chartVersion string, buildMetadata string) (string, error) {
+	version, err := semver.StrictNewVersion(chartVersion)
+	if err != nil {
+		return "", errors.Wrapf(err, "parse chart version %q", chartVersion)
+	}
+
+	withMetadata, err := version.SetMetadata(buildMetadata)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid build metadata %q", buildMetadata)
+	}
+
+	return withMetadata.String(), nil
+}
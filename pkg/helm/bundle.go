@@ -0,0 +1,214 @@
+package helm
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+const (
+	BundleManifestFile = "bundle.yaml"
+	BundleAPIVersion   = "release2chart/v1"
+	BundleKind         = "ReleaseBundle"
+
+	bundleNotesFile = "NOTES.txt"
+)
+
+// BundleManifest is the bundle.yaml at the top of a release bundle. File names are relative to the bundle.
+type BundleManifest struct {
+	APIVersion string        `yaml:"apiVersion"`
+	Kind       string        `yaml:"kind"`
+	Release    BundleRelease `yaml:"release"`
+	Chart      BundleChart   `yaml:"chart"`
+	Values     string        `yaml:"values,omitempty"`
+	Notes      string        `yaml:"notes,omitempty"`
+}
+
+type BundleRelease struct {
+	Name         string    `yaml:"name"`
+	Namespace    string    `yaml:"namespace"`
+	Revision     int       `yaml:"revision"`
+	Status       string    `yaml:"status,omitempty"`
+	LastDeployed time.Time `yaml:"lastDeployed,omitempty"`
+	Description  string    `yaml:"description,omitempty"`
+}
+
+type BundleChart struct {
+	Name       string `yaml:"name"`
+	Version    string `yaml:"version"`
+	AppVersion string `yaml:"appVersion,omitempty"`
+	File       string `yaml:"file"`
+}
+
+// writeReleaseBundle packs the converted chart and values files in dir into a single tar file
+// described by bundle.yaml, and removes the individual files.
+func writeReleaseBundle(release *helmrelease.Release, dir string, chartFile string, valuesFile string, mode os.FileMode, reproducible bool) (string, error) {
+	modTime, err := archiveModTime(reproducible)
+	if err != nil {
+		return "", err
+	}
+
+	manifest := BundleManifest{
+		APIVersion: BundleAPIVersion,
+		Kind:       BundleKind,
+		Release: BundleRelease{
+			Name:      release.Name,
+			Namespace: release.Namespace,
+			Revision:  release.Version,
+		},
+		Chart: BundleChart{
+			Name:       release.Chart.Metadata.Name,
+			Version:    release.Chart.Metadata.Version,
+			AppVersion: release.Chart.Metadata.AppVersion,
+			File:       chartFile,
+		},
+		Values: valuesFile,
+	}
+
+	notes := ""
+	if release.Info != nil {
+		manifest.Release.Status = release.Info.Status.String()
+		manifest.Release.LastDeployed = release.Info.LastDeployed.Time
+		manifest.Release.Description = release.Info.Description
+		notes = release.Info.Notes
+	}
+	if notes != "" {
+		manifest.Notes = bundleNotesFile
+	}
+
+	manifestData, err := yaml.Marshal(manifest)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal bundle manifest")
+	}
+
+	bundleFile := strings.TrimSuffix(chartFile, ".tgz") + ".bundle.tar"
+	f, err := os.OpenFile(filepath.Join(dir, bundleFile), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return "", errors.Wrap(err, "create bundle file")
+	}
+	defer f.Close()
+
+	tarWriter := tar.NewWriter(f)
+
+	if err := writeTarFile(tarWriter, BundleManifestFile, manifestData, 0644, modTime); err != nil {
+		return "", errors.Wrap(err, "write bundle manifest")
+	}
+	for _, name := range []string{chartFile, valuesFile} {
+		if name == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return "", errors.Wrapf(err, "read %s", name)
+		}
+		fileMode := os.FileMode(0644)
+		if name == valuesFile {
+			fileMode = mode
+		}
+		if err := writeTarFile(tarWriter, name, data, fileMode, modTime); err != nil {
+			return "", errors.Wrapf(err, "write %s", name)
+		}
+	}
+	if notes != "" {
+		if err := writeTarFile(tarWriter, bundleNotesFile, []byte(notes), 0644, modTime); err != nil {
+			return "", errors.Wrap(err, "write notes")
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return "", errors.Wrap(err, "close tar writer")
+	}
+	if err := f.Close(); err != nil {
+		return "", errors.Wrap(err, "close bundle file")
+	}
+
+	for _, name := range []string{chartFile, valuesFile} {
+		if name == "" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return "", errors.Wrapf(err, "remove %s", name)
+		}
+	}
+
+	return bundleFile, nil
+}
+
+func writeTarFile(tarWriter *tar.Writer, name string, data []byte, mode os.FileMode, modTime time.Time) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    int64(mode),
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return errors.Wrap(err, "write tar header")
+	}
+	if _, err := tarWriter.Write(data); err != nil {
+		return errors.Wrap(err, "write tar data")
+	}
+	return nil
+}
+
+// Unbundle extracts a release bundle into destDir and returns its manifest.
+// Bundles only contain top-level files, any other entry is rejected.
+func Unbundle(bundleFile string, destDir string) (*BundleManifest, error) {
+	f, err := os.Open(bundleFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "open bundle")
+	}
+	defer f.Close()
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return nil, errors.Wrap(err, "create dest dir")
+	}
+
+	var manifest *BundleManifest
+	tarReader := tar.NewReader(f)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "read bundle")
+		}
+
+		if header.Typeflag != tar.TypeReg || header.Name != filepath.Base(header.Name) || header.Name == ".." {
+			return nil, errors.Errorf("unexpected entry %q in bundle", header.Name)
+		}
+
+		data, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read %s", header.Name)
+		}
+
+		if header.Name == BundleManifestFile {
+			manifest = &BundleManifest{}
+			if err := yaml.Unmarshal(data, manifest); err != nil {
+				return nil, errors.Wrap(err, "parse bundle manifest")
+			}
+			if manifest.APIVersion != BundleAPIVersion || manifest.Kind != BundleKind {
+				return nil, errors.Errorf("unsupported bundle %s %s", manifest.APIVersion, manifest.Kind)
+			}
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(destDir, header.Name), data, os.FileMode(header.Mode).Perm()); err != nil {
+			return nil, errors.Wrapf(err, "write %s", header.Name)
+		}
+	}
+
+	if manifest == nil {
+		return nil, errors.Errorf("%s not found in bundle", BundleManifestFile)
+	}
+
+	return manifest, nil
+}
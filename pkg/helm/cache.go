@@ -0,0 +1,31 @@
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/cli"
+)
+
+// InstallToCache copies a packaged chart into the Helm repository cache directory,
+// which is $HELM_REPOSITORY_CACHE or Helm's default, and returns the cached file path.
+func InstallToCache(chartFile string) (string, error) {
+	cacheDir := cli.New().RepositoryCache
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return "", errors.Wrap(err, "create repository cache dir")
+	}
+
+	data, err := ioutil.ReadFile(chartFile)
+	if err != nil {
+		return "", errors.Wrap(err, "read chart file")
+	}
+
+	cachedFile := filepath.Join(cacheDir, filepath.Base(chartFile))
+	if err := ioutil.WriteFile(cachedFile, data, 0644); err != nil {
+		return "", errors.Wrap(err, "write cached chart")
+	}
+
+	return cachedFile, nil
+}
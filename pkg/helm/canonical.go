@@ -0,0 +1,40 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// canonicalizeChart orders the chart templates and files by name and converts CRLF line endings
+// of text files to LF, so that unpacking the same release always produces the same files.
+func canonicalizeChart(c *chart.Chart) {
+	for _, files := range [][]*chart.File{c.Templates, c.Files} {
+		sort.SliceStable(files, func(i, j int) bool {
+			return files[i].Name < files[j].Name
+		})
+		for _, file := range files {
+			if bytes.IndexByte(file.Data, 0) == -1 {
+				file.Data = bytes.ReplaceAll(file.Data, []byte("\r\n"), []byte("\n"))
+			}
+		}
+	}
+}
+
+// marshalCanonicalMetadata marshals Chart.yaml with sorted keys and two space indentation.
+func marshalCanonicalMetadata(metadata *chart.Metadata) ([]byte, error) {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal metadata")
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, errors.Wrap(err, "unmarshal metadata")
+	}
+
+	return marshalNormalizedValues(fields)
+}
@@ -0,0 +1,88 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// dirFiles returns the contents of the files under dir, keyed by their path relative to it.
+func dirFiles(t *testing.T, dir string) map[string][]byte {
+	t.Helper()
+
+	files := map[string][]byte{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(name)] = data
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("read %s: %v", dir, err)
+	}
+	return files
+}
+
+// canonicalRelease returns a release whose chart has CRLF line endings, unsorted templates and maps with many keys.
+func canonicalRelease() *helmrelease.Release {
+	release := testRelease(1, helmrelease.StatusDeployed)
+	release.Chart.Metadata.Annotations = map[string]string{"z": "1", "a": "2", "m": "3", "c": "4", "x": "5"}
+	release.Chart.Templates = []*chart.File{
+		{Name: "templates/service.yaml", Data: []byte("apiVersion: v1\r\nkind: Service\r\nmetadata:\r\n  name: {{ .Release.Name }}\r\n")},
+		{Name: "templates/configmap.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Release.Name }}\n")},
+	}
+	release.Chart.Values = map[string]interface{}{"replicas": 1, "image": map[string]interface{}{"tag": "1.0", "repository": "app"}, "service": map[string]interface{}{"port": 80, "type": "ClusterIP"}}
+	release.Config = map[string]interface{}{"zone": "b", "env": "prod", "tier": "web", "debug": false, "owner": "team"}
+	return release
+}
+
+func TestConvertReleaseCanonicalStable(t *testing.T) {
+	convert := func() (map[string][]byte, []byte) {
+		result, err := NewClient().ConvertRelease(context.Background(), canonicalRelease(), ConvertOptions{DestDir: t.TempDir(), ChartDir: true, Canonical: true})
+		if err != nil {
+			t.Fatalf("ConvertRelease: %v", err)
+		}
+		values, err := ioutil.ReadFile(result.ValuesPath)
+		if err != nil {
+			t.Fatalf("read values file: %v", err)
+		}
+		return dirFiles(t, result.ChartPath), values
+	}
+
+	firstChart, firstValues := convert()
+	secondChart, secondValues := convert()
+
+	if !reflect.DeepEqual(firstChart, secondChart) {
+		t.Errorf("canonical charts of two conversions differ:\n%v\n%v", firstChart, secondChart)
+	}
+	if !bytes.Equal(firstValues, secondValues) {
+		t.Errorf("canonical values of two conversions differ:\n%s\n%s", firstValues, secondValues)
+	}
+
+	if bytes.Contains(firstChart["templates/service.yaml"], []byte("\r\n")) {
+		t.Errorf("templates/service.yaml has CRLF line endings:\n%q", firstChart["templates/service.yaml"])
+	}
+	if want := "debug: false\nenv: prod\nowner: team\ntier: web\nzone: b\n"; string(firstValues) != want {
+		t.Errorf("got values file\n%s\nwant sorted keys\n%s", firstValues, want)
+	}
+	chartYAML := firstChart["Chart.yaml"]
+	if a, z := bytes.Index(chartYAML, []byte("a: \"2\"")), bytes.Index(chartYAML, []byte("z: \"1\"")); a == -1 || z == -1 || a > z {
+		t.Errorf("Chart.yaml annotations are not sorted:\n%s", chartYAML)
+	}
+}
@@ -0,0 +1,45 @@
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// copyChartDir copies the unpacked chart in srcDir to dstDir, keeping file modes.
+// The temp dir the chart is unpacked to may be on another filesystem than the output dir, so it can't be renamed.
+func copyChartDir(srcDir string, dstDir string) error {
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		dstPath := filepath.Join(dstDir, relPath)
+
+		if info.IsDir() {
+			if err := os.MkdirAll(dstPath, 0755); err != nil {
+				return errors.Wrapf(err, "create dir %s", dstPath)
+			}
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "read %s", relPath)
+		}
+		if err := ioutil.WriteFile(dstPath, data, info.Mode().Perm()); err != nil {
+			return errors.Wrapf(err, "write %s", relPath)
+		}
+		// WriteFile modes are subject to the umask
+		if err := os.Chmod(dstPath, info.Mode().Perm()); err != nil {
+			return errors.Wrapf(err, "set mode of %s", relPath)
+		}
+		return nil
+	})
+}
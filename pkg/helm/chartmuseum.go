@@ -0,0 +1,65 @@
+package helm
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type ChartMuseumOptions struct {
+	URL      string
+	Username string
+	Password string
+	// Force overwrites a chart version that already exists on the server.
+	Force bool
+}
+
+// UploadToChartMuseum uploads a packaged chart using the ChartMuseum POST /api/charts endpoint
+// and returns the server's response body.
+func UploadToChartMuseum(chartFile string, opts ChartMuseumOptions) (string, error) {
+	data, err := ioutil.ReadFile(chartFile)
+	if err != nil {
+		return "", errors.Wrap(err, "read chart file")
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(opts.URL, "/") + "/api/charts")
+	if err != nil {
+		return "", errors.Wrap(err, "parse chartmuseum url")
+	}
+	if opts.Force {
+		u.RawQuery = "force"
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return "", errors.Wrap(err, "create request")
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	if opts.Username != "" || opts.Password != "" {
+		req.SetBasicAuth(opts.Username, opts.Password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "send request")
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "read response")
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusConflict:
+		return "", errors.Errorf("chart already exists on %s, use --chartmuseum-force to overwrite it: %s", opts.URL, strings.TrimSpace(string(body)))
+	case resp.StatusCode >= 300:
+		return "", errors.Errorf("unexpected response %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
@@ -0,0 +1,40 @@
+package helm
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	chooserLock sync.Mutex
+	// releaseChooser picks one of several releases a lookup matched, see SetReleaseChooser.
+	releaseChooser func(question string, choices []string) (int, error)
+)
+
+// SetReleaseChooser makes lookups that match more than one release, such as a release name in several namespaces,
+// ask choose to pick one of choices by index instead of failing. A nil choose, the default, fails with the list.
+func SetReleaseChooser(choose func(question string, choices []string) (int, error)) {
+	chooserLock.Lock()
+	defer chooserLock.Unlock()
+	releaseChooser = choose
+}
+
+// chooseRelease returns the index of the choice the release chooser picked, or false if there is no chooser.
+// Lookups running concurrently ask one at a time.
+func chooseRelease(question string, choices []string) (int, bool, error) {
+	chooserLock.Lock()
+	defer chooserLock.Unlock()
+	if releaseChooser == nil {
+		return 0, false, nil
+	}
+
+	i, err := releaseChooser(question, choices)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "choose release")
+	}
+	if i < 0 || i >= len(choices) {
+		return 0, false, errors.Errorf("invalid choice %d", i)
+	}
+	return i, true, nil
+}
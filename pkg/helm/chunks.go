@@ -0,0 +1,41 @@
+package helm
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// releaseChunks reassembles release data that some operators split across numbered keys, e.g. release-0,
+// release-1, when it is too large for one key. It returns nil if data has no chunk keys.
+func releaseChunks(data map[string][]byte, releaseKey string) ([]byte, error) {
+	prefix := releaseKey + "-"
+	indexes := []int{}
+	for key := range data {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimPrefix(key, prefix))
+		if err != nil || index < 0 {
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	if len(indexes) == 0 {
+		return nil, nil
+	}
+	sort.Ints(indexes)
+
+	var joined bytes.Buffer
+	for i, index := range indexes {
+		if index != i {
+			return nil, errors.Errorf("release data chunk %s%d is missing", prefix, i)
+		}
+		joined.Write(data[prefix+strconv.Itoa(index)])
+	}
+	debugf("reassembled release data from %d chunks", len(indexes))
+	return joined.Bytes(), nil
+}
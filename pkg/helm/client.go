@@ -1,12 +1,20 @@
 package helm
 
 import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/pkg/errors"
 	flag "github.com/spf13/pflag"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 const (
@@ -14,18 +22,156 @@ const (
 	DEFAULT_K8S_CLIENT_BURST = 100
 )
 
-var kubernetesConfigFlags *genericclioptions.ConfigFlags
+// Client reads releases from a cluster, or from a database with the sql storage driver. Its fields hold the
+// cluster and storage settings of the release2chart command flags, see AddFlags. Create it with NewClient,
+// which sets the defaults, and don't change it once it is used.
+type Client struct {
+	// ConfigFlags select the kubeconfig, context, namespace and credentials like the kubectl flags do.
+	ConfigFlags *genericclioptions.ConfigFlags
+	// InCluster forces the in-cluster service account config.
+	InCluster bool
+	// Clientset, if set, is used for all Kubernetes API calls instead of a clientset for ConfigFlags, e.g. a fake
+	// clientset in tests. Storage objects are then listed with their data, without a metadata client.
+	Clientset kubernetes.Interface
+	// DynamicClient, if set, is used instead of a dynamic client for ConfigFlags.
+	DynamicClient dynamic.Interface
+
+	// Storage is the Helm storage driver the releases were written with: StorageSecret, StorageConfigMap or StorageSQL.
+	Storage string
+	// SQLConnectionString is the PostgreSQL DSN of the sql storage driver.
+	SQLConnectionString string
+	// ReleaseKey is the secret data key that holds the release. Helm uses "release", but some forks store
+	// it under a different key.
+	ReleaseKey string
+	// Owner is the owner label of release objects. Empty matches objects with any owner.
+	Owner string
+	// Selector is a label selector release objects must match in addition to the owner and name labels.
+	Selector string
+	// Labels are key=value labels release objects must have.
+	Labels []string
+	// Strict fails lookups of a revision that more than one storage object holds.
+	Strict bool
+
+	// MaxRetries is how many times a Kubernetes API call that failed with a transient error is retried.
+	MaxRetries int
+	// RetryDelay is the delay before the first retry. It doubles with every retry.
+	RetryDelay time.Duration
+
+	// config and clientset are created once, so the kubeconfig is read and auth plugins are set up once and
+	// token refreshes are shared.
+	lock      sync.Mutex
+	config    *rest.Config
+	clientset kubernetes.Interface
+
+	storageLock sync.Mutex
+	storage     map[string]releaseStorage
+}
+
+// NewClient returns a client with the defaults of the release2chart command. Like helm, the namespace defaults
+// to HELM_NAMESPACE, the storage driver to HELM_DRIVER and the SQL connection string to
+// HELM_DRIVER_SQL_CONNECTION_STRING.
+func NewClient() *Client {
+	configFlags := genericclioptions.NewConfigFlags(false)
+	*configFlags.Namespace = os.Getenv("HELM_NAMESPACE")
+
+	return &Client{
+		ConfigFlags:         configFlags,
+		Storage:             storageDriverFromEnv(),
+		SQLConnectionString: os.Getenv("HELM_DRIVER_SQL_CONNECTION_STRING"),
+		ReleaseKey:          "release",
+		Owner:               "helm",
+		MaxRetries:          3,
+		RetryDelay:          500 * time.Millisecond,
+	}
+}
+
+// AddFlags binds the settings of c to flags.
+func (c *Client) AddFlags(flags *flag.FlagSet) {
+	c.ConfigFlags.AddFlags(flags)
+	// --kubeconfig comes from the kube flags, --kube-context is the name helm uses for --context
+	flags.StringVar(c.ConfigFlags.Context, "kube-context", "", "name of the kubeconfig context to use, same as --context")
+	flags.BoolVar(&c.InCluster, "in-cluster", c.InCluster, "use the service account of the pod instead of a kubeconfig, the default when running in a pod without --kubeconfig")
+	flags.StringVar(&c.ReleaseKey, "release-key", c.ReleaseKey, "secret data key that holds the release")
+	flags.BoolVar(&c.Strict, "strict", c.Strict, "fail if more than one secret or configmap holds the same revision instead of using the newest one")
+	flags.IntVar(&c.MaxRetries, "max-retries", c.MaxRetries, "how many times Kubernetes API calls that fail with timeouts, throttling or other server errors are retried")
+	flags.DurationVar(&c.RetryDelay, "retry-delay", c.RetryDelay, "delay before the first retry of a Kubernetes API call, doubled with every retry")
+	flags.StringVar(&c.Owner, "owner", c.Owner, "owner label of the release objects, empty to match any owner")
+	flags.StringVar(&c.Selector, "selector", c.Selector, "additional label selector release objects must match, e.g. team=payments")
+	flags.StringArrayVar(&c.Labels, "label", c.Labels, "key=value label release objects must have, e.g. team=payments, can be repeated")
+	flags.StringVar(&c.Storage, "storage", c.Storage, "Helm storage driver the releases were written with: secret, configmap or sql, defaults to HELM_DRIVER")
+	flags.BoolVarP(&verbose, "verbose", "v", verbose, "print debug messages about the label selectors, matched objects, selected revision, temp dirs and packaging to stderr")
+	flags.StringVar(&c.SQLConnectionString, "sql-dsn", c.SQLConnectionString, "PostgreSQL connection string of the sql storage driver, defaults to HELM_DRIVER_SQL_CONNECTION_STRING")
+}
+
+// ForCluster returns a client with the settings of c that connects with kubeconfig and context instead.
+// Empty values keep the ones of c.
+func (c *Client) ForCluster(kubeconfig string, context string) *Client {
+	configFlags := copyConfigFlags(c.ConfigFlags)
+	if kubeconfig != "" {
+		configFlags.KubeConfig = &kubeconfig
+	}
+	if context != "" {
+		configFlags.Context = &context
+	}
 
-func init() {
-	kubernetesConfigFlags = genericclioptions.NewConfigFlags(false)
+	return &Client{
+		ConfigFlags:         configFlags,
+		InCluster:           c.InCluster,
+		Storage:             c.Storage,
+		SQLConnectionString: c.SQLConnectionString,
+		ReleaseKey:          c.ReleaseKey,
+		Owner:               c.Owner,
+		Selector:            c.Selector,
+		Labels:              c.Labels,
+		Strict:              c.Strict,
+		MaxRetries:          c.MaxRetries,
+		RetryDelay:          c.RetryDelay,
+	}
 }
 
-func AddFlags(flags *flag.FlagSet) {
-	kubernetesConfigFlags.AddFlags(flags)
+// copyConfigFlags returns config flags with the settings of flags. ConfigFlags caches clients and can't be
+// copied as a whole.
+func copyConfigFlags(flags *genericclioptions.ConfigFlags) *genericclioptions.ConfigFlags {
+	return &genericclioptions.ConfigFlags{
+		CacheDir:           flags.CacheDir,
+		KubeConfig:         flags.KubeConfig,
+		ClusterName:        flags.ClusterName,
+		AuthInfoName:       flags.AuthInfoName,
+		Context:            flags.Context,
+		Namespace:          flags.Namespace,
+		APIServer:          flags.APIServer,
+		TLSServerName:      flags.TLSServerName,
+		Insecure:           flags.Insecure,
+		CertFile:           flags.CertFile,
+		KeyFile:            flags.KeyFile,
+		CAFile:             flags.CAFile,
+		BearerToken:        flags.BearerToken,
+		Impersonate:        flags.Impersonate,
+		ImpersonateUID:     flags.ImpersonateUID,
+		ImpersonateGroup:   flags.ImpersonateGroup,
+		Username:           flags.Username,
+		Password:           flags.Password,
+		Timeout:            flags.Timeout,
+		DisableCompression: flags.DisableCompression,
+		WrapConfigFn:       flags.WrapConfigFn,
+	}
 }
 
-func GetClientset() (*kubernetes.Clientset, error) {
-	cfg, err := GetClusterConfig()
+// GetClientset returns the clientset of the configured cluster, or Clientset if it is set. It is created once
+// and shared by all callers.
+func (c *Client) GetClientset() (kubernetes.Interface, error) {
+	if c.Clientset != nil {
+		return c.Clientset, nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.clientset != nil {
+		return c.clientset, nil
+	}
+
+	cfg, err := c.cachedClusterConfig()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get cluster config")
 	}
@@ -35,33 +181,182 @@ func GetClientset() (*kubernetes.Clientset, error) {
 		return nil, errors.Wrap(err, "failed to create kubernetes clientset")
 	}
 
+	c.clientset = clientset
 	return clientset, nil
 }
 
-func GetClusterConfig() (*rest.Config, error) {
-	var cfg *rest.Config
-	var err error
+// GetDynamicClient returns DynamicClient if it is set, or a dynamic client of the configured cluster.
+func (c *Client) GetDynamicClient() (dynamic.Interface, error) {
+	if c.DynamicClient != nil {
+		return c.DynamicClient, nil
+	}
 
-	if kubernetesConfigFlags != nil {
-		cfg, err = kubernetesConfigFlags.ToRESTConfig()
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to convert kube flags to rest config")
+	cfg, err := c.GetClusterConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "get cluster config")
+	}
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "create dynamic client")
+	}
+	return dynamicClient, nil
+}
+
+// GetClusterConfig returns the REST config of the configured cluster. The config is loaded once,
+// callers get a copy they can modify.
+func (c *Client) GetClusterConfig() (*rest.Config, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	cfg, err := c.cachedClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	return rest.CopyConfig(cfg), nil
+}
+
+// cachedClusterConfig returns the REST config, loading it if needed. c.lock must be held.
+func (c *Client) cachedClusterConfig() (*rest.Config, error) {
+	if c.config != nil {
+		return c.config, nil
+	}
+
+	cfg, err := c.clusterConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.QPS = DEFAULT_K8S_CLIENT_QPS
+	cfg.Burst = DEFAULT_K8S_CLIENT_BURST
+
+	c.config = cfg
+	return cfg, nil
+}
+
+func (c *Client) clusterConfig() (*rest.Config, error) {
+	if c.InCluster {
+		if *c.ConfigFlags.KubeConfig != "" || *c.ConfigFlags.Context != "" {
+			return nil, errors.New("--in-cluster can't be combined with --kubeconfig or --kube-context")
 		}
-	} else {
-		cfg, err = config.GetConfig()
+		cfg, err := rest.InClusterConfig()
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to get config")
+			return nil, errors.Wrap(err, "failed to get in-cluster config")
+		}
+		if err := c.applyConfigFlags(cfg); err != nil {
+			return nil, err
 		}
+		return cfg, nil
 	}
 
-	cfg.QPS = DEFAULT_K8S_CLIENT_QPS
-	cfg.Burst = DEFAULT_K8S_CLIENT_BURST
+	if c.runningInCluster() {
+		// a kubeconfig mounted into the pod may be stale, fall back to it only without a service account
+		if cfg, err := rest.InClusterConfig(); err == nil {
+			if err := c.applyConfigFlags(cfg); err != nil {
+				return nil, err
+			}
+			return cfg, nil
+		}
+	}
 
+	if err := c.checkKubeContext(); err != nil {
+		return nil, err
+	}
+	cfg, err := c.ConfigFlags.ToRESTConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to convert kube flags to rest config")
+	}
 	return cfg, nil
 }
 
-func GetK8sVersion() (string, error) {
-	clientset, err := GetClientset()
+// applyConfigFlags applies the kube flags that don't come from a kubeconfig, such as --as, --token and
+// --request-timeout, to the in-cluster config. ToRESTConfig applies them to kubeconfig configs.
+func (c *Client) applyConfigFlags(cfg *rest.Config) error {
+	if *c.ConfigFlags.BearerToken != "" {
+		cfg.BearerToken = *c.ConfigFlags.BearerToken
+		cfg.BearerTokenFile = ""
+	}
+	if *c.ConfigFlags.Impersonate != "" {
+		cfg.Impersonate.UserName = *c.ConfigFlags.Impersonate
+	}
+	if *c.ConfigFlags.ImpersonateUID != "" {
+		cfg.Impersonate.UID = *c.ConfigFlags.ImpersonateUID
+	}
+	if len(*c.ConfigFlags.ImpersonateGroup) > 0 {
+		cfg.Impersonate.Groups = *c.ConfigFlags.ImpersonateGroup
+	}
+	if *c.ConfigFlags.Timeout != "" {
+		timeout, err := clientcmd.ParseTimeout(*c.ConfigFlags.Timeout)
+		if err != nil {
+			return errors.Wrap(err, "parse request timeout")
+		}
+		cfg.Timeout = timeout
+	}
+	if *c.ConfigFlags.DisableCompression {
+		cfg.DisableCompression = true
+	}
+	return nil
+}
+
+// serviceAccountNamespaceFile holds the namespace of the pod's service account.
+const serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// CurrentNamespace returns --namespace if it is set. Otherwise it returns the namespace of the current kubeconfig
+// context, or of the service account when the in-cluster config is used, like kubectl does, and "default" if neither sets one.
+func (c *Client) CurrentNamespace() (string, error) {
+	if *c.ConfigFlags.Namespace != "" {
+		return *c.ConfigFlags.Namespace, nil
+	}
+
+	if c.InCluster || c.runningInCluster() {
+		if data, err := ioutil.ReadFile(serviceAccountNamespaceFile); err == nil && strings.TrimSpace(string(data)) != "" {
+			return strings.TrimSpace(string(data)), nil
+		}
+		if c.InCluster {
+			return "default", nil
+		}
+	}
+
+	namespace, _, err := c.ConfigFlags.ToRawKubeConfigLoader().Namespace()
+	if err != nil {
+		return "", errors.Wrap(err, "get namespace of kube context")
+	}
+	return namespace, nil
+}
+
+// runningInCluster reports whether the in-cluster config should be tried first: the process runs in a pod
+// and no kubeconfig or context was selected.
+func (c *Client) runningInCluster() bool {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" || os.Getenv("KUBECONFIG") != "" {
+		return false
+	}
+	return *c.ConfigFlags.KubeConfig == "" && *c.ConfigFlags.Context == ""
+}
+
+// checkKubeContext returns an error listing the available contexts if the selected context is not in the kubeconfig.
+func (c *Client) checkKubeContext() error {
+	context := *c.ConfigFlags.Context
+	if context == "" {
+		return nil
+	}
+
+	rawConfig, err := c.ConfigFlags.ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return errors.Wrap(err, "failed to load kubeconfig")
+	}
+	if _, ok := rawConfig.Contexts[context]; ok {
+		return nil
+	}
+
+	contexts := []string{}
+	for name := range rawConfig.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	return errors.Errorf("context %q not found in kubeconfig, available contexts: %s", context, strings.Join(contexts, ", "))
+}
+
+func (c *Client) GetK8sVersion() (string, error) {
+	clientset, err := c.GetClientset()
 	if err != nil {
 		return "", errors.Wrap(err, "failed to create kubernetes clientset")
 	}
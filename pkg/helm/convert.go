@@ -0,0 +1,48 @@
+package helm
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// ReleaseTarget selects the release revision Convert converts.
+type ReleaseTarget struct {
+	Namespace string
+	Name      string
+	// Revision is the revision to convert. 0 selects the latest revision with Status, and a negative value
+	// counts back from it, so -1 is the revision before the latest.
+	Revision int
+	// Status limits the latest revision to revisions with this status. Empty considers every revision.
+	Status helmrelease.Status
+	// IncludePending considers pending revisions along with those matching Status, see FindLatestReleaseVersion.
+	IncludePending bool
+}
+
+// ResolveRevision returns the revision target selects.
+func (c *Client) ResolveRevision(ctx context.Context, target ReleaseTarget) (int, error) {
+	if target.Revision > 0 {
+		return target.Revision, nil
+	}
+
+	latest, err := c.FindLatestReleaseVersion(ctx, target.Namespace, target.Name, target.Status, target.IncludePending)
+	if err != nil {
+		return 0, errors.Wrap(err, "find latest revision")
+	}
+	if latest+target.Revision < 1 {
+		return 0, errors.Errorf("revision %d is before the first revision, the latest revision is %d", target.Revision, latest)
+	}
+	return latest + target.Revision, nil
+}
+
+// Convert resolves the revision target selects and converts it, like the release2chart command does for
+// a single release. It needs no CLI flags, only the cluster and storage settings of c.
+func (c *Client) Convert(ctx context.Context, target ReleaseTarget, opts ConvertOptions) (*ConversionResult, error) {
+	revision, err := c.ResolveRevision(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.ConvertReleaseVersion(ctx, target.Namespace, target.Name, revision, opts)
+}
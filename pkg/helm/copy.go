@@ -0,0 +1,98 @@
+package helm
+
+import (
+	"helm.sh/helm/v3/pkg/chart"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// copyRelease deep copies the chart and values of release, which the convert options modify, so the caller's
+// release is left as it was decoded. Manifest, info and hooks are only read and shared with release.
+func copyRelease(release *helmrelease.Release) *helmrelease.Release {
+	copied := *release
+	if release.Config != nil {
+		copied.Config = copyValues(release.Config)
+	}
+	if release.Chart != nil {
+		copied.Chart = copyChart(release.Chart)
+	}
+	return &copied
+}
+
+// copyChart deep copies c and its dependencies. The data of files is shared, it is replaced rather than
+// modified in place.
+func copyChart(c *chart.Chart) *chart.Chart {
+	copied := &chart.Chart{
+		Raw:       copyFiles(c.Raw),
+		Metadata:  copyMetadata(c.Metadata),
+		Lock:      c.Lock,
+		Templates: copyFiles(c.Templates),
+		Schema:    c.Schema,
+		Files:     copyFiles(c.Files),
+	}
+	if c.Values != nil {
+		copied.Values = copyValues(c.Values)
+	}
+	for _, dependency := range c.Dependencies() {
+		copied.AddDependency(copyChart(dependency))
+	}
+	return copied
+}
+
+func copyFiles(files []*chart.File) []*chart.File {
+	if files == nil {
+		return nil
+	}
+	copied := make([]*chart.File, len(files))
+	for i, file := range files {
+		if file == nil {
+			continue
+		}
+		f := *file
+		copied[i] = &f
+	}
+	return copied
+}
+
+func copyMetadata(metadata *chart.Metadata) *chart.Metadata {
+	if metadata == nil {
+		return nil
+	}
+	copied := *metadata
+	copied.Sources = copyStrings(metadata.Sources)
+	copied.Keywords = copyStrings(metadata.Keywords)
+	if metadata.Maintainers != nil {
+		copied.Maintainers = make([]*chart.Maintainer, len(metadata.Maintainers))
+		for i, maintainer := range metadata.Maintainers {
+			if maintainer == nil {
+				continue
+			}
+			m := *maintainer
+			copied.Maintainers[i] = &m
+		}
+	}
+	if metadata.Dependencies != nil {
+		copied.Dependencies = make([]*chart.Dependency, len(metadata.Dependencies))
+		for i, dependency := range metadata.Dependencies {
+			if dependency == nil {
+				continue
+			}
+			d := *dependency
+			d.Tags = copyStrings(dependency.Tags)
+			copied.Dependencies[i] = &d
+		}
+	}
+	if metadata.Annotations != nil {
+		copied.Annotations = make(map[string]string, len(metadata.Annotations))
+		for key, value := range metadata.Annotations {
+			copied.Annotations[key] = value
+		}
+	}
+	return &copied
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
+}
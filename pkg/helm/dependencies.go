@@ -0,0 +1,69 @@
+package helm
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+// rebuildDependencies replaces the bundled subcharts of the unpacked chart in chartDir
+// with fresh copies from their repositories, like `helm dependency build`.
+func rebuildDependencies(chartDir string) error {
+	if err := os.RemoveAll(filepath.Join(chartDir, "charts")); err != nil {
+		return errors.Wrap(err, "remove bundled dependencies")
+	}
+
+	manager, err := dependencyManager(chartDir)
+	if err != nil {
+		return errors.Wrap(err, "create dependency manager")
+	}
+
+	if err := manager.Build(); err != nil {
+		return errors.Wrap(err, "build dependencies")
+	}
+
+	return nil
+}
+
+// updateDependencies resolves the chart dependencies in chartDir again and downloads them
+// into charts/, like `helm package --dependency-update`.
+func updateDependencies(chartDir string) error {
+	manager, err := dependencyManager(chartDir)
+	if err != nil {
+		return errors.Wrap(err, "create dependency manager")
+	}
+
+	if err := manager.Update(); err != nil {
+		return errors.Wrap(err, "update dependencies")
+	}
+
+	return nil
+}
+
+func dependencyManager(chartDir string) (*downloader.Manager, error) {
+	settings := cli.New()
+
+	registryClient, err := registry.NewClient(
+		registry.ClientOptCredentialsFile(settings.RegistryConfig),
+		registry.ClientOptWriter(os.Stderr),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "create registry client")
+	}
+
+	manager := &downloader.Manager{
+		Out:              os.Stderr,
+		ChartPath:        chartDir,
+		Getters:          getter.All(settings),
+		RegistryClient:   registryClient,
+		RepositoryConfig: settings.RepositoryConfig,
+		RepositoryCache:  settings.RepositoryCache,
+	}
+
+	return manager, nil
+}
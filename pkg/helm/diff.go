@@ -0,0 +1,92 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+const (
+	DiffPartValues   = "values"
+	DiffPartManifest = "manifest"
+)
+
+// DiffRevisions returns a unified diff of the user supplied values and the deployed manifest of two revisions of the release.
+// If only is DiffPartValues or DiffPartManifest, only that part is compared. The diff is empty if the revisions don't differ.
+func (c *Client) DiffRevisions(ctx context.Context, namespace string, releaseName string, from int, to int, only string) (string, error) {
+	if only != "" && only != DiffPartValues && only != DiffPartManifest {
+		return "", errors.Errorf("unsupported diff part %q, expected %s or %s", only, DiffPartValues, DiffPartManifest)
+	}
+
+	fromRelease, err := c.GetRelease(ctx, namespace, releaseName, from)
+	if err != nil {
+		return "", errors.Wrapf(err, "get revision %d", from)
+	}
+	toRelease, err := c.GetRelease(ctx, namespace, releaseName, to)
+	if err != nil {
+		return "", errors.Wrapf(err, "get revision %d", to)
+	}
+
+	diff := ""
+	if only != DiffPartManifest {
+		fromValues, err := diffableValues(fromRelease)
+		if err != nil {
+			return "", errors.Wrapf(err, "marshal values of revision %d", from)
+		}
+		toValues, err := diffableValues(toRelease)
+		if err != nil {
+			return "", errors.Wrapf(err, "marshal values of revision %d", to)
+		}
+		valuesDiff, err := unifiedDiff("values.yaml", from, to, fromValues, toValues)
+		if err != nil {
+			return "", errors.Wrap(err, "diff values")
+		}
+		diff += valuesDiff
+	}
+
+	if only != DiffPartValues {
+		manifestDiff, err := unifiedDiff("manifest.yaml", from, to, fromRelease.Manifest, toRelease.Manifest)
+		if err != nil {
+			return "", errors.Wrap(err, "diff manifest")
+		}
+		diff += manifestDiff
+	}
+
+	return diff, nil
+}
+
+// diffableValues returns the user supplied values of the release as YAML with sorted keys.
+func diffableValues(release *helmrelease.Release) (string, error) {
+	if len(release.Config) == 0 {
+		return "", nil
+	}
+	data, err := yaml.Marshal(release.Config)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func unifiedDiff(fileName string, from int, to int, fromText string, toText string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        diffLines(fromText),
+		B:        diffLines(toText),
+		FromFile: fmt.Sprintf("a/%s (revision %d)", fileName, from),
+		ToFile:   fmt.Sprintf("b/%s (revision %d)", fileName, to),
+		Context:  3,
+	})
+}
+
+// diffLines splits text into lines that all end with a newline, so a missing final newline isn't reported as a change.
+func diffLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(text, "\n")+"\n", "\n")
+	return lines[:len(lines)-1]
+}
@@ -0,0 +1,204 @@
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+const (
+	DriftInSync  = "in sync"
+	DriftChanged = "drifted"
+	DriftMissing = "missing"
+)
+
+type ResourceDrift struct {
+	Resource string
+	Status   string
+	Changes  []ValueDiff
+}
+
+// ignoredLiveFields are set by the API server and never part of a stored manifest.
+var ignoredLiveFields = []string{
+	"metadata.managedFields",
+	"metadata.resourceVersion",
+	"metadata.uid",
+	"metadata.generation",
+	"metadata.creationTimestamp",
+	"metadata.selfLink",
+	"status",
+}
+
+// CompareWithCluster fetches the live objects of every resource in the release manifest and reports
+// fields that differ from the stored manifest. Only fields present in the manifest are compared,
+// so defaults filled in by the API server are not reported as drift.
+func (c *Client) CompareWithCluster(ctx context.Context, namespace string, releaseName string, revision int) ([]ResourceDrift, error) {
+	helmRelease, err := c.GetRelease(ctx, namespace, releaseName, revision)
+	if err != nil {
+		return nil, errors.Wrap(err, "get release")
+	}
+
+	return c.compareReleaseWithCluster(ctx, helmRelease)
+}
+
+func (c *Client) compareReleaseWithCluster(ctx context.Context, helmRelease *helmrelease.Release) ([]ResourceDrift, error) {
+	clientSet, err := c.GetClientset()
+	if err != nil {
+		return nil, errors.Wrap(err, "get clientset")
+	}
+
+	dynamicClient, err := c.GetDynamicClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "get dynamic client")
+	}
+
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientSet.Discovery()))
+
+	objects, err := manifestObjects(helmRelease)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse manifest")
+	}
+
+	drifts := []ResourceDrift{}
+	for _, obj := range objects {
+		drift, err := resourceDrift(ctx, dynamicClient, mapper, obj, helmRelease.Namespace)
+		if err != nil {
+			return nil, errors.Wrapf(err, "compare %s", resourceID(obj))
+		}
+		drifts = append(drifts, drift)
+	}
+
+	return drifts, nil
+}
+
+func manifestObjects(release *helmrelease.Release) ([]*unstructured.Unstructured, error) {
+	docs := releaseutil.SplitManifests(release.Manifest)
+	keys := []string{}
+	for key := range docs {
+		keys = append(keys, key)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	objects := []*unstructured.Unstructured{}
+	for _, key := range keys {
+		data, err := k8syaml.ToJSON([]byte(docs[key]))
+		if err != nil {
+			return nil, errors.Wrap(err, "convert document to json")
+		}
+
+		obj := map[string]interface{}{}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return nil, errors.Wrap(err, "unmarshal document")
+		}
+		if obj["kind"] == nil {
+			continue
+		}
+
+		objects = append(objects, &unstructured.Unstructured{Object: obj})
+	}
+
+	return objects, nil
+}
+
+func resourceDrift(ctx context.Context, client dynamic.Interface, mapper meta.RESTMapper, obj *unstructured.Unstructured, defaultNamespace string) (ResourceDrift, error) {
+	drift := ResourceDrift{
+		Resource: resourceID(obj),
+	}
+
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(schema.GroupKind{Group: gvk.Group, Kind: gvk.Kind}, gvk.Version)
+	if err != nil {
+		return drift, errors.Wrap(err, "map resource kind")
+	}
+
+	var resourceClient dynamic.ResourceInterface = client.Resource(mapping.Resource)
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespace := obj.GetNamespace()
+		if namespace == "" {
+			namespace = defaultNamespace
+		}
+		resourceClient = client.Resource(mapping.Resource).Namespace(namespace)
+	}
+
+	live, err := resourceClient.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		drift.Status = DriftMissing
+		return drift, nil
+	} else if err != nil {
+		return drift, errors.Wrap(err, "get live object")
+	}
+
+	liveObject, err := normalizeObject(live.Object)
+	if err != nil {
+		return drift, errors.Wrap(err, "normalize live object")
+	}
+	storedObject, err := normalizeObject(obj.Object)
+	if err != nil {
+		return drift, errors.Wrap(err, "normalize stored object")
+	}
+
+	liveLeaves := flattenValues(liveObject)
+	for path, storedValue := range flattenValues(storedObject) {
+		liveValue, ok := liveLeaves[path]
+		if !ok {
+			drift.Changes = append(drift.Changes, ValueDiff{Path: path, Change: ValueRemoved, Old: storedValue})
+		} else if !reflect.DeepEqual(storedValue, liveValue) {
+			drift.Changes = append(drift.Changes, ValueDiff{Path: path, Change: ValueChanged, Old: storedValue, New: liveValue})
+		}
+	}
+	sort.Slice(drift.Changes, func(i, j int) bool {
+		return drift.Changes[i].Path < drift.Changes[j].Path
+	})
+
+	drift.Status = DriftInSync
+	if len(drift.Changes) > 0 {
+		drift.Status = DriftChanged
+	}
+
+	return drift, nil
+}
+
+// normalizeObject drops server managed fields and converts numbers to the same types
+// regardless of whether the object came from YAML or the API server.
+func normalizeObject(obj map[string]interface{}) (map[string]interface{}, error) {
+	obj = runtime.DeepCopyJSON(obj)
+	for _, field := range ignoredLiveFields {
+		unstructured.RemoveNestedField(obj, strings.Split(field, ".")...)
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := map[string]interface{}{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+
+	return normalized, nil
+}
+
+func resourceID(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() != "" {
+		return fmt.Sprintf("%s/%s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+	}
+	return fmt.Sprintf("%s/%s", obj.GetKind(), obj.GetName())
+}
@@ -0,0 +1,60 @@
+package helm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+const redactedValue = "***"
+
+// dumpRelease writes the decoded release as indented JSON. If redact is set, string values in the
+// release config are replaced in the dump.
+func dumpRelease(release *helmrelease.Release, fileName string, redact bool, mode os.FileMode) error {
+	dumped := *release
+	if redact {
+		dumped.Config = redactValues(release.Config)
+	}
+
+	data, err := json.MarshalIndent(&dumped, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "marshal release")
+	}
+
+	if err := ioutil.WriteFile(fileName, data, mode); err != nil {
+		return errors.Wrap(err, "write release dump")
+	}
+
+	return nil
+}
+
+func redactValues(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+	redacted := map[string]interface{}{}
+	for key, value := range values {
+		redacted[key] = redactValue(value)
+	}
+	return redacted
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return redactValues(v)
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = redactValue(item)
+		}
+		return redacted
+	case string:
+		return redactedValue
+	default:
+		return v
+	}
+}
@@ -0,0 +1,66 @@
+package helm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EncodeRelease encodes a release the way Helm stores it under the release key of a secret: JSON, gzipped
+// and base64 encoded. DecodeRelease reverses it.
+func EncodeRelease(release *helmrelease.Release) ([]byte, error) {
+	data, err := json.Marshal(release)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal release")
+	}
+
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	if _, err := gzipWriter.Write(data); err != nil {
+		return nil, errors.Wrap(err, "compress release")
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, errors.Wrap(err, "close gzip writer")
+	}
+
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(compressed.Len()))
+	base64.StdEncoding.Encode(encoded, compressed.Bytes())
+	return encoded, nil
+}
+
+// ReleaseSecret returns the secret Helm's secret storage driver keeps the release in, with the same name,
+// labels and type. It is meant to seed fake clientsets set as Client.Clientset.
+func ReleaseSecret(release *helmrelease.Release) (*corev1.Secret, error) {
+	data, err := EncodeRelease(release)
+	if err != nil {
+		return nil, err
+	}
+
+	status := ""
+	if release.Info != nil {
+		status = release.Info.Status.String()
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("sh.helm.release.v1.%s.v%d", release.Name, release.Version),
+			Namespace: release.Namespace,
+			Labels: map[string]string{
+				"owner":   "helm",
+				"name":    release.Name,
+				"status":  status,
+				"version": strconv.Itoa(release.Version),
+			},
+		},
+		Type: "helm.sh/release.v1",
+		Data: map[string][]byte{"release": data},
+	}, nil
+}
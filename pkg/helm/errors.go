@@ -0,0 +1,114 @@
+package helm
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var (
+	// ErrReleaseNotFound matches errors of lookups that found no release with errors.Is.
+	ErrReleaseNotFound = errors.New("release not found")
+	// ErrRevisionNotFound matches errors of lookups that found the release, but not the requested revision.
+	ErrRevisionNotFound = errors.New("revision not found")
+)
+
+// notFoundError keeps the message of a failed lookup while matching ErrReleaseNotFound or ErrRevisionNotFound.
+type notFoundError struct {
+	message string
+	target  error
+}
+
+func (e *notFoundError) Error() string {
+	return e.message
+}
+
+func (e *notFoundError) Is(target error) bool {
+	return target == e.target
+}
+
+func releaseNotFoundf(format string, args ...interface{}) error {
+	return &notFoundError{message: fmt.Sprintf(format, args...), target: ErrReleaseNotFound}
+}
+
+func revisionNotFoundf(format string, args ...interface{}) error {
+	return &notFoundError{message: fmt.Sprintf(format, args...), target: ErrRevisionNotFound}
+}
+
+// IsClusterError reports whether err was caused by a missing or invalid cluster config, failed authentication
+// or authorization, or a Kubernetes API server that couldn't be reached or kept failing. Network errors of other
+// servers, e.g. chart repositories, are reported as well.
+func IsClusterError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	// not net.Error, os.PathError implements it as well
+	var urlErr *url.Error
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return isTransientError(err) ||
+		apierrors.IsUnauthorized(err) ||
+		apierrors.IsForbidden(err) ||
+		clientcmd.IsConfigurationInvalid(err) ||
+		clientcmd.IsEmptyConfig(err) ||
+		errors.Is(err, rest.ErrNotInCluster) ||
+		errors.As(err, &urlErr) ||
+		errors.As(err, &opErr) ||
+		errors.As(err, &dnsErr)
+}
+
+// forbiddenMessage matches the message of a Forbidden API error, e.g. User "jane" cannot list resource "secrets"
+// in API group "" in the namespace "prod". The namespace is missing for cluster wide requests.
+var forbiddenMessage = regexp.MustCompile(`cannot (\w+) resource "([^"]+)" in API group "([^"]*)"(?: in the namespace "([^"]*)")?`)
+
+// permissionError is a Forbidden API error with the RBAC rule it asks for.
+type permissionError struct {
+	err  error
+	hint string
+}
+
+func (e *permissionError) Error() string {
+	return e.err.Error() + "\n\n" + e.hint
+}
+
+func (e *permissionError) Unwrap() error {
+	return e.err
+}
+
+// withPermissionHint adds the RBAC rule a Forbidden error asks for and a Role granting it to err.
+// Other errors are returned as is. The result still matches apierrors.IsForbidden.
+func withPermissionHint(err error) error {
+	if !apierrors.IsForbidden(err) {
+		return err
+	}
+	match := forbiddenMessage.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+	verb, resource, group, namespace := match[1], match[2], match[3], match[4]
+
+	role := fmt.Sprintf("kind: Role\nmetadata:\n  name: release2chart\n  namespace: %s", namespace)
+	scope := "namespace " + namespace
+	if namespace == "" {
+		role = "kind: ClusterRole\nmetadata:\n  name: release2chart"
+		scope = "all namespaces"
+	}
+	// releases are listed and the selected one is fetched, so both verbs are needed
+	hint := fmt.Sprintf(`Permission to %s %s in %s is missing. Grant get and list with a role bound to the user or service account:
+
+apiVersion: rbac.authorization.k8s.io/v1
+%s
+rules:
+- apiGroups: [%q]
+  resources: [%q]
+  verbs: ["get", "list"]`, verb, resource, scope, role, group, resource)
+
+	return &permissionError{err: err, hint: hint}
+}
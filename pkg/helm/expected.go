@@ -0,0 +1,88 @@
+package helm
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chartutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const defaultExpectedValuesKey = "values.yaml"
+
+// LoadExpectedValues reads values from a ConfigMap or Secret referenced as configmap/<name>[:key] or secret/<name>[:key].
+// If no key is given, "values.yaml" is used, or the only key in the object.
+func (c *Client) LoadExpectedValues(ctx context.Context, namespace string, ref string) (map[string]interface{}, error) {
+	kind, name, ok := strings.Cut(ref, "/")
+	if !ok || name == "" {
+		return nil, errors.Errorf("expected configmap/<name> or secret/<name>, got %q", ref)
+	}
+	name, key, _ := strings.Cut(name, ":")
+
+	clientSet, err := c.GetClientset()
+	if err != nil {
+		return nil, errors.Wrap(err, "get clientset")
+	}
+
+	data := map[string][]byte{}
+	switch strings.ToLower(kind) {
+	case "configmap", "cm":
+		configMap, err := clientSet.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, "get configmap")
+		}
+		for k, v := range configMap.Data {
+			data[k] = []byte(v)
+		}
+	case "secret":
+		secret, err := clientSet.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, "get secret")
+		}
+		data = secret.Data
+	default:
+		return nil, errors.Errorf("unsupported expected values source %q", kind)
+	}
+
+	valuesData, err := expectedValuesData(data, key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s", ref)
+	}
+
+	values, err := chartutil.ReadValues(valuesData)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse expected values")
+	}
+
+	return values, nil
+}
+
+func expectedValuesData(data map[string][]byte, key string) ([]byte, error) {
+	if key != "" {
+		valuesData, ok := data[key]
+		if !ok {
+			return nil, errors.Errorf("key %q not found", key)
+		}
+		return valuesData, nil
+	}
+
+	if valuesData, ok := data[defaultExpectedValuesKey]; ok {
+		return valuesData, nil
+	}
+
+	if len(data) == 1 {
+		for _, valuesData := range data {
+			return valuesData, nil
+		}
+	}
+
+	keys := []string{}
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return nil, errors.Errorf("key %q not found, specify one of: %s", defaultExpectedValuesKey, strings.Join(keys, ", "))
+}
@@ -0,0 +1,135 @@
+package helm
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const defaultChartFileMode os.FileMode = 0644
+
+// scriptDirs are directories whose files are treated as scripts by ExecutableScripts, in addition to *.sh files.
+var scriptDirs = []string{"scripts", "bin"}
+
+// chartFileMode returns the mode a chart file is written with. Releases don't store file modes.
+func chartFileMode(name string, opts ConvertOptions) os.FileMode {
+	mode := defaultChartFileMode
+	if opts.FileMode != 0 {
+		mode = opts.FileMode
+	}
+	if opts.ExecutableScripts && isScript(name) {
+		// executable wherever the file is readable
+		mode |= (mode & 0444) >> 2
+	}
+	return mode
+}
+
+func isScript(name string) bool {
+	if path.Ext(name) == ".sh" {
+		return true
+	}
+	dirs := strings.Split(path.Dir(name), "/")
+	for _, dir := range dirs {
+		for _, scriptDir := range scriptDirs {
+			if dir == scriptDir {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasCustomFileModes reports whether chart files may be written with modes other than the default.
+// Helm's packager writes every file with the default mode.
+func hasCustomFileModes(opts ConvertOptions) bool {
+	return opts.ExecutableScripts || (opts.FileMode != 0 && opts.FileMode != defaultChartFileMode)
+}
+
+// checkChartFileName rejects release file names that would be written outside the chart dir.
+func checkChartFileName(name string) error {
+	cleanName := path.Clean(name)
+	if name == "" || path.IsAbs(name) || strings.Contains(name, `\`) || cleanName == "." || cleanName == ".." || strings.HasPrefix(cleanName, "../") {
+		return errors.Errorf("invalid chart file name %q", name)
+	}
+	return nil
+}
+
+// checkChartNameAndVersion rejects chart names and versions that would place the chart archive or dir
+// outside the output dir.
+func checkChartNameAndVersion(metadata *chart.Metadata) error {
+	if metadata.Name == "" || metadata.Name == "." || metadata.Name == ".." || strings.ContainsAny(metadata.Name, `/\`) {
+		return errors.Errorf("invalid chart name %q", metadata.Name)
+	}
+	if strings.ContainsAny(metadata.Version, `/\`) {
+		return errors.Errorf("invalid chart version %q", metadata.Version)
+	}
+	return nil
+}
+
+// checkChartVersions validates the SemVer versions of c and its dependencies the way helm package does,
+// naming the chart at fault. Surrounding whitespace, which YAML editing can leave behind, is trimmed first.
+func checkChartVersions(c *chart.Chart, chartPath string) error {
+	c.Metadata.Version = strings.TrimSpace(c.Metadata.Version)
+	if c.Metadata.Version == "" {
+		if chartPath == "" {
+			return errors.Errorf("chart %s has no version, set one with --chart-version", c.Name())
+		}
+		return errors.Errorf("dependency %s at %s has no version", c.Name(), chartPath)
+	}
+	if _, err := semver.NewVersion(c.Metadata.Version); err != nil {
+		if chartPath == "" {
+			return errors.Errorf("chart %s has the invalid SemVer version %q, replace it with --chart-version", c.Name(), c.Metadata.Version)
+		}
+		return errors.Errorf("dependency %s at %s has the invalid SemVer version %q", c.Name(), chartPath, c.Metadata.Version)
+	}
+
+	for _, dependency := range c.Dependencies() {
+		if err := checkChartVersions(dependency, path.Join(chartPath, "charts", dependency.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// safeFileName replaces path separators in a file name made from release data, such as a resource name,
+// so the file can't be written outside its dir.
+func safeFileName(name string) string {
+	return strings.NewReplacer("/", "_", `\`, "_").Replace(name)
+}
+
+// checkPackagedFiles returns an error listing the templates and files of source that are missing from packaged.
+func checkPackagedFiles(packaged *chart.Chart, source *chart.Chart) error {
+	names := map[string]bool{}
+	for _, name := range chartFileNames(packaged) {
+		names[name] = true
+	}
+
+	missing := []string{}
+	for _, name := range chartFileNames(source) {
+		if !names[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	return errors.Errorf("files missing from the packaged chart: %s", strings.Join(missing, ", "))
+}
+
+func chartFileNames(c *chart.Chart) []string {
+	names := []string{}
+	for _, file := range c.Templates {
+		names = append(names, path.Clean(file.Name))
+	}
+	for _, file := range c.Files {
+		names = append(names, path.Clean(file.Name))
+	}
+	return names
+}
@@ -0,0 +1,58 @@
+package helm
+
+import (
+	"path"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// checkFilePatterns validates the --include and --exclude globs, so a typo fails before the release is read.
+func checkFilePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.Wrapf(err, "invalid file pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// filterChartFiles removes the files and templates of c and its dependencies that don't match an include
+// pattern, if there are any, or that match an exclude pattern. Names are relative to the top level chart dir,
+// e.g. charts/redis/templates/svc.yaml. It returns how many files were removed.
+func filterChartFiles(c *chart.Chart, prefix string, include []string, exclude []string) int {
+	removed := 0
+	keep := func(files []*chart.File) []*chart.File {
+		kept := []*chart.File{}
+		for _, file := range files {
+			name := path.Join(prefix, file.Name)
+			if (len(include) > 0 && !matchesFilePattern(include, name)) || matchesFilePattern(exclude, name) {
+				debugf("filtered out %s", name)
+				removed++
+				continue
+			}
+			kept = append(kept, file)
+		}
+		return kept
+	}
+	c.Files = keep(c.Files)
+	c.Templates = keep(c.Templates)
+
+	for _, dependency := range c.Dependencies() {
+		removed += filterChartFiles(dependency, path.Join(prefix, "charts", dependency.Name()), include, exclude)
+	}
+	return removed
+}
+
+// matchesFilePattern reports whether name or one of its parent dirs matches a pattern, so files/* also
+// matches files nested deeper in files/.
+func matchesFilePattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		for p := name; p != "." && p != "/"; p = path.Dir(p) {
+			if matched, _ := path.Match(pattern, p); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
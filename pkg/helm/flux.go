@@ -0,0 +1,98 @@
+package helm
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+)
+
+var fluxHelmReleaseKind = schema.GroupKind{Group: "helm.toolkit.fluxcd.io", Kind: "HelmRelease"}
+
+// ResolveFluxHelmRelease returns the storage namespace and name of the Helm release managed by a Flux HelmRelease.
+func (c *Client) ResolveFluxHelmRelease(ctx context.Context, namespace string, name string) (string, string, error) {
+	clientSet, err := c.GetClientset()
+	if err != nil {
+		return "", "", errors.Wrap(err, "get clientset")
+	}
+
+	dynamicClient, err := c.GetDynamicClient()
+	if err != nil {
+		return "", "", errors.Wrap(err, "get dynamic client")
+	}
+
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientSet.Discovery()))
+	mapping, err := mapper.RESTMapping(fluxHelmReleaseKind)
+	if err != nil {
+		return "", "", errors.Wrap(err, "find HelmRelease API, is Flux installed")
+	}
+
+	helmRelease, err := dynamicClient.Resource(mapping.Resource).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", "", errors.Wrap(err, "get HelmRelease")
+	}
+
+	storageNamespace, releaseName := fluxStorageRelease(helmRelease)
+
+	revisions, err := c.ListReleaseRevisions(ctx, storageNamespace, releaseName)
+	if err != nil {
+		return "", "", errors.Wrap(err, "list release revisions")
+	}
+	if len(revisions) == 0 {
+		message := "no Helm release %s found in namespace %s, the HelmRelease has not been installed yet"
+		if ready := fluxReadyMessage(helmRelease); ready != "" {
+			return "", "", errors.Errorf(message+" (%s)", releaseName, storageNamespace, ready)
+		}
+		return "", "", errors.Errorf(message, releaseName, storageNamespace)
+	}
+
+	return storageNamespace, releaseName, nil
+}
+
+// fluxStorageRelease returns where helm-controller stores the release. Newer Flux versions record it
+// in the status history, older ones are resolved from the spec the same way helm-controller does.
+func fluxStorageRelease(helmRelease *unstructured.Unstructured) (string, string) {
+	history, _, _ := unstructured.NestedSlice(helmRelease.Object, "status", "history")
+	if len(history) > 0 {
+		if latest, ok := history[0].(map[string]interface{}); ok {
+			name, _, _ := unstructured.NestedString(latest, "name")
+			namespace, _, _ := unstructured.NestedString(latest, "namespace")
+			if name != "" && namespace != "" {
+				return namespace, name
+			}
+		}
+	}
+
+	storageNamespace, _, _ := unstructured.NestedString(helmRelease.Object, "spec", "storageNamespace")
+	if storageNamespace == "" {
+		storageNamespace = helmRelease.GetNamespace()
+	}
+
+	releaseName, _, _ := unstructured.NestedString(helmRelease.Object, "spec", "releaseName")
+	if releaseName == "" {
+		releaseName = helmRelease.GetName()
+		if targetNamespace, _, _ := unstructured.NestedString(helmRelease.Object, "spec", "targetNamespace"); targetNamespace != "" {
+			releaseName = strings.Join([]string{targetNamespace, releaseName}, "-")
+		}
+	}
+
+	return storageNamespace, releaseName
+}
+
+func fluxReadyMessage(helmRelease *unstructured.Unstructured) string {
+	conditions, _, _ := unstructured.NestedSlice(helmRelease.Object, "status", "conditions")
+	for _, condition := range conditions {
+		c, ok := condition.(map[string]interface{})
+		if !ok || c["type"] != "Ready" {
+			continue
+		}
+		message, _ := c["message"].(string)
+		return message
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+type ValuesHistoryEntry struct {
+	Revision int         `json:"revision" yaml:"revision"`
+	Changes  []ValueDiff `json:"changes" yaml:"changes"`
+}
+
+// ValuesHistory decodes every revision of the release and returns how the user supplied values
+// changed from each revision to the next. The first revision is compared to empty values.
+// Up to parallelism revisions are fetched and decoded concurrently. Revisions that can't be decoded are skipped.
+func (c *Client) ValuesHistory(ctx context.Context, namespace string, releaseName string, parallelism int) ([]ValuesHistoryEntry, error) {
+	revisions, err := c.ListReleaseRevisions(ctx, namespace, releaseName)
+	if err != nil {
+		return nil, errors.Wrap(err, "list release revisions")
+	}
+
+	configs := make([]map[string]interface{}, len(revisions))
+	corrupt := make([]bool, len(revisions))
+	err = forEachParallel(len(revisions), parallelism, func(i int) error {
+		helmRelease, err := c.GetRelease(ctx, namespace, releaseName, revisions[i])
+		if isCorruptRelease(err) {
+			fmt.Fprintf(os.Stderr, "Warning: skipping revision %d: %v\n", revisions[i], err)
+			corrupt[i] = true
+			return nil
+		} else if err != nil {
+			return errors.Wrapf(err, "get revision %d", revisions[i])
+		}
+		configs[i] = helmRelease.Config
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	history := []ValuesHistoryEntry{}
+	previous := map[string]interface{}{}
+	for i, revision := range revisions {
+		if corrupt[i] {
+			// the next revision is compared to the last one that could be decoded
+			continue
+		}
+		history = append(history, ValuesHistoryEntry{
+			Revision: revision,
+			Changes:  DiffValues(previous, configs[i]),
+		})
+		previous = configs[i]
+	}
+
+	return history, nil
+}
@@ -0,0 +1,106 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	helmrelease "helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// historyReleases returns n revisions of release app, each changing the revision value.
+func historyReleases(t testing.TB, n int) []runtime.Object {
+	t.Helper()
+
+	releases := []*helmrelease.Release{}
+	for revision := 1; revision <= n; revision++ {
+		status := helmrelease.StatusSuperseded
+		if revision == n {
+			status = helmrelease.StatusDeployed
+		}
+		releases = append(releases, testRelease(revision, status))
+	}
+	return releaseSecrets(t, releases...)
+}
+
+func TestValuesHistoryOrder(t *testing.T) {
+	client := fakeReleaseClient(historyReleases(t, 20)...)
+
+	for _, parallelism := range []int{1, 8} {
+		history, err := client.ValuesHistory(context.Background(), "ns", "app", parallelism)
+		if err != nil {
+			t.Fatalf("ValuesHistory with parallelism %d: %v", parallelism, err)
+		}
+		if len(history) != 20 {
+			t.Fatalf("parallelism %d: got %d revisions, want 20", parallelism, len(history))
+		}
+		for i, entry := range history {
+			if entry.Revision != i+1 {
+				t.Errorf("parallelism %d: got revision %d at index %d, want %d", parallelism, entry.Revision, i, i+1)
+			}
+			if len(entry.Changes) != 1 || entry.Changes[0].Path != "revision" {
+				t.Errorf("parallelism %d: got changes %v in revision %d, want the revision value", parallelism, entry.Changes, entry.Revision)
+			}
+		}
+	}
+}
+
+// BenchmarkValuesHistory decodes 50 revisions from a fake API server that answers each request
+// after 5ms, like a remote cluster would.
+func BenchmarkValuesHistory(b *testing.B) {
+	clientset := slowClientset{Clientset: fake.NewSimpleClientset(historyReleases(b, 50)...), latency: 5 * time.Millisecond}
+
+	client := NewClient()
+	client.Clientset = clientset
+	client.Storage = StorageSecret
+
+	for _, parallelism := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("parallelism=%d", parallelism), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := client.ValuesHistory(context.Background(), "ns", "app", parallelism); err != nil {
+					b.Fatalf("ValuesHistory: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// slowClientset delays secret requests by latency. The reactors of the fake clientset can't, they run under its lock.
+type slowClientset struct {
+	*fake.Clientset
+	latency time.Duration
+}
+
+func (c slowClientset) CoreV1() typedcorev1.CoreV1Interface {
+	return slowCoreV1{CoreV1Interface: c.Clientset.CoreV1(), latency: c.latency}
+}
+
+type slowCoreV1 struct {
+	typedcorev1.CoreV1Interface
+	latency time.Duration
+}
+
+func (c slowCoreV1) Secrets(namespace string) typedcorev1.SecretInterface {
+	return slowSecrets{SecretInterface: c.CoreV1Interface.Secrets(namespace), latency: c.latency}
+}
+
+type slowSecrets struct {
+	typedcorev1.SecretInterface
+	latency time.Duration
+}
+
+func (s slowSecrets) Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.Secret, error) {
+	time.Sleep(s.latency)
+	return s.SecretInterface.Get(ctx, name, opts)
+}
+
+func (s slowSecrets) List(ctx context.Context, opts metav1.ListOptions) (*corev1.SecretList, error) {
+	time.Sleep(s.latency)
+	return s.SecretInterface.List(ctx, opts)
+}
@@ -0,0 +1,80 @@
+package helm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// hookAnnotation matches the helm.sh/hook annotation key, but not helm.sh/hook-weight or helm.sh/hook-delete-policy.
+var hookAnnotation = regexp.MustCompile(`(?m)^\s*["']?helm\.sh/hook["']?\s*:`)
+
+// writeHooks writes the rendered manifest of each release hook to <kind>-<name>.yaml in dir.
+// Hooks such as migration Jobs can hold secrets, so the files are written with mode like the values file.
+func writeHooks(hooks []*helmrelease.Hook, dir string, mode os.FileMode) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "create dir")
+	}
+
+	written := map[string]bool{}
+	for _, hook := range hooks {
+		baseName := safeFileName(strings.ToLower(hook.Kind) + "-" + hook.Name)
+		fileName := baseName + ".yaml"
+		for n := 2; written[fileName]; n++ {
+			fileName = fmt.Sprintf("%s-%d.yaml", baseName, n)
+		}
+		written[fileName] = true
+
+		if err := ioutil.WriteFile(filepath.Join(dir, fileName), []byte(strings.TrimSpace(hook.Manifest)+"\n"), mode); err != nil {
+			return errors.Wrapf(err, "write %s", fileName)
+		}
+	}
+
+	return nil
+}
+
+// stripHooks removes the templates annotated with helm.sh/hook from the release chart and its dependencies.
+// A template that renders both hooks and regular resources is removed as a whole.
+// Hooks whose template was not removed, e.g. because the annotation comes from a named template, are reported as warnings.
+func stripHooks(release *helmrelease.Release) []string {
+	stripped := map[string]bool{}
+	for _, name := range stripHookTemplates(release.Chart, "") {
+		fmt.Fprintln(os.Stderr, "Stripped hook template", name)
+		stripped[path.Join(release.Chart.Metadata.Name, name)] = true
+	}
+
+	warnings := []string{}
+	for _, hook := range release.Hooks {
+		if !stripped[hook.Path] {
+			warnings = append(warnings, fmt.Sprintf("hook %s %s from %s was not stripped, its template was not found or has no helm.sh/hook annotation", hook.Kind, hook.Name, hook.Path))
+		}
+	}
+	return warnings
+}
+
+// stripHookTemplates removes hook templates from c and returns their names relative to the top level chart.
+func stripHookTemplates(c *chart.Chart, prefix string) []string {
+	stripped := []string{}
+	templates := []*chart.File{}
+	for _, template := range c.Templates {
+		if hookAnnotation.Match(template.Data) {
+			stripped = append(stripped, path.Join(prefix, template.Name))
+			continue
+		}
+		templates = append(templates, template)
+	}
+	c.Templates = templates
+
+	for _, dependency := range c.Dependencies() {
+		stripped = append(stripped, stripHookTemplates(dependency, path.Join(prefix, "charts", dependency.Name()))...)
+	}
+	return stripped
+}
@@ -0,0 +1,46 @@
+package helm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+func TestConvertReleaseHooksDirMode(t *testing.T) {
+	release := testRelease(1, helmrelease.StatusDeployed)
+	release.Hooks = []*helmrelease.Hook{{
+		Name:     "migrate",
+		Kind:     "Job",
+		Path:     "app/templates/migrate.yaml",
+		Manifest: "apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n",
+	}}
+
+	tests := []struct {
+		name string
+		mode os.FileMode
+		want os.FileMode
+	}{
+		{name: "default", want: 0600},
+		{name: "output permissions", mode: 0640, want: 0640},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hooksDir := t.TempDir()
+			_, err := NewClient().ConvertRelease(context.Background(), release, ConvertOptions{DestDir: t.TempDir(), HooksDir: hooksDir, ValuesFileMode: test.mode})
+			if err != nil {
+				t.Fatalf("ConvertRelease: %v", err)
+			}
+
+			info, err := os.Stat(filepath.Join(hooksDir, "job-migrate.yaml"))
+			if err != nil {
+				t.Fatalf("stat hook file: %v", err)
+			}
+			if info.Mode().Perm() != test.want {
+				t.Errorf("got hook file mode %o, want %o", info.Mode().Perm(), test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// pinImages rewrites the release images in values and templates to the digests of the images
+// currently running in the release namespace. Images that can't be resolved are left as is.
+func (c *Client) pinImages(ctx context.Context, release *helmrelease.Release) error {
+	images, err := manifestImages(release.Manifest)
+	if err != nil {
+		return errors.Wrap(err, "find manifest images")
+	}
+
+	digests, err := c.runningImageDigests(ctx, release.Namespace)
+	if err != nil {
+		return errors.Wrap(err, "find running image digests")
+	}
+
+	pinned := map[string]string{}
+	for _, image := range images {
+		if strings.Contains(image, "@") {
+			continue
+		}
+		digest, ok := digests[image]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Warning: could not resolve digest of image %s\n", image)
+			continue
+		}
+		pinned[image] = imageRepository(image) + "@" + digest
+	}
+
+	if len(pinned) == 0 {
+		return nil
+	}
+
+	pinValueImages(release.Config, pinned)
+
+	if release.Chart != nil {
+		pinValueImages(release.Chart.Values, pinned)
+		for _, template := range release.Chart.Templates {
+			for image, pinnedImage := range pinned {
+				template.Data = replaceImage(template.Data, image, pinnedImage)
+			}
+		}
+	}
+
+	return nil
+}
+
+// runningImageDigests maps pod spec images in the namespace to the digests of the images the containers run.
+func (c *Client) runningImageDigests(ctx context.Context, namespace string) (map[string]string, error) {
+	clientSet, err := c.GetClientset()
+	if err != nil {
+		return nil, errors.Wrap(err, "get clientset")
+	}
+
+	pods, err := clientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "list pods")
+	}
+
+	digests := map[string]string{}
+	for _, pod := range pods.Items {
+		imageIDs := map[string]string{}
+		for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+			imageIDs[status.Name] = status.ImageID
+		}
+
+		for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+			imageID := imageIDs[container.Name]
+			if i := strings.Index(imageID, "@sha256:"); i != -1 {
+				digests[container.Image] = imageID[i+1:]
+			}
+		}
+	}
+
+	return digests, nil
+}
+
+// pinValueImages replaces image references in values. Both plain "repo:tag" strings and
+// maps with separate repository and tag keys are handled.
+func pinValueImages(values map[string]interface{}, pinned map[string]string) {
+	for key, value := range values {
+		switch v := value.(type) {
+		case string:
+			if pinnedImage, ok := pinned[v]; ok {
+				values[key] = pinnedImage
+			}
+		case map[string]interface{}:
+			pinValueImageMap(v, pinned)
+			pinValueImages(v, pinned)
+		case []interface{}:
+			for i, item := range v {
+				switch itemValue := item.(type) {
+				case string:
+					if pinnedImage, ok := pinned[itemValue]; ok {
+						v[i] = pinnedImage
+					}
+				case map[string]interface{}:
+					pinValueImageMap(itemValue, pinned)
+					pinValueImages(itemValue, pinned)
+				}
+			}
+		}
+	}
+}
+
+func pinValueImageMap(values map[string]interface{}, pinned map[string]string) {
+	repository, _ := values["repository"].(string)
+	tag := fmt.Sprint(values["tag"])
+	if repository == "" || values["tag"] == nil {
+		return
+	}
+
+	for image, pinnedImage := range pinned {
+		if image != repository+":"+tag && !strings.HasSuffix(image, "/"+repository+":"+tag) {
+			continue
+		}
+
+		digest := pinnedImage[strings.Index(pinnedImage, "@")+1:]
+		if _, ok := values["digest"]; ok {
+			values["digest"] = digest
+		} else {
+			values["tag"] = tag + "@" + digest
+		}
+		return
+	}
+}
+
+// replaceImage replaces whole occurrences of image, delimited by whitespace or quotes, in template data.
+func replaceImage(data []byte, image string, pinnedImage string) []byte {
+	re := regexp.MustCompile(`(^|[\s"'])` + regexp.QuoteMeta(image) + `($|[\s"'])`)
+	return re.ReplaceAll(data, []byte("${1}"+pinnedImage+"${2}"))
+}
+
+// imageRepository strips the tag from an image reference.
+func imageRepository(image string) string {
+	i := strings.LastIndex(image, ":")
+	if i != -1 && i > strings.LastIndex(image, "/") {
+		return image[:i]
+	}
+	return image
+}
+
+// writeImageList writes the images deployed by the release manifest and its hooks to path, one per line,
+// sorted and without duplicates. "-" writes them to stdout.
+func writeImageList(release *helmrelease.Release, path string, mode os.FileMode) error {
+	manifests := []string{release.Manifest}
+	for _, hook := range release.Hooks {
+		manifests = append(manifests, hook.Manifest)
+	}
+
+	images, err := manifestImages(strings.Join(manifests, "\n---\n"))
+	if err != nil {
+		return errors.Wrap(err, "find manifest images")
+	}
+	sort.Strings(images)
+
+	list := ""
+	for _, image := range images {
+		list += image + "\n"
+	}
+
+	if path == "-" {
+		_, err := fmt.Print(list)
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(list), mode)
+}
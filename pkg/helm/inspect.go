@@ -0,0 +1,72 @@
+package helm
+
+import (
+	"sort"
+	"time"
+
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// ReleaseSummary describes a release revision, see SummarizeRelease.
+type ReleaseSummary struct {
+	Name          string    `json:"name" yaml:"name"`
+	Namespace     string    `json:"namespace" yaml:"namespace"`
+	Revision      int       `json:"revision" yaml:"revision"`
+	Status        string    `json:"status" yaml:"status"`
+	Chart         string    `json:"chart" yaml:"chart"`
+	ChartVersion  string    `json:"chartVersion" yaml:"chartVersion"`
+	AppVersion    string    `json:"appVersion,omitempty" yaml:"appVersion,omitempty"`
+	FirstDeployed time.Time `json:"firstDeployed" yaml:"firstDeployed"`
+	LastDeployed  time.Time `json:"lastDeployed" yaml:"lastDeployed"`
+	Description   string    `json:"description,omitempty" yaml:"description,omitempty"`
+	Templates     int       `json:"templates" yaml:"templates"`
+	Files         int       `json:"files" yaml:"files"`
+	Dependencies  []string  `json:"dependencies" yaml:"dependencies"`
+	// ValueKeys are the top-level keys of the values supplied at install or upgrade,
+	// DefaultValueKeys those of the chart defaults.
+	ValueKeys        []string `json:"valueKeys" yaml:"valueKeys"`
+	DefaultValueKeys []string `json:"defaultValueKeys" yaml:"defaultValueKeys"`
+}
+
+// SummarizeRelease returns the chart, status, deploy times, file counts, dependencies and value keys of the release.
+func SummarizeRelease(release *helmrelease.Release) *ReleaseSummary {
+	summary := &ReleaseSummary{
+		Name:             release.Name,
+		Namespace:        release.Namespace,
+		Revision:         release.Version,
+		Dependencies:     []string{},
+		ValueKeys:        sortedKeys(release.Config),
+		DefaultValueKeys: []string{},
+	}
+
+	if release.Info != nil {
+		summary.Status = release.Info.Status.String()
+		summary.FirstDeployed = release.Info.FirstDeployed.Time
+		summary.LastDeployed = release.Info.LastDeployed.Time
+		summary.Description = release.Info.Description
+	}
+
+	if release.Chart != nil {
+		summary.Templates = len(release.Chart.Templates)
+		summary.Files = len(release.Chart.Files)
+		summary.DefaultValueKeys = sortedKeys(release.Chart.Values)
+		if release.Chart.Metadata != nil {
+			summary.Chart = release.Chart.Metadata.Name
+			summary.ChartVersion = release.Chart.Metadata.Version
+			summary.AppVersion = release.Chart.Metadata.AppVersion
+			for _, dependency := range release.Chart.Metadata.Dependencies {
+				summary.Dependencies = append(summary.Dependencies, dependency.Name)
+			}
+		}
+	}
+	return summary
+}
+
+func sortedKeys(values map[string]interface{}) []string {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,135 @@
+package helm
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// JobOutputDir is where the conversion Job writes the chart in its container.
+const JobOutputDir = "/charts"
+
+// JobOptions configures the manifest ConversionJobManifest generates.
+type JobOptions struct {
+	Namespace   string
+	ReleaseName string
+	// Image is the release2chart image the Job runs.
+	Image string
+	// ClaimName is the PersistentVolumeClaim mounted at JobOutputDir. Empty mounts an emptyDir,
+	// which is lost with the pod.
+	ClaimName string
+	// Args are added to the release2chart arguments, e.g. --values-mode computed.
+	Args []string
+	// Storage is the storage driver of the release, StorageSecret if empty.
+	Storage string
+}
+
+// ConversionJobManifest returns a ServiceAccount, Role, RoleBinding and Job that convert the release in-cluster.
+// The Role grants get and list on the objects of the storage driver in the release namespace,
+// which is what reading a release needs. Nothing is sent to the cluster.
+func ConversionJobManifest(opts JobOptions) ([]byte, error) {
+	if opts.ReleaseName == "" || opts.Namespace == "" || opts.Image == "" {
+		return nil, errors.New("release name, namespace and image are required")
+	}
+
+	storage := opts.Storage
+	if storage == "" {
+		storage = StorageSecret
+	}
+
+	resource := ""
+	switch storage {
+	case StorageSecret:
+		resource = "secrets"
+	case StorageConfigMap:
+		resource = "configmaps"
+	default:
+		return nil, errors.Errorf("a Job can only be generated for the %s and %s storage drivers", StorageSecret, StorageConfigMap)
+	}
+
+	name := "release2chart-" + opts.ReleaseName
+	if len(name) > validation.DNS1123LabelMaxLength {
+		name = name[:validation.DNS1123LabelMaxLength]
+	}
+	meta := metav1.ObjectMeta{
+		Name:      name,
+		Namespace: opts.Namespace,
+		Labels:    map[string]string{"app.kubernetes.io/name": "release2chart", "app.kubernetes.io/instance": opts.ReleaseName},
+	}
+
+	args := []string{opts.ReleaseName, "--namespace", opts.Namespace, "--in-cluster", "--output-dir", JobOutputDir, "--overwrite"}
+	if storage != StorageSecret {
+		args = append(args, "--storage", storage)
+	}
+	args = append(args, opts.Args...)
+
+	volume := corev1.Volume{Name: "charts"}
+	if opts.ClaimName != "" {
+		volume.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{ClaimName: opts.ClaimName}
+	} else {
+		volume.EmptyDir = &corev1.EmptyDirVolumeSource{}
+	}
+
+	backoffLimit := int32(2)
+	objects := []interface{}{
+		&corev1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: meta,
+		},
+		&rbacv1.Role{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+			ObjectMeta: meta,
+			Rules: []rbacv1.PolicyRule{{
+				APIGroups: []string{""},
+				Resources: []string{resource},
+				Verbs:     []string{"get", "list"},
+			}},
+		},
+		&rbacv1.RoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+			ObjectMeta: meta,
+			Subjects:   []rbacv1.Subject{{Kind: "ServiceAccount", Name: name, Namespace: opts.Namespace}},
+			RoleRef:    rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "Role", Name: name},
+		},
+		&batchv1.Job{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+			ObjectMeta: meta,
+			Spec: batchv1.JobSpec{
+				BackoffLimit: &backoffLimit,
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: meta.Labels},
+					Spec: corev1.PodSpec{
+						ServiceAccountName: name,
+						RestartPolicy:      corev1.RestartPolicyNever,
+						Containers: []corev1.Container{{
+							Name:         "release2chart",
+							Image:        opts.Image,
+							Args:         args,
+							VolumeMounts: []corev1.VolumeMount{{Name: "charts", MountPath: JobOutputDir}},
+						}},
+						Volumes: []corev1.Volume{volume},
+					},
+				},
+			},
+		},
+	}
+
+	var manifest bytes.Buffer
+	for i, object := range objects {
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			return nil, errors.Wrap(err, "marshal job manifest")
+		}
+		if i > 0 {
+			manifest.WriteString("---\n")
+		}
+		manifest.Write(data)
+	}
+	return manifest.Bytes(), nil
+}
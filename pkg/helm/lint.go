@@ -0,0 +1,33 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/lint"
+	"helm.sh/helm/v3/pkg/lint/support"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// lintChart runs the `helm lint` rules on chartDir with the release values. Warnings are printed,
+// errors are returned.
+func lintChart(chartDir string, release *helmrelease.Release) error {
+	linter := lint.All(chartDir, copyValues(release.Config), release.Namespace, false)
+
+	lintErrors := []string{}
+	for _, message := range linter.Messages {
+		switch message.Severity {
+		case support.ErrorSev:
+			lintErrors = append(lintErrors, message.Error())
+		case support.WarningSev:
+			fmt.Fprintln(os.Stderr, "Lint:", message.Error())
+		}
+	}
+	if len(lintErrors) > 0 {
+		return errors.Errorf("chart failed lint: %s", strings.Join(lintErrors, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type ReleaseInfo struct {
+	Name         string    `json:"name" yaml:"name"`
+	Namespace    string    `json:"namespace" yaml:"namespace"`
+	Revision     int       `json:"revision" yaml:"revision"`
+	Status       string    `json:"status" yaml:"status"`
+	Chart        string    `json:"chart" yaml:"chart"`
+	ChartVersion string    `json:"chartVersion" yaml:"chartVersion"`
+	Updated      time.Time `json:"updated" yaml:"updated"`
+}
+
+// ListReleases returns the latest revision of every release in the namespace, sorted by namespace and name.
+// An empty namespace lists releases in all namespaces. Releases that can't be decoded are skipped with a warning.
+func (c *Client) ListReleases(ctx context.Context, namespace string) ([]ReleaseInfo, error) {
+	selector, err := c.releaseSelector(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := c.listStoredReleaseMetadata(ctx, namespace, selector)
+	if err != nil {
+		return nil, errors.Wrap(err, "list stored releases")
+	}
+
+	type releaseID struct {
+		namespace string
+		name      string
+	}
+	latest := map[releaseID]int{}
+	revisions := map[releaseID]int{}
+	for i, object := range stored {
+		revision, err := strconv.Atoi(object.Labels["version"])
+		if err != nil {
+			continue
+		}
+		key := releaseID{namespace: object.Namespace, name: object.Labels["name"]}
+		if _, ok := latest[key]; !ok || revision > revisions[key] {
+			latest[key] = i
+			revisions[key] = revision
+		}
+	}
+
+	releases := []ReleaseInfo{}
+	for key, i := range latest {
+		helmRelease, err := c.loadStoredRelease(ctx, &stored[i])
+		if isCorruptRelease(err) {
+			fmt.Fprintf(os.Stderr, "Warning: skipping release %s/%s: %v\n", key.namespace, key.name, err)
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "decode release %s/%s", key.namespace, key.name)
+		}
+
+		info := ReleaseInfo{
+			Name:      helmRelease.Name,
+			Namespace: helmRelease.Namespace,
+			Revision:  helmRelease.Version,
+		}
+		if helmRelease.Info != nil {
+			info.Status = helmRelease.Info.Status.String()
+			info.Updated = helmRelease.Info.LastDeployed.Time
+		}
+		if helmRelease.Chart != nil && helmRelease.Chart.Metadata != nil {
+			info.Chart = helmRelease.Chart.Metadata.Name
+			info.ChartVersion = helmRelease.Chart.Metadata.Version
+		}
+		releases = append(releases, info)
+	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		if releases[i].Namespace != releases[j].Namespace {
+			return releases[i].Namespace < releases[j].Namespace
+		}
+		return releases[i].Name < releases[j].Name
+	})
+
+	return releases, nil
+}
+
+// FindReleaseNamespace returns the namespace of the release named releaseName, searching all namespaces.
+// If the name is used in more than one namespace, the release chooser picks one or it fails.
+func (c *Client) FindReleaseNamespace(ctx context.Context, releaseName string) (string, error) {
+	selector, err := c.releaseSelector(map[string]string{"name": releaseName})
+	if err != nil {
+		return "", err
+	}
+
+	stored, err := c.listStoredReleaseMetadata(ctx, "", selector)
+	if err != nil {
+		return "", errors.Wrap(err, "list stored releases")
+	}
+
+	namespaces := map[string]bool{}
+	for _, object := range stored {
+		namespaces[object.Namespace] = true
+	}
+
+	found := []string{}
+	for namespace := range namespaces {
+		found = append(found, namespace)
+	}
+	sort.Strings(found)
+
+	switch len(found) {
+	case 0:
+		return "", releaseNotFoundf("release %s not found in any namespace", releaseName)
+	case 1:
+		return found[0], nil
+	}
+	i, ok, err := chooseRelease("Release "+releaseName+" exists in several namespaces", found)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return found[i], nil
+	}
+	return "", errors.Errorf("release %s exists in namespaces %s, use --namespace to select one", releaseName, strings.Join(found, ", "))
+}
+
+// FindReleaseByChart returns the latest revision of the release installed from the chart named chartName.
+// An empty namespace searches all namespaces. If more than one release uses the chart, the release chooser
+// picks one or it fails.
+func (c *Client) FindReleaseByChart(ctx context.Context, namespace string, chartName string) (*ReleaseInfo, error) {
+	releases, err := c.ListReleases(ctx, namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "list releases")
+	}
+
+	found := []ReleaseInfo{}
+	for _, release := range releases {
+		if release.Chart == chartName {
+			found = append(found, release)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, releaseNotFoundf("no release of chart %s found", chartName)
+	case 1:
+		return &found[0], nil
+	}
+
+	names := []string{}
+	for _, release := range found {
+		names = append(names, release.Namespace+"/"+release.Name)
+	}
+	i, ok, err := chooseRelease("Chart "+chartName+" is used by several releases", names)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return &found[i], nil
+	}
+	return nil, errors.Errorf("chart %s is used by releases %s, pass the release name instead", chartName, strings.Join(names, ", "))
+}
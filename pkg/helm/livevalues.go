@@ -0,0 +1,108 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chartutil"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// DefaultLiveValueFields are the live object fields copied into values by default.
+var DefaultLiveValueFields = []string{"replicas", "image"}
+
+var listIndex = regexp.MustCompile(`\[\d+\]`)
+
+// applyLiveValues copies fields that changed in the live objects since the release was deployed back into
+// the release values. A field is matched by the last segments of its path, e.g. "replicas" or
+// "containers.image". A changed field is written to the one value that holds the deployed value under a key
+// named like the field, e.g. replicaCount for replicas, or to the tag of a repository/tag image map.
+// Changes without exactly one matching value are reported and skipped.
+func (c *Client) applyLiveValues(ctx context.Context, release *helmrelease.Release, fields []string) error {
+	drifts, err := c.compareReleaseWithCluster(ctx, release)
+	if err != nil {
+		return errors.Wrap(err, "compare with cluster")
+	}
+
+	values := chartutil.CoalesceTables(copyValues(release.Config), copyValues(release.Chart.Values))
+	leaves := flattenValues(values)
+
+	for _, drift := range drifts {
+		for _, change := range drift.Changes {
+			if change.Change != ValueChanged {
+				continue
+			}
+			field := matchingLiveField(change.Path, fields)
+			if field == "" {
+				continue
+			}
+
+			overrides := liveValueOverrides(leaves, field, change.Old, change.New)
+			if len(overrides) != 1 {
+				fmt.Fprintf(os.Stderr, "Warning: %s %s changed from %v to %v, but %d values match, not updating values\n", drift.Resource, change.Path, change.Old, change.New, len(overrides))
+				continue
+			}
+
+			for path, value := range overrides {
+				if err := setValue(release.Config, path, value); err != nil {
+					return errors.Wrapf(err, "set %s", path)
+				}
+				leaves[path] = value
+			}
+		}
+	}
+
+	return nil
+}
+
+func matchingLiveField(path string, fields []string) string {
+	path = listIndex.ReplaceAllString(path, "")
+	for _, field := range fields {
+		if path == field || strings.HasSuffix(path, "."+field) {
+			return field
+		}
+	}
+	return ""
+}
+
+// liveValueOverrides returns the values paths that produced old and should be set to live.
+func liveValueOverrides(leaves map[string]interface{}, field string, old interface{}, live interface{}) map[string]interface{} {
+	segments := strings.Split(field, ".")
+	stem := strings.TrimSuffix(strings.ToLower(segments[len(segments)-1]), "s")
+
+	overrides := map[string]interface{}{}
+	for path, value := range leaves {
+		key := strings.ToLower(path[strings.LastIndex(path, ".")+1:])
+		if strings.HasPrefix(key, stem) && reflect.DeepEqual(value, old) {
+			overrides[path] = live
+		}
+	}
+	if len(overrides) > 0 {
+		return overrides
+	}
+
+	// images split into repository and tag values
+	oldImage, _ := old.(string)
+	liveImage, _ := live.(string)
+	if oldImage == "" || liveImage == "" || imageRepository(oldImage) != imageRepository(liveImage) || imageRepository(liveImage) == liveImage {
+		return overrides
+	}
+	oldTag := strings.TrimPrefix(oldImage, imageRepository(oldImage)+":")
+	liveTag := strings.TrimPrefix(liveImage, imageRepository(liveImage)+":")
+	for path, value := range leaves {
+		if !strings.HasSuffix(path, ".tag") || fmt.Sprint(value) != oldTag {
+			continue
+		}
+		repository, _ := leaves[strings.TrimSuffix(path, ".tag")+".repository"].(string)
+		if repository != "" && (oldImage == repository+":"+oldTag || strings.HasSuffix(oldImage, "/"+repository+":"+oldTag)) {
+			overrides[path] = liveTag
+		}
+	}
+
+	return overrides
+}
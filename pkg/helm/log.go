@@ -0,0 +1,16 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+)
+
+// verbose enables debug messages about the steps of a conversion. Warnings are printed either way.
+var verbose bool
+
+// debugf prints a debug message to stderr if --verbose is set.
+func debugf(format string, args ...interface{}) {
+	if verbose {
+		fmt.Fprintf(os.Stderr, "Debug: "+format+"\n", args...)
+	}
+}
@@ -0,0 +1,164 @@
+package helm
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+type manifestResource struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name        string            `yaml:"name"`
+		Namespace   string            `yaml:"namespace"`
+		Labels      map[string]string `yaml:"labels"`
+		Annotations map[string]string `yaml:"annotations"`
+	} `yaml:"metadata"`
+}
+
+// parseManifest splits a rendered release manifest into its resources.
+// Empty documents are skipped.
+func parseManifest(manifest string) ([]manifestResource, error) {
+	resources := []manifestResource{}
+
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		resource := manifestResource{}
+		err := decoder.Decode(&resource)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "decode manifest document")
+		}
+
+		if resource.Kind == "" {
+			continue
+		}
+		resources = append(resources, resource)
+	}
+
+	return resources, nil
+}
+
+// manifestImages returns the container and init container images of all workloads in the manifest,
+// in order of first appearance.
+func manifestImages(manifest string) ([]string, error) {
+	images := []string{}
+	seen := map[string]bool{}
+
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		doc := map[string]interface{}{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "decode manifest document")
+		}
+
+		for _, image := range podSpecImages(podSpec(doc)) {
+			if !seen[image] {
+				seen[image] = true
+				images = append(images, image)
+			}
+		}
+	}
+
+	return images, nil
+}
+
+// podSpec returns the pod spec of a workload resource, or nil if the resource has none.
+func podSpec(doc map[string]interface{}) map[string]interface{} {
+	switch doc["kind"] {
+	case "Pod":
+		return nestedMap(doc, "spec")
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "ReplicationController", "Job":
+		return nestedMap(doc, "spec", "template", "spec")
+	case "CronJob":
+		return nestedMap(doc, "spec", "jobTemplate", "spec", "template", "spec")
+	}
+	return nil
+}
+
+func podSpecImages(spec map[string]interface{}) []string {
+	images := []string{}
+	for _, field := range []string{"initContainers", "containers"} {
+		containers, _ := spec[field].([]interface{})
+		for _, container := range containers {
+			c, _ := container.(map[string]interface{})
+			if image, ok := c["image"].(string); ok && image != "" {
+				images = append(images, image)
+			}
+		}
+	}
+	return images
+}
+
+func nestedMap(m map[string]interface{}, fields ...string) map[string]interface{} {
+	for _, field := range fields {
+		next, ok := m[field].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		m = next
+	}
+	return m
+}
+
+// writeManifestResources writes each resource of the manifest to <kind>-<name>.yaml in dir.
+// Resources without a name are numbered by their position in the manifest.
+func writeManifestResources(manifest string, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "create dir")
+	}
+
+	docs := releaseutil.SplitManifests(manifest)
+	keys := []string{}
+	for key := range docs {
+		keys = append(keys, key)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	written := map[string]bool{}
+	for i, key := range keys {
+		doc := docs[key]
+
+		resource := manifestResource{}
+		if err := yaml.Unmarshal([]byte(doc), &resource); err != nil {
+			return errors.Wrapf(err, "decode manifest document %d", i)
+		}
+		if resource.Kind == "" {
+			continue
+		}
+
+		name := resource.Metadata.Name
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+
+		baseName := safeFileName(strings.ToLower(resource.Kind) + "-" + name)
+		fileName := baseName + ".yaml"
+		for n := 2; written[fileName]; n++ {
+			fileName = fmt.Sprintf("%s-%d.yaml", baseName, n)
+		}
+		written[fileName] = true
+
+		if err := ioutil.WriteFile(filepath.Join(dir, fileName), []byte(strings.TrimSpace(doc)+"\n"), 0644); err != nil {
+			return errors.Wrapf(err, "write %s", fileName)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// patchChartMetadata merges the Chart.yaml fields in patchFile onto metadata. Maps such as annotations are
+// merged key by key, other fields including lists are replaced, and null removes a field.
+func patchChartMetadata(metadata *chart.Metadata, patchFile string) error {
+	data, err := ioutil.ReadFile(patchFile)
+	if err != nil {
+		return errors.Wrap(err, "read metadata patch")
+	}
+	patch := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &patch); err != nil {
+		return errors.Wrap(err, "parse metadata patch")
+	}
+
+	// chart.Metadata has JSON tags named like the Chart.yaml fields
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		return errors.Wrap(err, "marshal chart metadata")
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(metadataJSON, &fields); err != nil {
+		return errors.Wrap(err, "unmarshal chart metadata")
+	}
+	mergePatch(fields, patch)
+
+	patchedJSON, err := json.Marshal(fields)
+	if err != nil {
+		return errors.Wrap(err, "marshal patched metadata")
+	}
+	patched := &chart.Metadata{}
+	decoder := json.NewDecoder(bytes.NewReader(patchedJSON))
+	// a misspelled field would otherwise be dropped silently
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(patched); err != nil {
+		return errors.Wrap(err, "apply metadata patch")
+	}
+
+	if patched.Name == "" {
+		return errors.New("patched chart metadata has no name")
+	}
+	if _, err := semver.NewVersion(patched.Version); err != nil {
+		return errors.Wrapf(err, "patched chart version %q is invalid", patched.Version)
+	}
+
+	*metadata = *patched
+	return nil
+}
+
+// mergePatch merges patch onto fields like a JSON merge patch.
+func mergePatch(fields map[string]interface{}, patch map[string]interface{}) {
+	for key, value := range patch {
+		if value == nil {
+			delete(fields, key)
+			continue
+		}
+		patchMap, isMap := value.(map[string]interface{})
+		fieldMap, fieldIsMap := fields[key].(map[string]interface{})
+		if isMap && fieldIsMap {
+			mergePatch(fieldMap, patchMap)
+			continue
+		}
+		fields[key] = value
+	}
+}
@@ -0,0 +1,98 @@
+package helm
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// namespaceListParallelism is how many namespaces listInNamespaces lists at a time.
+const namespaceListParallelism = 10
+
+// listInNamespaces calls list for namespace. If namespace is empty and listing across all namespaces is forbidden,
+// every namespace is listed on its own, concurrently, and namespaces that can't be read are skipped.
+func (c *Client) listInNamespaces(ctx context.Context, storage releaseStorage, namespace string, list listFunc) ([]storedRelease, error) {
+	releases, err := list(storage, namespace)
+	if err == nil || namespace != "" || !apierrors.IsForbidden(err) {
+		return releases, err
+	}
+
+	clientSet := storageClientset(storage)
+	if clientSet == nil {
+		return nil, err
+	}
+	namespaces, nsErr := c.namespaceNames(ctx, clientSet)
+	if nsErr != nil {
+		// can't list namespaces either, report the original error with its RBAC hint
+		debugf("listing namespaces failed: %v", nsErr)
+		return nil, err
+	}
+	debugf("listing across namespaces is forbidden, listing %d namespaces one by one", len(namespaces))
+
+	found := make([][]storedRelease, len(namespaces))
+	readable := make([]bool, len(namespaces))
+	listErr := forEachParallel(len(namespaces), namespaceListParallelism, func(i int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		namespaceReleases, err := list(storage, namespaces[i])
+		if apierrors.IsForbidden(err) {
+			debugf("skipping namespace %s, listing it is forbidden", namespaces[i])
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "namespace %s", namespaces[i])
+		}
+		found[i], readable[i] = namespaceReleases, true
+		return nil
+	})
+	if listErr != nil {
+		return nil, listErr
+	}
+
+	anyReadable := false
+	releases = []storedRelease{}
+	for i := range namespaces {
+		anyReadable = anyReadable || readable[i]
+		releases = append(releases, found[i]...)
+	}
+	if !anyReadable {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// storageClientset returns the clientset of Kubernetes storage, nil for SQL storage.
+func storageClientset(storage releaseStorage) kubernetes.Interface {
+	switch s := storage.(type) {
+	case secretStorage:
+		return s.clientSet
+	case configMapStorage:
+		return s.clientSet
+	}
+	return nil
+}
+
+func (c *Client) namespaceNames(ctx context.Context, clientSet kubernetes.Interface) ([]string, error) {
+	names := []string{}
+	err := c.listPages(ctx, "list namespaces", labels.Everything(), listPageSize, func(opts metav1.ListOptions) (string, error) {
+		namespaces, err := clientSet.CoreV1().Namespaces().List(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+		for _, namespace := range namespaces.Items {
+			names = append(names, namespace.Name)
+		}
+		return namespaces.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,31 @@
+package helm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// NotesFile is the file ConvertOptions.DumpNotes writes the rendered notes to. It is not named NOTES.txt,
+// which would be mistaken for the chart template.
+const NotesFile = "NOTES.rendered.txt"
+
+// writeNotesFile writes the rendered notes of the release to NotesFile in dstDir, with the values file mode
+// since notes often print credentials. It returns the file name, or an empty string if the release has no notes.
+func writeNotesFile(release *helmrelease.Release, dstDir string, opts ConvertOptions) (string, error) {
+	notes := releaseNotes(release)
+	if notes == "" {
+		fmt.Fprintf(os.Stderr, "Warning: release %s has no notes, %s is not written\n", release.Name, NotesFile)
+		return "", nil
+	}
+
+	notesFile := filepath.Join(dstDir, NotesFile)
+	if err := ioutil.WriteFile(notesFile, []byte(notes), valuesFileMode(opts)); err != nil {
+		return "", errors.Wrap(err, "write notes")
+	}
+	return notesFile, nil
+}
@@ -0,0 +1,225 @@
+package helm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// packageChartDir archives chartDir into a chart tgz in destDir, the same way `helm package` does,
+// except that files are placed under archiveRoot instead of the chart name and compressed with the gzip level.
+// If reproducible is set, the archive doesn't depend on file times and owners.
+func packageChartDir(chartDir string, destDir string, metadata *chart.Metadata, archiveRoot string, reproducible bool, level int) (string, error) {
+	if archiveRoot == "." || archiveRoot == ".." || strings.ContainsAny(archiveRoot, `/\`) {
+		return "", errors.Errorf("invalid archive root %q", archiveRoot)
+	}
+
+	chartFile := filepath.Join(destDir, fmt.Sprintf("%s-%s.tgz", metadata.Name, metadata.Version))
+
+	f, err := os.Create(chartFile)
+	if err != nil {
+		return "", errors.Wrap(err, "create chart file")
+	}
+	defer f.Close()
+
+	if err := writeChartArchive(f, chartDir, archiveRoot, reproducible, level); err != nil {
+		return "", errors.Wrap(err, "write chart archive")
+	}
+
+	if err := f.Close(); err != nil {
+		return "", errors.Wrap(err, "close chart file")
+	}
+
+	if _, err := loader.LoadFile(chartFile); err != nil {
+		return "", errors.Wrap(err, "load packaged chart")
+	}
+
+	return chartFile, nil
+}
+
+func writeChartArchive(w io.Writer, chartDir string, archiveRoot string, reproducible bool, level int) error {
+	modTime, err := archiveModTime(reproducible)
+	if err != nil {
+		return err
+	}
+
+	gzipWriter, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		return errors.Wrap(err, "create gzip writer")
+	}
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	// Walk visits files in lexical order
+	err = filepath.Walk(chartDir, func(fileName string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relName, err := filepath.Rel(chartDir, fileName)
+		if err != nil {
+			return errors.Wrapf(err, "get relative path of %s", fileName)
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return errors.Wrapf(err, "create tar header for %s", relName)
+		}
+		header.Name = path.Join(archiveRoot, filepath.ToSlash(relName))
+		if reproducible {
+			makeReproducible(header, modTime)
+		}
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return errors.Wrapf(err, "write tar header for %s", relName)
+		}
+
+		file, err := os.Open(fileName)
+		if err != nil {
+			return errors.Wrapf(err, "open %s", fileName)
+		}
+		defer file.Close()
+
+		if _, err := io.Copy(tarWriter, file); err != nil {
+			return errors.Wrapf(err, "write %s to archive", relName)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return errors.Wrap(err, "close tar writer")
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return errors.Wrap(err, "close gzip writer")
+	}
+
+	return nil
+}
+
+// streamReleasePackage writes the chart archive straight from the decoded release, without unpacking it to disk first.
+func streamReleasePackage(release *helmrelease.Release, destDir string, opts ConvertOptions) (string, error) {
+	if opts.RebuildDeps || opts.DependencyUpdate || opts.Subchart != "" {
+		return "", errors.New("streamed packaging can't download dependencies or extract subcharts")
+	}
+	if opts.Lint {
+		return "", errors.New("streamed packaging can't lint the chart, which needs the unpacked chart")
+	}
+
+	if opts.BuildMetadata != "" {
+		version, err := versionWithBuildMetadata(release.Chart.Metadata.Version, opts.BuildMetadata)
+		if err != nil {
+			return "", errors.Wrap(err, "set build metadata")
+		}
+		release.Chart.Metadata.Version = version
+	}
+
+	archiveRoot := release.Chart.Metadata.Name
+	if opts.ArchiveRoot != "" {
+		archiveRoot = opts.ArchiveRoot
+	}
+	if archiveRoot == "" || archiveRoot == "." || archiveRoot == ".." || strings.ContainsAny(archiveRoot, `/\`) {
+		return "", errors.Errorf("invalid archive root %q", archiveRoot)
+	}
+
+	chartFile := filepath.Join(destDir, fmt.Sprintf("%s-%s.tgz", release.Chart.Metadata.Name, release.Chart.Metadata.Version))
+	f, err := os.Create(chartFile)
+	if err != nil {
+		return "", errors.Wrap(err, "create chart file")
+	}
+	defer f.Close()
+
+	if err := writeReleaseArchive(f, release, archiveRoot, opts); err != nil {
+		return "", errors.Wrap(err, "write chart archive")
+	}
+	if err := f.Close(); err != nil {
+		return "", errors.Wrap(err, "close chart file")
+	}
+
+	packaged, err := loader.LoadFile(chartFile)
+	if err != nil {
+		return "", errors.Wrap(err, "load packaged chart")
+	}
+
+	if err := checkPackagedFiles(packaged, release.Chart); err != nil {
+		return "", err
+	}
+
+	if opts.StrictRoundtrip {
+		if missing := missingDependencies(packaged); len(missing) > 0 {
+			return "", errors.Errorf("dependencies missing from the converted chart: %s", strings.Join(missing, ", "))
+		}
+	}
+
+	debugf("streamed %s %s with %d templates and %d files to %s", packaged.Metadata.Name, packaged.Metadata.Version, len(packaged.Templates), len(packaged.Files), chartFile)
+	return chartFile, nil
+}
+
+// compressionLevel returns the gzip level of chart archives, gzip.DefaultCompression if opts sets none.
+func compressionLevel(opts ConvertOptions) int {
+	if opts.CompressionLevel == nil {
+		return gzip.DefaultCompression
+	}
+	return *opts.CompressionLevel
+}
+
+// writeReleaseArchive writes the release chart as a chart archive with files under archiveRoot.
+func writeReleaseArchive(w io.Writer, release *helmrelease.Release, archiveRoot string, opts ConvertOptions) error {
+	files, err := releaseChartFiles(release, opts)
+	if err != nil {
+		return errors.Wrap(err, "collect chart files")
+	}
+	if opts.MaxChartSize > 0 {
+		if err := checkChartSize(files, opts.MaxChartSize); err != nil {
+			return err
+		}
+	}
+	if opts.Reproducible {
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].Name < files[j].Name
+		})
+	}
+
+	modTime, err := archiveModTime(opts.Reproducible)
+	if err != nil {
+		return err
+	}
+
+	gzipWriter, err := gzip.NewWriterLevel(w, compressionLevel(opts))
+	if err != nil {
+		return errors.Wrap(err, "create gzip writer")
+	}
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, file := range files {
+		if err := checkChartFileName(file.Name); err != nil {
+			return err
+		}
+		if err := writeTarFile(tarWriter, path.Join(archiveRoot, file.Name), file.Data, chartFileMode(file.Name, opts), modTime); err != nil {
+			return errors.Wrapf(err, "write %s", file.Name)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		return errors.Wrap(err, "close tar writer")
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return errors.Wrap(err, "close gzip writer")
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// archiveFile returns the data of a file in a chart archive, by its name relative to the chart root.
+func archiveFile(t *testing.T, chartFile string, name string) []byte {
+	t.Helper()
+
+	f, err := os.Open(chartFile)
+	if err != nil {
+		t.Fatalf("open chart archive: %v", err)
+	}
+	defer f.Close()
+
+	files, err := loader.LoadArchiveFiles(f)
+	if err != nil {
+		t.Fatalf("read chart archive: %v", err)
+	}
+	for _, file := range files {
+		if file.Name == name {
+			return file.Data
+		}
+	}
+	t.Fatalf("%s not found in chart archive", name)
+	return nil
+}
+
+func TestConvertReleaseChartYAML(t *testing.T) {
+	level := 9
+	tests := []struct {
+		name string
+		opts ConvertOptions
+	}{
+		{name: "helm packager"},
+		{name: "reproducible", opts: ConvertOptions{Reproducible: true}},
+		{name: "archive root", opts: ConvertOptions{ArchiveRoot: "root"}},
+		{name: "compression level", opts: ConvertOptions{CompressionLevel: &level}},
+		{name: "file mode", opts: ConvertOptions{FileMode: 0640, ExecutableScripts: true}},
+		{name: "stream package", opts: ConvertOptions{StreamPackage: true}},
+		{name: "chart dir", opts: ConvertOptions{ChartDir: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.opts.DestDir = t.TempDir()
+			result, err := NewClient().ConvertRelease(context.Background(), testRelease(1, helmrelease.StatusDeployed), test.opts)
+			if err != nil {
+				t.Fatalf("ConvertRelease: %v", err)
+			}
+
+			var chartYAML []byte
+			if test.opts.ChartDir {
+				chartYAML, err = ioutil.ReadFile(filepath.Join(result.ChartPath, "Chart.yaml"))
+				if err != nil {
+					t.Fatalf("read Chart.yaml: %v", err)
+				}
+			} else {
+				chartYAML = archiveFile(t, result.ChartPath, "Chart.yaml")
+			}
+
+			for _, field := range []string{"apiVersion: v2\n", "appVersion: \"1.0\"\n", "name: app\n", "version: 0.1.0\n"} {
+				if !bytes.Contains(chartYAML, []byte(field)) {
+					t.Errorf("Chart.yaml has no %q:\n%s", strings.TrimSpace(field), chartYAML)
+				}
+			}
+			// unset fields are omitted, not written empty
+			if bytes.Contains(chartYAML, []byte("home:")) || bytes.Contains(chartYAML, []byte("sources:")) {
+				t.Errorf("Chart.yaml has empty fields:\n%s", chartYAML)
+			}
+
+			converted, err := loader.Load(result.ChartPath)
+			if err != nil {
+				t.Fatalf("load converted chart: %v", err)
+			}
+			if converted.Metadata.APIVersion != "v2" || converted.Metadata.Version != "0.1.0" {
+				t.Errorf("got chart %s %s, want apiVersion v2 and version 0.1.0", converted.Metadata.APIVersion, converted.Metadata.Version)
+			}
+		})
+	}
+}
+
+func TestNewPackageActionDependencyUpdate(t *testing.T) {
+	for _, dependencyUpdate := range []bool{false, true} {
+		client := newPackageAction("dest", ConvertOptions{DependencyUpdate: dependencyUpdate})
+		if client.DependencyUpdate != dependencyUpdate {
+			t.Errorf("got DependencyUpdate %t, want %t", client.DependencyUpdate, dependencyUpdate)
+		}
+		if client.Destination != "dest" {
+			t.Errorf("got destination %q, want dest", client.Destination)
+		}
+	}
+}
+
+// dependencyRelease returns a release of an umbrella chart app that depends on mid, which depends on leaf.
+func dependencyRelease() *helmrelease.Release {
+	leaf := &chart.Chart{
+		Metadata:  &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "leaf", Version: "0.0.1"},
+		Templates: []*chart.File{{Name: "templates/leaf.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: leaf\n")}},
+		Values:    map[string]interface{}{"level": "leaf"},
+	}
+	mid := &chart.Chart{
+		Metadata:  &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "mid", Version: "0.1.0", Dependencies: []*chart.Dependency{{Name: "leaf", Version: "0.0.1"}}},
+		Templates: []*chart.File{{Name: "templates/mid.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: mid\n")}},
+		Values:    map[string]interface{}{"level": "mid"},
+		Files:     []*chart.File{{Name: "files/mid.conf", Data: []byte("mid=true\n")}},
+	}
+	mid.AddDependency(leaf)
+
+	release := testRelease(1, helmrelease.StatusDeployed)
+	release.Chart.Metadata.Dependencies = []*chart.Dependency{{Name: "mid", Version: "0.1.0"}}
+	release.Chart.AddDependency(mid)
+	return release
+}
+
+func TestConvertReleaseDependencies(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ConvertOptions
+	}{
+		{name: "helm packager"},
+		{name: "reproducible", opts: ConvertOptions{Reproducible: true}},
+		{name: "stream package", opts: ConvertOptions{StreamPackage: true}},
+		{name: "archive root", opts: ConvertOptions{ArchiveRoot: "root"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.opts.DestDir = t.TempDir()
+			result, err := NewClient().ConvertRelease(context.Background(), dependencyRelease(), test.opts)
+			if err != nil {
+				t.Fatalf("ConvertRelease: %v", err)
+			}
+
+			converted, err := loader.Load(result.ChartPath)
+			if err != nil {
+				t.Fatalf("load converted chart: %v", err)
+			}
+			if len(converted.Dependencies()) != 1 {
+				t.Fatalf("got %d dependencies of app, want mid", len(converted.Dependencies()))
+			}
+			mid := converted.Dependencies()[0]
+			if mid.Name() != "mid" || mid.Metadata.Version != "0.1.0" || mid.Values["level"] != "mid" {
+				t.Errorf("got dependency %s %s with values %v, want mid 0.1.0", mid.Name(), mid.Metadata.Version, mid.Values)
+			}
+			if len(mid.Templates) != 1 || mid.Templates[0].Name != "templates/mid.yaml" {
+				t.Errorf("got mid templates %v, want templates/mid.yaml", mid.Templates)
+			}
+			if len(mid.Files) != 1 || string(mid.Files[0].Data) != "mid=true\n" {
+				t.Errorf("got mid files %v, want files/mid.conf", mid.Files)
+			}
+
+			if len(mid.Dependencies()) != 1 {
+				t.Fatalf("got %d dependencies of mid, want leaf", len(mid.Dependencies()))
+			}
+			leaf := mid.Dependencies()[0]
+			if leaf.Name() != "leaf" || leaf.Values["level"] != "leaf" || len(leaf.Templates) != 1 {
+				t.Errorf("got dependency %s with values %v and templates %v, want leaf", leaf.Name(), leaf.Values, leaf.Templates)
+			}
+			if leaf.Parent() != mid || mid.Parent() != converted {
+				t.Error("dependencies are not nested app -> mid -> leaf")
+			}
+		})
+	}
+}
+
+func TestConvertReleaseCRDs(t *testing.T) {
+	crd := []byte("apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\nmetadata:\n  name: widgets.example.com\nspec:\n  group: example.com\n  names:\n    kind: Widget\n    plural: widgets\n  scope: Namespaced\n  versions:\n  - name: v1\n    served: true\n    storage: true\n    schema:\n      openAPIV3Schema:\n        type: object\n")
+
+	tests := []struct {
+		name string
+		opts ConvertOptions
+	}{
+		{name: "helm packager"},
+		{name: "canonical", opts: ConvertOptions{Canonical: true}},
+		{name: "stream package", opts: ConvertOptions{StreamPackage: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			release := testRelease(1, helmrelease.StatusDeployed)
+			release.Chart.Files = []*chart.File{{Name: "crds/widgets.yaml", Data: crd}}
+
+			test.opts.DestDir = t.TempDir()
+			result, err := NewClient().ConvertRelease(context.Background(), release, test.opts)
+			if err != nil {
+				t.Fatalf("ConvertRelease: %v", err)
+			}
+
+			converted, err := loader.Load(result.ChartPath)
+			if err != nil {
+				t.Fatalf("load converted chart: %v", err)
+			}
+			crds := converted.CRDObjects()
+			if len(crds) != 1 || crds[0].Name != "crds/widgets.yaml" {
+				t.Fatalf("got CRDs %v, want crds/widgets.yaml", crds)
+			}
+			if !bytes.Equal(crds[0].File.Data, crd) {
+				t.Errorf("got CRD\n%s\nwant\n%s", crds[0].File.Data, crd)
+			}
+			for _, template := range converted.Templates {
+				if strings.HasPrefix(template.Name, "crds/") {
+					t.Errorf("CRD %s was written as a template", template.Name)
+				}
+			}
+		})
+	}
+}
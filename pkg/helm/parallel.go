@@ -0,0 +1,41 @@
+package helm
+
+import (
+	"sync"
+)
+
+// forEachParallel calls fn for every index in [0, n) from at most parallelism goroutines.
+// It waits for all calls to finish and returns the error of the lowest failing index.
+func forEachParallel(n int, parallelism int, fn func(i int) error) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	errs := make([]error, n)
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < parallelism && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				errs[i] = fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package helm
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// PlanConversion returns the result ConvertRelease would return for helmRelease and opts, without
+// unpacking, packaging or writing anything. The digest is not known without packaging and is left empty.
+func PlanConversion(helmRelease *helmrelease.Release, opts ConvertOptions) (*ConversionResult, error) {
+	if helmRelease.Chart == nil || helmRelease.Chart.Metadata == nil {
+		return nil, errors.New("release has no chart")
+	}
+	if opts.Subchart != "" {
+		return nil, errors.New("the subchart version is only known after downloading the dependencies")
+	}
+	if opts.Bundle {
+		return nil, errors.New("a release bundle is installed with unbundle, not with a planned install command")
+	}
+
+	result := newConversionResult(helmRelease)
+	if opts.ChartVersion != "" {
+		if _, err := semver.StrictNewVersion(opts.ChartVersion); err != nil {
+			return nil, errors.Wrapf(err, "invalid chart version %q", opts.ChartVersion)
+		}
+		result.ChartVersion = opts.ChartVersion
+	}
+	if opts.BuildMetadata != "" {
+		version, err := versionWithBuildMetadata(result.ChartVersion, opts.BuildMetadata)
+		if err != nil {
+			return nil, errors.Wrap(err, "set build metadata")
+		}
+		result.ChartVersion = version
+	}
+
+	dstDir := "."
+	if opts.DestDir != "" {
+		dstDir = opts.DestDir
+	}
+
+	if !opts.ValuesOnly {
+		if opts.ChartDir {
+			result.ChartPath = filepath.Join(dstDir, result.ChartName)
+		} else {
+			result.ChartPath = filepath.Join(dstDir, fmt.Sprintf("%s-%s.tgz", result.ChartName, result.ChartVersion))
+		}
+		if opts.Sign {
+			result.ProvenancePath = result.ChartPath + ".prov"
+		}
+	}
+
+	values, err := outputValues(helmRelease, opts)
+	if err != nil {
+		return nil, err
+	}
+	if values != nil {
+		result.ValuesPath = filepath.Join(dstDir, "values.yaml")
+	}
+
+	return result, nil
+}
@@ -0,0 +1,34 @@
+package helm
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/provenance"
+)
+
+var expectedDigestPattern = regexp.MustCompile(`^sha256:[0-9a-fA-F]{64}$`)
+
+// ChartDigest returns the SHA256 digest of a chart archive in the form registries report it, sha256:<hex>.
+func ChartDigest(chartFile string) (string, error) {
+	digest, err := provenance.DigestFile(chartFile)
+	if err != nil {
+		return "", errors.Wrap(err, "digest chart file")
+	}
+	return "sha256:" + digest, nil
+}
+
+// signChart writes the <chart>.prov provenance file next to the chart archive, like `helm package --sign`.
+func signChart(chartFile string, opts ConvertOptions) error {
+	if opts.SignKey == "" || opts.Keyring == "" {
+		return errors.New("signing needs a key name and a keyring")
+	}
+
+	client := action.NewPackage()
+	client.Key = opts.SignKey
+	client.Keyring = opts.Keyring
+	client.PassphraseFile = opts.PassphraseFile
+
+	return client.Clearsign(chartFile)
+}
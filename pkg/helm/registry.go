@@ -0,0 +1,83 @@
+package helm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/registry"
+)
+
+type RegistryOptions struct {
+	// URL is the repository the chart is pushed to, e.g. oci://registry.example.com/charts.
+	URL string
+	// Username and Password log in to the registry. If they are empty, the credentials
+	// Helm stored with helm registry login are used.
+	Username string
+	Password string
+}
+
+type RegistryPushResult struct {
+	Ref    string
+	Digest string
+}
+
+// PushToRegistry pushes a packaged chart and its provenance file, if there is one, to an OCI registry
+// the way helm push does.
+func PushToRegistry(chartFile string, opts RegistryOptions) (*RegistryPushResult, error) {
+	if !registry.IsOCI(opts.URL) {
+		return nil, errors.Errorf("registry url %q must start with %s://", opts.URL, registry.OCIScheme)
+	}
+	repository := strings.TrimSuffix(strings.TrimPrefix(opts.URL, registry.OCIScheme+"://"), "/")
+
+	pushedChart, err := loader.Load(chartFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "load chart")
+	}
+	data, err := ioutil.ReadFile(chartFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "read chart file")
+	}
+
+	credentialsFile := cli.New().RegistryConfig
+	if opts.Username != "" || opts.Password != "" {
+		// log in to a temp credentials file, so the password isn't stored in Helm's registry config
+		credentialsDir, err := ioutil.TempDir("", "registry-")
+		if err != nil {
+			return nil, errors.Wrap(err, "create credentials dir")
+		}
+		defer os.RemoveAll(credentialsDir)
+		credentialsFile = filepath.Join(credentialsDir, "config.json")
+	}
+
+	client, err := registry.NewClient(registry.ClientOptCredentialsFile(credentialsFile), registry.ClientOptWriter(ioutil.Discard))
+	if err != nil {
+		return nil, errors.Wrap(err, "create registry client")
+	}
+
+	if opts.Username != "" || opts.Password != "" {
+		host := strings.SplitN(repository, "/", 2)[0]
+		if err := client.Login(host, registry.LoginOptBasicAuth(opts.Username, opts.Password)); err != nil {
+			return nil, errors.Wrapf(err, "log in to %s", host)
+		}
+	}
+
+	pushOpts := []registry.PushOption{}
+	if provData, err := ioutil.ReadFile(chartFile + ".prov"); err == nil {
+		pushOpts = append(pushOpts, registry.PushOptProvData(provData))
+	} else if !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "read provenance file")
+	}
+
+	ref := repository + "/" + pushedChart.Metadata.Name + ":" + pushedChart.Metadata.Version
+	result, err := client.Push(data, ref, pushOpts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "push %s", ref)
+	}
+
+	return &RegistryPushResult{Ref: result.Ref, Digest: result.Manifest.Digest}, nil
+}
@@ -5,201 +5,1539 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/pkg/errors"
+	"github.com/spf13/afero"
 	"gopkg.in/yaml.v3"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
 	helmrelease "helm.sh/helm/v3/pkg/release"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	sigsyaml "sigs.k8s.io/yaml"
 )
 
-func FindLatestReleaseVersion(namespace string, releaseName string) (int, error) {
-	clientSet, err := GetClientset()
+var releaseStatuses = []helmrelease.Status{
+	helmrelease.StatusUnknown,
+	helmrelease.StatusDeployed,
+	helmrelease.StatusUninstalled,
+	helmrelease.StatusSuperseded,
+	helmrelease.StatusFailed,
+	helmrelease.StatusUninstalling,
+	helmrelease.StatusPendingInstall,
+	helmrelease.StatusPendingUpgrade,
+	helmrelease.StatusPendingRollback,
+}
+
+// ParseReleaseStatus validates a release status name.
+func ParseReleaseStatus(status string) (helmrelease.Status, error) {
+	for _, s := range releaseStatuses {
+		if s.String() == status {
+			return s, nil
+		}
+	}
+	return "", errors.Errorf("unknown release status %q", status)
+}
+
+// RevisionCandidate is a revision considered by SelectLatestRevision.
+type RevisionCandidate struct {
+	Revision int
+	// Status is taken from the storage object labels, or from the decoded release if the object has no status label.
+	Status  helmrelease.Status
+	Created time.Time
+	// Note explains why the revision was chosen or passed over.
+	Note string
+}
+
+// RevisionSelection records how SelectLatestRevision chose a revision.
+type RevisionSelection struct {
+	Revision int
+	Status   helmrelease.Status
+	// Candidates are all revisions of the release, newest first.
+	Candidates []RevisionCandidate
+	// LastDeployedRevision is the newest revision labeled deployed, or 0 if there is none.
+	LastDeployedRevision int
+}
+
+// FindLatestReleaseVersion returns the highest revision of the release.
+// If status is not empty, only revisions with that status label are considered. If includePending is set,
+// revisions with a pending status, e.g. the one of a stuck upgrade, are considered as well.
+func (c *Client) FindLatestReleaseVersion(ctx context.Context, namespace string, releaseName string, status helmrelease.Status, includePending bool) (int, error) {
+	selection, err := c.SelectLatestRevision(ctx, namespace, releaseName, status, includePending)
 	if err != nil {
-		return 0, errors.Wrap(err, "get clientset")
+		return 0, err
 	}
 
-	selectorLabels := map[string]string{
-		"owner": "helm",
-		"name":  releaseName,
+	if status == "" && !includePending && selection.Status.IsPending() {
+		fmt.Fprintf(os.Stderr, "Warning: latest revision %d of release %s is %s, the converted chart may be incomplete\n", selection.Revision, releaseName, selection.Status)
+		if selection.LastDeployedRevision != 0 {
+			fmt.Fprintf(os.Stderr, "Warning: the last deployed revision is %d, convert it with --revision %d or --status deployed\n", selection.LastDeployedRevision, selection.LastDeployedRevision)
+		}
 	}
-	listOpts := metav1.ListOptions{
-		LabelSelector: labels.SelectorFromSet(selectorLabels).String(),
+
+	return selection.Revision, nil
+}
+
+// SelectLatestRevision picks the revision FindLatestReleaseVersion converts and records every candidate with the reason it was or wasn't chosen.
+func (c *Client) SelectLatestRevision(ctx context.Context, namespace string, releaseName string, status helmrelease.Status, includePending bool) (*RevisionSelection, error) {
+	selector, err := c.releaseSelector(map[string]string{"name": releaseName})
+	if err != nil {
+		return nil, err
 	}
 
-	secrets, err := clientSet.CoreV1().Secrets(namespace).List(context.TODO(), listOpts)
+	stored, err := c.listStoredReleaseMetadata(ctx, namespace, selector)
 	if err != nil {
-		return 0, errors.Wrap(err, "list secrets")
+		return nil, errors.Wrap(err, "list stored releases")
 	}
 
-	latestRevision := 0
-	for _, secret := range secrets.Items {
-		revision, err := strconv.Atoi(secret.Labels["version"])
+	if len(stored) == 0 {
+		return nil, releaseNotFoundf("release %s not found in namespace %s", releaseName, namespace)
+	}
+
+	type storedRevision struct {
+		candidate RevisionCandidate
+		stored    storedRelease
+	}
+
+	selection := &RevisionSelection{}
+	revisions := []storedRevision{}
+	for _, object := range stored {
+		revision, err := strconv.Atoi(object.Labels["version"])
 		if err != nil {
 			continue
 		}
 
-		if revision > latestRevision {
-			latestRevision = revision
+		candidate := RevisionCandidate{
+			Revision: revision,
+			Status:   helmrelease.Status(object.Labels["status"]),
+			Created:  object.Created,
+		}
+		if createdAt, err := strconv.ParseInt(object.Labels["createdAt"], 10, 64); err == nil {
+			candidate.Created = time.Unix(createdAt, 0)
+		}
+
+		if candidate.Status == helmrelease.StatusDeployed && revision > selection.LastDeployedRevision {
+			selection.LastDeployedRevision = revision
+		}
+
+		revisions = append(revisions, storedRevision{candidate: candidate, stored: object})
+	}
+	if len(revisions) == 0 {
+		// never return revision 0, it would be looked up as a revision of its own
+		return nil, releaseNotFoundf("release %s not found in namespace %s, none of its %d objects has a numeric version label", releaseName, namespace, len(stored))
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].candidate.Revision > revisions[j].candidate.Revision
+	})
+
+	for _, r := range revisions {
+		candidate := r.candidate
+		selected := false
+		switch {
+		case selection.Revision != 0:
+			candidate.Note = fmt.Sprintf("older than selected revision %d", selection.Revision)
+		case status == "":
+			candidate.Note = "selected: newest revision"
+			selected = true
+		default:
+			if candidate.Status == "" {
+				// storage written without a status label, fall back to the decoded release
+				helmRelease, err := c.loadStoredRelease(ctx, &r.stored)
+				if isCorruptRelease(err) {
+					fmt.Fprintln(os.Stderr, "Warning: skipping corrupt release:", err)
+					candidate.Note = "release data is corrupt"
+					break
+				} else if err != nil {
+					return nil, errors.Wrapf(err, "parse release info from %s %s", r.stored.Kind, r.stored.Name)
+				}
+				if helmRelease.Info == nil {
+					candidate.Note = "release has no status"
+					break
+				}
+				candidate.Status = helmRelease.Info.Status
+			}
+			if includePending && candidate.Status.IsPending() {
+				candidate.Note = "selected: newest pending revision"
+				selected = true
+				break
+			}
+			if candidate.Status != status {
+				candidate.Note = fmt.Sprintf("status is not %s", status)
+				break
+			}
+			candidate.Note = fmt.Sprintf("selected: newest revision with status %s", status)
+			selected = true
+		}
+
+		if selected {
+			selection.Revision = candidate.Revision
+			selection.Status = candidate.Status
+		}
+		debugf("revision %d (%s): %s", candidate.Revision, candidate.Status, candidate.Note)
+		selection.Candidates = append(selection.Candidates, candidate)
+	}
+
+	if status != "" && selection.Revision == 0 {
+		wanted := "status " + string(status)
+		if includePending {
+			wanted += " or a pending status"
+		}
+		return nil, revisionNotFoundf("no revision of release %s with %s found, use --status any to convert the latest revision", releaseName, wanted)
+	}
+
+	return selection, nil
+}
+
+// ListReleaseRevisions returns all revisions of the release in ascending order.
+func (c *Client) ListReleaseRevisions(ctx context.Context, namespace string, releaseName string) ([]int, error) {
+	selector, err := c.releaseSelector(map[string]string{"name": releaseName})
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := c.listStoredReleaseMetadata(ctx, namespace, selector)
+	if err != nil {
+		return nil, errors.Wrap(err, "list stored releases")
+	}
+
+	revisions := []int{}
+	for _, object := range stored {
+		revision, err := strconv.Atoi(object.Labels["version"])
+		if err != nil {
+			continue
 		}
+		revisions = append(revisions, revision)
 	}
+	sort.Ints(revisions)
 
-	return latestRevision, nil
+	return revisions, nil
 }
 
-func ConvertReleaseVersion(namespace string, releaseName string, revision int) (string, string, error) {
+// GetRelease fetches and decodes the given revision of the release.
+func (c *Client) GetRelease(ctx context.Context, namespace string, releaseName string, revision int) (*helmrelease.Release, error) {
+	selector, err := c.releaseSelector(map[string]string{"name": releaseName, "version": strconv.Itoa(revision)})
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := c.listStoredReleases(ctx, namespace, selector)
+	if err != nil {
+		return nil, errors.Wrap(err, "list stored releases")
+	}
+
+	if len(stored) == 0 {
+		return nil, c.revisionNotFoundError(ctx, namespace, releaseName, revision)
+	}
+
+	// a failed rollback can leave duplicates of a revision behind, use the newest one
+	sort.SliceStable(stored, func(i, j int) bool {
+		return stored[i].Created.After(stored[j].Created)
+	})
+	if len(stored) > 1 && c.Strict {
+		names := []string{}
+		for _, object := range stored {
+			names = append(names, object.Kind+" "+object.Name)
+		}
+		i, ok, err := chooseRelease(fmt.Sprintf("Revision %d of release %s is stored more than once", revision, releaseName), names)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.Errorf("found %d matching releases (%s), narrow them down with --label or --selector", len(stored), strings.Join(names, ", "))
+		}
+		stored = []storedRelease{stored[i]}
+	}
+	if len(stored) > 1 {
+		names := []string{}
+		for _, object := range stored {
+			names = append(names, object.Name)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: found %d %ss for revision %d (%s), using the newest one %s\n", len(stored), stored[0].Kind, revision, strings.Join(names, ", "), stored[0].Name)
+	}
+
+	debugf("decoding revision %d from %s %s", revision, stored[0].Kind, stored[0].Name)
+	helmRelease, err := releaseFromStorage(&stored[0], c.ReleaseKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse release info")
+	}
+	warnReleaseNameMismatch(&stored[0], releaseName, helmRelease)
+
+	return helmRelease, nil
+}
+
+// GetStoredRelease decodes the release in the storage object with the given name, a secret, configmap or
+// SQL row key depending on the storage driver. Its labels are ignored, so releases whose labels were stripped
+// or changed, e.g. by a backup tool, can still be read.
+func (c *Client) GetStoredRelease(ctx context.Context, namespace string, objectName string) (*helmrelease.Release, error) {
+	storage, err := c.storageFor(c.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := storage.Get(ctx, namespace, objectName)
+	if apierrors.IsNotFound(err) {
+		return nil, releaseNotFoundf("%s %s not found in namespace %s", c.Storage, objectName, namespace)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	debugf("decoding %s %s without checking its labels", stored.Kind, stored.Name)
+	helmRelease, err := releaseFromStorage(stored, c.ReleaseKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse release info")
+	}
+	return helmRelease, nil
+}
+
+// revisionNotFoundError lists the revisions the release has, or reports that there is no such release.
+func (c *Client) revisionNotFoundError(ctx context.Context, namespace string, releaseName string, revision int) error {
+	revisions, err := c.ListReleaseRevisions(ctx, namespace, releaseName)
+	if err != nil {
+		return errors.Wrapf(err, "revision %d not found, list available revisions", revision)
+	}
+	if len(revisions) == 0 {
+		return releaseNotFoundf("release %s not found in namespace %s", releaseName, namespace)
+	}
+
+	available := []string{}
+	for _, r := range revisions {
+		available = append(available, strconv.Itoa(r))
+	}
+	return revisionNotFoundf("revision %d not found, available revisions: %s", revision, strings.Join(available, ", "))
+}
+
+type ConvertOptions struct {
+	// DestDir is the directory the chart and values files are written to. Defaults to the current directory.
+	DestDir string
+	// ReportFile, if set, is where a summary of the converted release is written.
+	ReportFile string
+	// ReportFormat is the format of the report file, either "md" or "txt".
+	ReportFormat string
+	// TempDir is where the intermediate chart directory is created. Defaults to the OS temp dir.
+	TempDir string
+	// KeepTemp is KeepTempNever, KeepTempOnFailure or KeepTempAlways. Empty is KeepTempNever.
+	KeepTemp string
+	// ArchiveRoot, if set, is the top-level directory inside the chart archive instead of the chart name.
+	ArchiveRoot string
+	// PinImages rewrites image references to the digests currently running in the cluster.
+	PinImages bool
+	// RenderCheck renders the converted chart as the release and fails if the output differs from the stored manifest.
+	RenderCheck bool
+	// ArtifactHub adds Artifact Hub annotations derived from the release to Chart.yaml.
+	ArtifactHub bool
+	// RepoIndex creates or updates a chart repository index.yaml in the destination directory.
+	RepoIndex bool
+	// StrictRoundtrip fails the conversion if a declared dependency is missing from the converted chart.
+	StrictRoundtrip bool
+	// SplitManifestDir, if set, is where each resource of the deployed manifest is written to its own file.
+	SplitManifestDir string
+	// NormalizeValues writes the values file with sorted keys and two space indentation.
+	NormalizeValues bool
+	// AnnotateOverrides marks the keys of the computed values file that the release config sets with a
+	// "# user-override" comment. It needs ValuesMode computed.
+	AnnotateOverrides bool
+	// RebuildDeps downloads the chart dependencies from their repositories instead of using the bundled subcharts.
+	RebuildDeps bool
+	// DependencyUpdate updates the chart dependencies before packaging, replacing the bundled subcharts.
+	DependencyUpdate bool
+	// LiveValueFields, if set, are live object fields, e.g. replicas, copied back into the values when they
+	// changed since the release was deployed.
+	LiveValueFields []string
+	// StreamPackage writes the chart archive directly from the decoded release instead of packaging a temp dir.
+	// It can't be combined with options that need the unpacked chart, such as RebuildDeps or Subchart.
+	StreamPackage bool
+	// Canonical writes the chart in a stable form for version control: sorted Chart.yaml and values keys,
+	// LF line endings and templates ordered by name. It implies NormalizeValues.
+	Canonical bool
+	// BuildMetadata, if set, replaces the semver build metadata of the chart version, e.g. a CI run ID.
+	BuildMetadata string
+	// ChartVersion, if set, replaces the version of the converted chart. It must be a valid SemVer.
+	ChartVersion string
+	// AppVersion, if set, replaces the app version of the converted chart.
+	AppVersion string
+	// Rename, if set, is the name the chart will be installed under. The release name is replaced with it
+	// in name override values such as fullnameOverride. Names hardcoded in templates are not changed.
+	Rename string
+	// MigrateAPIVersion upgrades a chart with the Helm 2 apiVersion v1 to v2, moving its dependencies from
+	// requirements.yaml to Chart.yaml.
+	MigrateAPIVersion bool
+	// SecurityCheck warns about references to removed security APIs such as PodSecurityPolicy in the manifest.
+	SecurityCheck bool
+	// UnsetValues are dotted paths, e.g. a.b[0].c, removed from the extracted values file.
+	UnsetValues []string
+	// Subchart, if set, converts only the named subchart of the release as a standalone chart.
+	// Releases don't store subcharts, so the dependencies are downloaded from their repositories.
+	Subchart string
+	// OutputFs, if set, receives the chart archive and values file in DestDir instead of the local disk.
+	// Intermediate files are still written to TempDir.
+	OutputFs afero.Fs
+	// Bundle packs the chart, values and release metadata into a single release bundle instead of separate files.
+	Bundle bool
+	// Progress, if set, is called by ConvertReleases when the conversion of a release starts, with its
+	// position in the names and the number of names.
+	Progress func(name string, current int, total int)
+	// ChartDir writes the unpacked chart to <DestDir>/<chart name> instead of packaging a chart archive.
+	ChartDir bool
+	// ValuesFileMode is the mode of the extracted values file, which may contain secrets.
+	ValuesFileMode os.FileMode
+	// ValuesMode selects the values written to the values file: ValuesModeUser (the default),
+	// ValuesModeComputed or ValuesModeNone.
+	ValuesMode string
+	// Sign writes a <chart>.prov provenance file signed with SignKey from Keyring, like `helm package --sign`.
+	Sign bool
+	// SignKey is the name of the signing key in Keyring.
+	SignKey string
+	// Keyring is the secret keyring file with the signing key.
+	Keyring string
+	// PassphraseFile holds the passphrase of the signing key, "-" reads it from stdin. Prompted for if not set.
+	PassphraseFile string
+	// Reproducible writes byte-identical archives for the same release: entries are sorted and have fixed
+	// times (SOURCE_DATE_EPOCH or the Unix epoch) and owners.
+	Reproducible bool
+	// CompressionLevel, if set, is the gzip level of the chart archive, from gzip.NoCompression to
+	// gzip.BestCompression. Nil keeps the default level.
+	CompressionLevel *int
+	// ExpectDigest, if set, fails the conversion if the digest of the chart archive is not this sha256:<hex> digest.
+	// It implies Reproducible, other archives differ on every run.
+	ExpectDigest string
+	// DryRun only decodes and validates the release, nothing is written and no file names are returned.
+	DryRun bool
+	// Lint fails the conversion if the converted chart has `helm lint` errors. Warnings are printed.
+	Lint bool
+	// DumpReleaseFile, if set, is where the decoded release is written as JSON for debugging.
+	DumpReleaseFile string
+	// RedactDumpValues replaces string values of the release config in the dump, not in the values file.
+	RedactDumpValues bool
+	// FileMode is the mode chart files are written with, 0644 if not set.
+	FileMode os.FileMode
+	// ExecutableScripts makes *.sh files and files under scripts/ and bin/ dirs executable.
+	ExecutableScripts bool
+	// HooksDir, if set, is where the rendered manifest of each release hook is written to its own file.
+	HooksDir string
+	// Overwrite replaces existing chart, values, provenance and bundle files. Without it the conversion fails.
+	Overwrite bool
+	// AlwaysWriteValues writes an empty values file if the release has no values, unless ValuesMode is none.
+	AlwaysWriteValues bool
+	// ValuesOnly writes only the values file, the chart is neither unpacked nor packaged.
+	ValuesOnly bool
+	// ManifestFile, if set, is where the deployed manifest of the release is written verbatim.
+	ManifestFile string
+	// ImageListFile, if set, is where the images deployed by the manifest and hooks are listed, "-" for stdout.
+	ImageListFile string
+	// DumpNotes writes the rendered NOTES.txt of the release to NotesFile in the output dir.
+	DumpNotes bool
+	// MaxChartSize, if set, fails the conversion before packaging if the chart files add up to more bytes.
+	MaxChartSize int64
+	// IncludeFiles, if set, keeps only the chart files and templates matching one of these globs.
+	// ExcludeFiles removes those matching one of its globs. A filtered chart may not install.
+	IncludeFiles []string
+	ExcludeFiles []string
+	// MetadataPatchFile, if set, is a YAML file of Chart.yaml fields merged onto the chart metadata,
+	// after ChartVersion and AppVersion are applied.
+	MetadataPatchFile string
+	// StripHooks removes templates annotated with helm.sh/hook, so installing the chart doesn't run the hooks again.
+	StripHooks bool
+	// NoSchema leaves values.schema.json out of the chart. NoChartValues leaves out the values.yaml of the chart
+	// with its defaults, not the values file of the release written next to the chart, see ValuesMode.
+	// Dependencies keep theirs.
+	NoSchema      bool
+	NoChartValues bool
+}
+
+// ConversionResult describes a converted release and the files written for it.
+type ConversionResult struct {
+	// ChartPath is the chart archive, the release bundle with ConvertOptions.Bundle or the chart dir with
+	// ConvertOptions.ChartDir. Empty for dry runs.
+	ChartPath string `json:"chartPath,omitempty" yaml:"chartPath,omitempty"`
+	// ValuesPath is the values file, empty if none was written.
+	ValuesPath string `json:"valuesPath,omitempty" yaml:"valuesPath,omitempty"`
+	// ProvenancePath is the provenance file written with ConvertOptions.Sign.
+	ProvenancePath string `json:"provenancePath,omitempty" yaml:"provenancePath,omitempty"`
+	// NotesPath is the rendered notes file written with ConvertOptions.DumpNotes, empty if the release has no notes.
+	NotesPath    string `json:"notesPath,omitempty" yaml:"notesPath,omitempty"`
+	ChartName    string `json:"chartName" yaml:"chartName"`
+	ChartVersion string `json:"chartVersion" yaml:"chartVersion"`
+	ReleaseName  string `json:"releaseName" yaml:"releaseName"`
+	Namespace    string `json:"namespace" yaml:"namespace"`
+	Revision     int    `json:"revision" yaml:"revision"`
+	// Digest is the SHA256 digest of the chart archive as sha256:<hex>. Empty for a chart dir.
+	Digest string `json:"digest,omitempty" yaml:"digest,omitempty"`
+	// Status, FirstDeployed, LastDeployed and Description come from the release info.
+	// The times are nil if the release doesn't record them.
+	Status        string     `json:"status,omitempty" yaml:"status,omitempty"`
+	FirstDeployed *time.Time `json:"firstDeployed,omitempty" yaml:"firstDeployed,omitempty"`
+	LastDeployed  *time.Time `json:"lastDeployed,omitempty" yaml:"lastDeployed,omitempty"`
+	Description   string     `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+func newConversionResult(release *helmrelease.Release) *ConversionResult {
+	result := &ConversionResult{
+		ReleaseName: release.Name,
+		Namespace:   release.Namespace,
+		Revision:    release.Version,
+	}
+	if release.Chart != nil && release.Chart.Metadata != nil {
+		result.ChartName = release.Chart.Metadata.Name
+		result.ChartVersion = release.Chart.Metadata.Version
+	}
+	if release.Info != nil {
+		result.Status = release.Info.Status.String()
+		result.FirstDeployed = releaseTime(release.Info.FirstDeployed.Time)
+		result.LastDeployed = releaseTime(release.Info.LastDeployed.Time)
+		result.Description = release.Info.Description
+	}
+	return result
+}
+
+// releaseTime returns t in UTC, or nil if it is zero.
+func releaseTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func (c *Client) ConvertReleaseVersion(ctx context.Context, namespace string, releaseName string, revision int, opts ConvertOptions) (*ConversionResult, error) {
+	if opts.DestDir != "" && opts.OutputFs == nil && !opts.DryRun {
+		if err := prepareDestDir(opts.DestDir); err != nil {
+			return nil, errors.Wrap(err, "prepare output dir")
+		}
+	}
+
+	if opts.TempDir != "" {
+		if err := checkDirWritable(opts.TempDir); err != nil {
+			return nil, errors.Wrap(err, "check temp dir")
+		}
+	}
+
+	helmRelease, err := c.GetRelease(ctx, namespace, releaseName, revision)
+	if err != nil {
+		return nil, errors.Wrap(err, "get release")
+	}
+
+	return c.ConvertRelease(ctx, helmRelease, opts)
+}
+
+// ConvertRelease writes the chart and values of an already decoded release. helmRelease is not modified.
+func (c *Client) ConvertRelease(ctx context.Context, helmRelease *helmrelease.Release, opts ConvertOptions) (*ConversionResult, error) {
+	opts, err := checkConvertOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun {
+		for _, warning := range validateRelease(helmRelease) {
+			fmt.Fprintln(os.Stderr, "Warning:", warning)
+		}
+		return newConversionResult(helmRelease), nil
+	}
+
+	if opts.OutputFs != nil {
+		return c.convertReleaseToFs(ctx, helmRelease, opts)
+	}
+
+	// the options below modify the chart and values, the caller's release is left as it was decoded
+	helmRelease, err = convertibleRelease(helmRelease)
+	if err != nil {
+		return nil, err
+	}
+
 	dstDir := "."
+	if opts.DestDir != "" {
+		dstDir = opts.DestDir
+		if err := prepareDestDir(dstDir); err != nil {
+			return nil, errors.Wrap(err, "prepare output dir")
+		}
+	}
+
+	if opts.Subchart != "" && opts.RenderCheck {
+		return nil, errors.New("render check compares with the umbrella chart manifest and can't be used for a subchart")
+	}
+
+	if opts.Subchart != "" && (opts.ChartVersion != "" || opts.AppVersion != "" || opts.MetadataPatchFile != "") {
+		return nil, errors.New("chart and app version overrides and metadata patches apply to the release chart and can't be used for a subchart")
+	}
+
+	if opts.ValuesOnly && (opts.Bundle || opts.Sign || opts.Lint || opts.RenderCheck || opts.RepoIndex || opts.Subchart != "") {
+		return nil, errors.New("values only can't be combined with a bundle, signing, lint, render check, repo index or subchart")
+	}
+	if opts.ChartDir && (opts.Bundle || opts.Sign || opts.StreamPackage || opts.RepoIndex || opts.ExpectDigest != "") {
+		return nil, errors.New("a chart dir can't be combined with a bundle, signing, stream packaging, repo index or expected digest")
+	}
+	if opts.ValuesOnly && opts.ExpectDigest != "" {
+		return nil, errors.New("values only writes no chart to compare with the expected digest")
+	}
+	if opts.ValuesOnly && opts.ValuesMode == ValuesModeNone {
+		return nil, errors.New("values only needs a values mode other than none")
+	}
+	if opts.AnnotateOverrides && opts.ValuesMode != ValuesModeComputed {
+		return nil, errors.New("annotating user overrides needs the computed values mode")
+	}
+	switch opts.KeepTemp {
+	case "", KeepTempNever, KeepTempOnFailure, KeepTempAlways:
+	default:
+		return nil, errors.Errorf("unknown keep temp mode %q, use %s, %s or %s", opts.KeepTemp, KeepTempNever, KeepTempOnFailure, KeepTempAlways)
+	}
+	if opts.CompressionLevel != nil && (*opts.CompressionLevel < gzip.NoCompression || *opts.CompressionLevel > gzip.BestCompression) {
+		return nil, errors.Errorf("invalid compression level %d, use 0 to 9", *opts.CompressionLevel)
+	}
+	if err := checkFilePatterns(append(append([]string{}, opts.IncludeFiles...), opts.ExcludeFiles...)); err != nil {
+		return nil, err
+	}
+
+	if opts.DumpReleaseFile != "" {
+		// dump the release as stored, before any option modifies it
+		if err := dumpRelease(helmRelease, opts.DumpReleaseFile, opts.RedactDumpValues, valuesFileMode(opts)); err != nil {
+			return nil, errors.Wrap(err, "dump release")
+		}
+	}
+
+	for _, warning := range validateRelease(helmRelease) {
+		fmt.Fprintln(os.Stderr, "Warning:", warning)
+	}
+
+	if opts.SecurityCheck {
+		findings, err := securityFindings(helmRelease.Manifest)
+		if err != nil {
+			return nil, errors.Wrap(err, "security check")
+		}
+		for _, finding := range findings {
+			fmt.Fprintln(os.Stderr, "Security check:", finding)
+		}
+	}
+
+	if opts.SplitManifestDir != "" {
+		if err := writeManifestResources(helmRelease.Manifest, opts.SplitManifestDir); err != nil {
+			return nil, errors.Wrap(err, "split manifest")
+		}
+	}
+
+	if opts.ManifestFile != "" {
+		// the manifest can hold secrets, write it like the values file
+		if err := ioutil.WriteFile(opts.ManifestFile, []byte(helmRelease.Manifest), valuesFileMode(opts)); err != nil {
+			return nil, errors.Wrap(err, "write manifest")
+		}
+	}
+
+	if opts.ImageListFile != "" {
+		if err := writeImageList(helmRelease, opts.ImageListFile, valuesFileMode(opts)); err != nil {
+			return nil, errors.Wrap(err, "write image list")
+		}
+	}
+
+	if opts.HooksDir != "" {
+		if err := writeHooks(helmRelease.Hooks, opts.HooksDir, valuesFileMode(opts)); err != nil {
+			return nil, errors.Wrap(err, "write hooks")
+		}
+	}
+
+	if len(opts.LiveValueFields) > 0 {
+		if helmRelease.Config == nil {
+			helmRelease.Config = map[string]interface{}{}
+		}
+		if err := c.applyLiveValues(ctx, helmRelease, opts.LiveValueFields); err != nil {
+			return nil, errors.Wrap(err, "apply live values")
+		}
+	}
+
+	if opts.PinImages {
+		if err := c.pinImages(ctx, helmRelease); err != nil {
+			return nil, errors.Wrap(err, "pin images")
+		}
+	}
+
+	if opts.ReportFile != "" {
+		if err := writeReport(helmRelease, opts.ReportFile, opts.ReportFormat); err != nil {
+			return nil, errors.Wrap(err, "write report")
+		}
+	}
+
+	if opts.ChartVersion != "" {
+		helmRelease.Chart.Metadata.Version = opts.ChartVersion
+	}
+	if opts.AppVersion != "" {
+		helmRelease.Chart.Metadata.AppVersion = opts.AppVersion
+	}
+	if opts.MetadataPatchFile != "" {
+		if err := patchChartMetadata(helmRelease.Chart.Metadata, opts.MetadataPatchFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.ArtifactHub {
+		if err := addArtifactHubAnnotations(helmRelease); err != nil {
+			return nil, errors.Wrap(err, "add artifact hub annotations")
+		}
+	}
+
+	if opts.ValuesOnly {
+		if err := checkOverwrite(filepath.Join(dstDir, "values.yaml"), opts.Overwrite); err != nil {
+			return nil, err
+		}
+		if opts.DumpNotes {
+			if err := checkOverwrite(filepath.Join(dstDir, NotesFile), opts.Overwrite); err != nil {
+				return nil, err
+			}
+		}
+		valuesFile, err := writeValuesFile(helmRelease, dstDir, opts)
+		if err != nil {
+			return nil, err
+		}
+		result := newConversionResult(helmRelease)
+		if valuesFile != "" {
+			result.ValuesPath = filepath.Join(dstDir, filepath.Base(valuesFile))
+		}
+		if opts.DumpNotes {
+			result.NotesPath, err = writeNotesFile(helmRelease, dstDir, opts)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+
+	if opts.StripHooks {
+		for _, warning := range stripHooks(helmRelease) {
+			fmt.Fprintln(os.Stderr, "Warning:", warning)
+		}
+	}
+
+	if len(opts.IncludeFiles) > 0 || len(opts.ExcludeFiles) > 0 {
+		removed := filterChartFiles(helmRelease.Chart, "", opts.IncludeFiles, opts.ExcludeFiles)
+		if !opts.ChartDir {
+			fmt.Fprintf(os.Stderr, "Warning: %d chart files were filtered out with --include or --exclude, the packaged chart may not install. Use --format dir for a chart to review\n", removed)
+		}
+	}
+
+	if isV1Chart(helmRelease.Chart) {
+		if opts.MigrateAPIVersion {
+			debugf("migrating chart %s from apiVersion v1 to v2", helmRelease.Chart.Name())
+			migrateChartAPIVersion(helmRelease.Chart)
+		} else if len(helmRelease.Chart.Metadata.Dependencies) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: chart %s has the deprecated apiVersion v1 and dependencies, which may need migrating to Chart.yaml. requirements.yaml is not regenerated, use --migrate-apiversion to convert the chart to apiVersion v2\n", helmRelease.Chart.Name())
+		}
+	}
+
+	if opts.Canonical {
+		canonicalizeChart(helmRelease.Chart)
+	}
+
+	if err := checkChartNameAndVersion(helmRelease.Chart.Metadata); err != nil {
+		return nil, err
+	}
+	if err := checkChartVersions(helmRelease.Chart, ""); err != nil {
+		return nil, err
+	}
+
+	// files are written to a staging dir first, so existing files are only replaced as a whole and with opts.Overwrite
+	stagingDir, err := ioutil.TempDir(dstDir, ".release2chart-")
+	if err != nil {
+		return nil, errors.Wrap(err, "create staging dir")
+	}
+	defer os.RemoveAll(stagingDir)
+	debugf("staging output in %s", stagingDir)
+
+	var chartFile string
+	if opts.StreamPackage {
+		chartFile, err = streamReleasePackage(helmRelease, stagingDir, opts)
+	} else {
+		chartFile, helmRelease, err = packageRelease(helmRelease, stagingDir, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Sign {
+		if err := signChart(chartFile, opts); err != nil {
+			return nil, errors.Wrap(err, "sign chart")
+		}
+	}
+
+	if opts.RenderCheck {
+		convertedChart, err := loader.Load(chartFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "load converted chart")
+		}
+
+		mismatched, err := renderCheck(helmRelease, convertedChart)
+		if err != nil {
+			return nil, errors.Wrap(err, "render check")
+		}
+		if len(mismatched) > 0 {
+			return nil, errors.Errorf("converted chart renders differently from the release in: %s", strings.Join(mismatched, ", "))
+		}
+	}
+
+	valuesFile, err := writeValuesFile(helmRelease, stagingDir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newConversionResult(helmRelease)
+	if !opts.ChartDir {
+		result.Digest, err = ChartDigest(chartFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.ExpectDigest != "" && !strings.EqualFold(result.Digest, opts.ExpectDigest) {
+		return nil, errors.Errorf("chart digest %s doesn't match the expected digest %s", result.Digest, opts.ExpectDigest)
+	}
+
+	outputFiles := []*string{&result.ChartPath, &result.ValuesPath, &result.ProvenancePath}
+	if opts.Bundle {
+		bundleValuesFile := ""
+		if valuesFile != "" {
+			bundleValuesFile = filepath.Base(valuesFile)
+		}
+		bundleFile, err := writeReleaseBundle(helmRelease, stagingDir, filepath.Base(chartFile), bundleValuesFile, valuesFileMode(opts), opts.Reproducible)
+		if err != nil {
+			return nil, errors.Wrap(err, "write release bundle")
+		}
+		result.ChartPath = bundleFile
+		outputFiles = outputFiles[:1]
+	} else {
+		result.ChartPath = filepath.Base(chartFile)
+		if valuesFile != "" {
+			result.ValuesPath = filepath.Base(valuesFile)
+		}
+		if opts.Sign {
+			result.ProvenancePath = result.ChartPath + ".prov"
+		}
+	}
+	if opts.DumpNotes {
+		notesFile, err := writeNotesFile(helmRelease, stagingDir, opts)
+		if err != nil {
+			return nil, err
+		}
+		if notesFile != "" {
+			result.NotesPath = filepath.Base(notesFile)
+			outputFiles = append(outputFiles, &result.NotesPath)
+		}
+	}
+
+	// check all files before moving any, so a refused conversion leaves no partial output
+	for _, file := range outputFiles {
+		if *file == "" {
+			continue
+		}
+		if err := checkOverwrite(filepath.Join(dstDir, *file), opts.Overwrite); err != nil {
+			return nil, err
+		}
+	}
+	for _, file := range outputFiles {
+		if *file == "" {
+			continue
+		}
+		if opts.ChartDir && file == &result.ChartPath {
+			// a dir can't be renamed over an existing one
+			if err := os.RemoveAll(filepath.Join(dstDir, *file)); err != nil {
+				return nil, errors.Wrapf(err, "remove %s", *file)
+			}
+		}
+		if err := os.Rename(filepath.Join(stagingDir, *file), filepath.Join(dstDir, *file)); err != nil {
+			return nil, errors.Wrapf(err, "move %s", *file)
+		}
+		*file = filepath.Join(dstDir, *file)
+	}
+
+	if opts.RepoIndex {
+		if err := updateRepoIndex(dstDir); err != nil {
+			return nil, errors.Wrap(err, "update repo index")
+		}
+	}
+
+	return result, nil
+}
+
+// writeValuesFile writes the release values selected by opts.ValuesMode to values.yaml in dstDir.
+// It returns the file name, or an empty string if there are no values.
+func writeValuesFile(helmRelease *helmrelease.Release, dstDir string, opts ConvertOptions) (string, error) {
+	config, err := outputValues(helmRelease, opts)
+	if err != nil {
+		return "", err
+	}
+	if config == nil {
+		return "", nil
+	}
 
-	clientSet, err := GetClientset()
+	valuesFile := filepath.Join(dstDir, "values.yaml")
+
+	var overrides map[string]interface{}
+	if opts.AnnotateOverrides {
+		overrides = helmRelease.Config
+	}
+	configData, err := marshalValues(config, opts.NormalizeValues || opts.Canonical, overrides)
 	if err != nil {
-		return "", "", errors.Wrap(err, "get clientset")
+		return "", errors.Wrap(err, "marshal config data")
+	}
+
+	if err = ioutil.WriteFile(valuesFile, configData, valuesFileMode(opts)); err != nil {
+		return "", errors.Wrap(err, "write values file")
+	}
+
+	return valuesFile, nil
+}
+
+// outputValues returns the values to write in the values file, or nil if no values file is written.
+func outputValues(helmRelease *helmrelease.Release, opts ConvertOptions) (map[string]interface{}, error) {
+	config, err := releaseValues(helmRelease, opts.ValuesMode)
+	if err != nil {
+		return nil, errors.Wrap(err, "get release values")
+	}
+	if len(opts.UnsetValues) > 0 {
+		config = copyValues(config)
+		for _, path := range opts.UnsetValues {
+			found, err := unsetValue(config, path)
+			if err != nil {
+				return nil, errors.Wrap(err, "unset value")
+			}
+			if !found {
+				fmt.Fprintf(os.Stderr, "Warning: value %s to unset not found\n", path)
+			}
+		}
+	}
+	if opts.Rename != "" && opts.Rename != helmRelease.Name {
+		if len(opts.UnsetValues) == 0 {
+			config = copyValues(config)
+		}
+		for _, path := range renameReleaseValues(config, helmRelease.Name, opts.Rename, "") {
+			fmt.Fprintf(os.Stderr, "Renamed release %s to %s in value %s\n", helmRelease.Name, opts.Rename, path)
+		}
+	}
+
+	if len(config) == 0 && (!opts.AlwaysWriteValues || opts.ValuesMode == ValuesModeNone) {
+		return nil, nil
+	}
+	if config == nil {
+		config = map[string]interface{}{}
 	}
+	return config, nil
+}
 
-	selectorLabels := map[string]string{
-		"owner":   "helm",
-		"name":    releaseName,
-		"version": strconv.Itoa(revision),
+// checkConvertOptions validates opts and returns them with the options other options imply set.
+func checkConvertOptions(opts ConvertOptions) (ConvertOptions, error) {
+	if opts.ChartVersion != "" {
+		if _, err := semver.StrictNewVersion(opts.ChartVersion); err != nil {
+			return opts, errors.Wrapf(err, "invalid chart version %q", opts.ChartVersion)
+		}
 	}
-	listOpts := metav1.ListOptions{
-		LabelSelector: labels.SelectorFromSet(selectorLabels).String(),
+
+	if opts.ExpectDigest != "" {
+		if !expectedDigestPattern.MatchString(opts.ExpectDigest) {
+			return opts, errors.Errorf("invalid expected digest %q, expected sha256:<hex>", opts.ExpectDigest)
+		}
+		opts.Reproducible = true
+	}
+
+	return opts, nil
+}
+
+// convertibleRelease returns a copy of helmRelease that the conversion can modify. It fails if the release
+// has no chart metadata to name the chart.
+func convertibleRelease(helmRelease *helmrelease.Release) (*helmrelease.Release, error) {
+	if helmRelease.Chart == nil || helmRelease.Chart.Metadata == nil {
+		return nil, errors.New("release has no chart metadata")
+	}
+	return copyRelease(helmRelease), nil
+}
+
+// ConvertReleaseVersionToBytes converts a release without writing to disk. It returns the chart archive
+// and the values file, which is nil if the release has no values.
+func (c *Client) ConvertReleaseVersionToBytes(ctx context.Context, namespace string, releaseName string, revision int) ([]byte, []byte, error) {
+	helmRelease, err := c.GetRelease(ctx, namespace, releaseName, revision)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "get release")
 	}
 
-	secrets, err := clientSet.CoreV1().Secrets(namespace).List(context.TODO(), listOpts)
+	return releaseToBytes(helmRelease, ConvertOptions{})
+}
+
+// releaseToBytes packages helmRelease in memory, see ConvertReleaseVersionToBytes. helmRelease is not modified.
+func releaseToBytes(helmRelease *helmrelease.Release, opts ConvertOptions) ([]byte, []byte, error) {
+	opts, err := checkConvertOptions(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	helmRelease, err = convertibleRelease(helmRelease)
 	if err != nil {
-		return "", "", errors.Wrap(err, "list secrets")
+		return nil, nil, err
+	}
+
+	var chartData bytes.Buffer
+	if err := writeReleaseArchive(&chartData, helmRelease, helmRelease.Chart.Metadata.Name, opts); err != nil {
+		return nil, nil, errors.Wrap(err, "write chart archive")
+	}
+	if _, err := loader.LoadArchive(bytes.NewReader(chartData.Bytes())); err != nil {
+		return nil, nil, errors.Wrap(err, "load packaged chart")
 	}
 
-	if len(secrets.Items) != 1 {
-		return "", "", errors.Errorf("found %d matching releases", len(secrets.Items))
+	if len(helmRelease.Config) == 0 {
+		return chartData.Bytes(), nil, nil
 	}
 
-	helmRelease, err := helmReleaseFromReleaseData(secrets.Items[0].Data["release"])
+	valuesData, err := marshalValues(helmRelease.Config, false, nil)
 	if err != nil {
-		return "", "", errors.Wrap(err, "parse release info from secret")
+		return nil, nil, errors.Wrap(err, "marshal config data")
 	}
 
-	releaseDir, err := ioutil.TempDir("", "helm-release-")
+	return chartData.Bytes(), valuesData, nil
+}
+
+// marshalValues renders the values file. Keys set in overrides are annotated, see annotateOverrides.
+func marshalValues(values map[string]interface{}, normalize bool, overrides map[string]interface{}) ([]byte, error) {
+	if len(overrides) > 0 {
+		return marshalAnnotatedValues(values, normalize, overrides)
+	}
+	if normalize {
+		return marshalNormalizedValues(values)
+	}
+	return yaml.Marshal(values)
+}
+
+// packageRelease unpacks the release chart into a temp dir and packages it into dstDir.
+// It returns the release of the packaged chart, which differs from helmRelease when packaging a subchart.
+func packageRelease(helmRelease *helmrelease.Release, dstDir string, opts ConvertOptions) (_ string, _ *helmrelease.Release, err error) {
+	releaseDir, err := ioutil.TempDir(opts.TempDir, "helm-release-")
 	if err != nil {
-		return "", "", errors.Wrap(err, "create temp dir")
+		return "", nil, errors.Wrap(err, "create temp dir")
 	}
-	defer os.RemoveAll(releaseDir)
+	defer func() { removeTempDir(releaseDir, opts.KeepTemp, err != nil) }()
+	debugf("unpacking chart to %s", releaseDir)
 
-	if err := saveReleaseToFiles(helmRelease, releaseDir); err != nil {
-		return "", "", errors.Wrap(err, "save release to files")
+	if err := saveReleaseToFiles(afero.NewOsFs(), helmRelease, releaseDir, opts); err != nil {
+		return "", nil, errors.Wrap(err, "save release to files")
 	}
 
+	if opts.RebuildDeps {
+		if err := rebuildDependencies(releaseDir); err != nil {
+			return "", nil, errors.Wrap(err, "rebuild dependencies")
+		}
+	}
+
+	if opts.DependencyUpdate {
+		// action.Package only records DependencyUpdate, the update itself is left to the caller
+		if err := updateDependencies(releaseDir); err != nil {
+			return "", nil, errors.Wrap(err, "update dependencies")
+		}
+	}
+
+	chartDir := releaseDir
+	if opts.Subchart != "" {
+		if !opts.RebuildDeps && !opts.DependencyUpdate {
+			if err := rebuildDependencies(releaseDir); err != nil {
+				return "", nil, errors.Wrap(err, "download dependencies")
+			}
+		}
+
+		chartDir, helmRelease, err = extractSubchart(helmRelease, releaseDir, opts.Subchart)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "extract subchart")
+		}
+	}
+
+	if opts.BuildMetadata != "" {
+		if err := setBuildMetadata(chartDir, helmRelease.Chart.Metadata, opts.BuildMetadata); err != nil {
+			return "", nil, errors.Wrap(err, "set build metadata")
+		}
+	}
+
+	if opts.StrictRoundtrip {
+		reconstructed, err := loader.LoadDir(chartDir)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "load reconstructed chart")
+		}
+		if missing := missingDependencies(reconstructed); len(missing) > 0 {
+			return "", nil, errors.Errorf("dependencies missing from the converted chart: %s", strings.Join(missing, ", "))
+		}
+	}
+
+	if opts.Lint {
+		if err := lintChart(chartDir, helmRelease); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if opts.ChartDir {
+		dirName := filepath.Join(dstDir, helmRelease.Chart.Metadata.Name)
+		if err := copyChartDir(chartDir, dirName); err != nil {
+			return "", nil, errors.Wrap(err, "copy chart dir")
+		}
+		debugf("copied %s %s to %s", helmRelease.Chart.Metadata.Name, helmRelease.Chart.Metadata.Version, dirName)
+		return dirName, helmRelease, nil
+	}
+
+	chartFile := ""
+	// the helm packager has no compression level, archives with one are written like the ones with custom entries
+	if opts.ArchiveRoot != "" || hasCustomFileModes(opts) || opts.Reproducible || opts.CompressionLevel != nil {
+		archiveRoot := opts.ArchiveRoot
+		if archiveRoot == "" {
+			archiveRoot = helmRelease.Chart.Metadata.Name
+		}
+		chartFile, err = packageChartDir(chartDir, dstDir, helmRelease.Chart.Metadata, archiveRoot, opts.Reproducible, compressionLevel(opts))
+		if err != nil {
+			return "", nil, errors.Wrap(err, "package chart")
+		}
+	} else {
+		chartFile, err = newPackageAction(dstDir, opts).Run(chartDir, nil)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "package client run")
+		}
+	}
+
+	packaged, err := loader.LoadFile(chartFile)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "load packaged chart")
+	}
+	if err := checkPackagedFiles(packaged, helmRelease.Chart); err != nil {
+		return "", nil, err
+	}
+	debugf("packaged %s %s with %d templates and %d files to %s", packaged.Metadata.Name, packaged.Metadata.Version, len(packaged.Templates), len(packaged.Files), chartFile)
+
+	return chartFile, helmRelease, nil
+}
+
+// newPackageAction returns the Helm package action that packages the chart to dstDir.
+func newPackageAction(dstDir string, opts ConvertOptions) *action.Package {
 	client := action.NewPackage()
 	client.Destination = dstDir
+	client.DependencyUpdate = opts.DependencyUpdate
+	return client
+}
+
+// convertReleaseToFs converts the release into a staging dir and copies the results to opts.OutputFs.
+func (c *Client) convertReleaseToFs(ctx context.Context, helmRelease *helmrelease.Release, opts ConvertOptions) (_ *ConversionResult, err error) {
+	if opts.RepoIndex {
+		return nil, errors.New("repo index can't be updated on an output filesystem")
+	}
+	if opts.ChartDir {
+		return nil, errors.New("a chart dir can't be written to an output filesystem")
+	}
 
-	chartFile, err := client.Run(releaseDir, nil)
+	stagingDir, err := ioutil.TempDir(opts.TempDir, "helm-output-")
 	if err != nil {
-		return "", "", errors.Wrap(err, "package client run")
+		return nil, errors.Wrap(err, "create staging dir")
 	}
+	defer func() { removeTempDir(stagingDir, opts.KeepTemp, err != nil) }()
 
-	valuesFile := ""
-	if len(helmRelease.Config) != 0 {
-		valuesFile = filepath.Join(dstDir, "values.yaml")
+	stagingOpts := opts
+	stagingOpts.OutputFs = nil
+	stagingOpts.DestDir = stagingDir
+
+	result, err := c.ConvertRelease(ctx, helmRelease, stagingOpts)
+	if err != nil {
+		return nil, err
+	}
 
-		configData, err := yaml.Marshal(helmRelease.Config)
+	dstDir := "."
+	if opts.DestDir != "" {
+		dstDir = opts.DestDir
+	}
+	if err := opts.OutputFs.MkdirAll(dstDir, 0755); err != nil {
+		return nil, errors.Wrap(err, "create dest dir")
+	}
+
+	stagedPaths := []*string{&result.ChartPath, &result.ValuesPath, &result.ProvenancePath, &result.NotesPath}
+	for _, stagedPath := range stagedPaths {
+		if *stagedPath == "" || opts.Overwrite {
+			continue
+		}
+		file := filepath.Join(dstDir, filepath.Base(*stagedPath))
+		exists, err := afero.Exists(opts.OutputFs, file)
 		if err != nil {
-			return "", "", errors.Wrap(err, "marshal config data")
+			return nil, errors.Wrapf(err, "stat %s", file)
+		}
+		if exists {
+			return nil, errors.Errorf("%s already exists, use --overwrite to replace it", file)
+		}
+	}
+
+	for _, stagedPath := range stagedPaths {
+		if *stagedPath == "" {
+			continue
 		}
 
-		if err = ioutil.WriteFile(valuesFile, configData, 0644); err != nil {
-			return "", "", errors.Wrap(err, "write values file")
+		name := filepath.Base(*stagedPath)
+		fileName := filepath.Join(stagingDir, name)
+		info, err := os.Stat(fileName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "stat %s", name)
+		}
+		data, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read %s", name)
 		}
+		if err := afero.WriteFile(opts.OutputFs, filepath.Join(dstDir, name), data, info.Mode().Perm()); err != nil {
+			return nil, errors.Wrapf(err, "write %s", name)
+		}
+		*stagedPath = filepath.Join(dstDir, name)
 	}
 
-	return filepath.Base(chartFile), filepath.Base(valuesFile), nil
+	return result, nil
 }
 
-func helmReleaseFromReleaseData(data []byte) (*helmrelease.Release, error) {
-	base64Reader := base64.NewDecoder(base64.StdEncoding, bytes.NewReader(data))
-	gzreader, err := gzip.NewReader(base64Reader)
+func valuesFileMode(opts ConvertOptions) os.FileMode {
+	if opts.ValuesFileMode == 0 {
+		return 0600
+	}
+	return opts.ValuesFileMode
+}
+
+// prepareDestDir creates dir if needed and checks that files can be written to it.
+func prepareDestDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrap(err, "create dir")
+	}
+	return checkDirWritable(dir)
+}
+
+// checkOverwrite fails if file exists, unless overwrite is set.
+func checkOverwrite(file string, overwrite bool) error {
+	if overwrite {
+		return nil
+	}
+
+	_, err := os.Stat(file)
+	if err == nil {
+		return errors.Errorf("%s already exists, use --overwrite to replace it", file)
+	}
+	if !os.IsNotExist(err) {
+		return errors.Wrapf(err, "stat %s", file)
+	}
+	return nil
+}
+
+func checkDirWritable(dir string) error {
+	f, err := ioutil.TempFile(dir, ".release2chart-")
 	if err != nil {
-		return nil, errors.Wrap(err, "create gzip reader")
+		return errors.Wrapf(err, "%s is not writable", dir)
 	}
-	defer gzreader.Close()
+	f.Close()
+	return os.Remove(f.Name())
+}
 
-	releaseData, err := ioutil.ReadAll(gzreader)
+// releaseFromStorage decodes the release stored under releaseKey of the storage object, or split across
+// numbered chunk keys.
+func releaseFromStorage(stored *storedRelease, releaseKey string) (*helmrelease.Release, error) {
+	data, ok := stored.Data[releaseKey]
+	if !ok {
+		chunks, err := releaseChunks(stored.Data, releaseKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reassemble %s %s", stored.Kind, stored.Name)
+		}
+		data, ok = chunks, chunks != nil
+	}
+	if !ok {
+		keys := []string{}
+		for key := range stored.Data {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return nil, errors.Errorf("%s %s has no %q key, use --release-key to select one of: %s", stored.Kind, stored.Name, releaseKey, strings.Join(keys, ", "))
+	}
+
+	helmRelease, err := helmReleaseFromReleaseData(data)
 	if err != nil {
-		return nil, errors.Wrap(err, "read from gzip reader")
+		revision, _ := strconv.Atoi(stored.Labels["version"])
+		return nil, &CorruptReleaseError{Kind: stored.Kind, Name: stored.Name, Revision: revision, Err: err}
 	}
+	return helmRelease, nil
+}
 
-	release := &helmrelease.Release{}
-	err = json.Unmarshal(releaseData, &release)
+// CorruptReleaseError is returned when the data of a stored release can't be decoded, e.g. because it was truncated.
+// Commands that read many releases skip them with a warning instead of failing.
+type CorruptReleaseError struct {
+	Kind     string
+	Name     string
+	Revision int
+	Err      error
+}
+
+func (e *CorruptReleaseError) Error() string {
+	return fmt.Sprintf("decode %s %s (revision %d): %v", e.Kind, e.Name, e.Revision, e.Err)
+}
+
+func (e *CorruptReleaseError) Unwrap() error {
+	return e.Err
+}
+
+// isCorruptRelease reports whether err was caused by release data that can't be decoded.
+func isCorruptRelease(err error) bool {
+	corrupt := &CorruptReleaseError{}
+	return errors.As(err, &corrupt)
+}
+
+// DecodeRelease decodes the release data stored by Helm in the "release" key of a release secret.
+// Besides Helm's base64 encoded gzip, it accepts raw gzip and data that was base64 encoded twice,
+// e.g. the secret value printed by kubectl get -o jsonpath='{.data.release}'.
+func DecodeRelease(data []byte) (*helmrelease.Release, error) {
+	return helmReleaseFromReleaseData(normalizeReleaseData(data))
+}
+
+// gzipMagic starts every gzip stream, base64GzipPrefix starts its base64 encoding.
+var (
+	gzipMagic        = []byte{0x1f, 0x8b}
+	base64GzipPrefix = []byte("H4sI")
+)
+
+// normalizeReleaseData returns data in the encoding Helm stores releases in, base64 encoded gzip.
+// Data in an encoding it doesn't recognize is returned as is.
+func normalizeReleaseData(data []byte) []byte {
+	if bytes.HasPrefix(data, gzipMagic) {
+		encoded := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+		base64.StdEncoding.Encode(encoded, data)
+		return encoded
+	}
+
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, base64GzipPrefix) {
+		return data
+	}
+
+	decoded := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(decoded, data)
+	if err == nil && bytes.HasPrefix(decoded[:n], base64GzipPrefix) {
+		return bytes.TrimSpace(decoded[:n])
+	}
+	return data
+}
+
+func helmReleaseFromReleaseData(data []byte) (*helmrelease.Release, error) {
+	releaseJSON, err := readReleaseJSON(data)
 	if err != nil {
+		return nil, err
+	}
+	if len(releaseJSON) > 0 && releaseJSON[0] != '{' {
+		return nil, releaseEncodingError(releaseJSON[0])
+	}
+
+	release := &helmrelease.Release{}
+	err = json.Unmarshal(releaseJSON, &release)
+	if syntaxErr, ok := err.(*json.SyntaxError); ok && syntaxErr.Offset == int64(len(releaseJSON)) {
+		return nil, errors.New("release data is truncated")
+	}
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+		return nil, errors.Wrap(unsupportedSchemaError(typeErr.Error()), "unmarshal release data")
+	} else if err != nil {
 		return nil, errors.Wrap(err, "unmarshal release data")
 	}
 
+	if err := checkReleaseSchema(releaseJSON, release); err != nil {
+		return nil, errors.Wrap(err, "check release schema")
+	}
+
 	return release, nil
 }
 
-func saveReleaseToFiles(release *helmrelease.Release, destDir string) error {
-	type chartFile struct {
-		Name string
-		Data []byte
+// releaseEncodingError describes release data that decompressed to something other than Helm 3 JSON.
+// Helm 2 stored releases as gzipped protobuf hapi.release.Release messages, which start with the
+// tag of the name field.
+func releaseEncodingError(firstByte byte) error {
+	if firstByte == 0x0a {
+		return errors.New("release data is a Helm 2 (Tiller) protobuf release, which is not supported; migrate it to Helm 3 with the helm-2to3 plugin first")
+	}
+	return errors.Errorf("release data is not a Helm 3 JSON release, it starts with byte 0x%02x", firstByte)
+}
+
+const (
+	// maxDeflateRatio bounds how many times larger than its compressed size deflated data can be.
+	maxDeflateRatio = 1032
+	// maxReleasePrealloc caps the buffer allocated up front from the gzip trailer, larger releases grow it.
+	// The trailer is not checked until the data is decompressed, so it can't make a small secret allocate
+	// more than this.
+	maxReleasePrealloc = 4 << 20
+)
+
+// readReleaseJSON returns the decompressed JSON of base64 encoded, gzipped release data. The buffer
+// is sized from the length recorded in the gzip trailer, up to maxReleasePrealloc, so that most releases,
+// which can be many times larger than the secret, are held in memory once instead of in a buffer grown
+// by doubling.
+func readReleaseJSON(data []byte) ([]byte, error) {
+	compressed := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(compressed, data)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode base64")
+	}
+	compressed = compressed[:n]
+
+	gzreader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, errors.Wrap(err, "create gzip reader")
+	}
+	defer gzreader.Close()
+
+	// the trailer holds the size modulo 2^32, don't trust a size the data can't decompress to
+	size := 0
+	if len(compressed) >= 4 {
+		trailerSize := int(binary.LittleEndian.Uint32(compressed[len(compressed)-4:]))
+		if trailerSize <= maxDeflateRatio*len(compressed) {
+			size = trailerSize
+		}
+	}
+	if size > maxReleasePrealloc {
+		size = maxReleasePrealloc
+	}
+	// ReadFrom grows the buffer unless MinRead bytes are left for the read that returns EOF
+	buf := bytes.NewBuffer(make([]byte, 0, size+bytes.MinRead))
+	_, err = buf.ReadFrom(gzreader)
+	if err == io.ErrUnexpectedEOF {
+		return nil, errors.New("release data is truncated")
+	} else if err != nil {
+		return nil, errors.Wrap(err, "decompress release data")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// saveReleaseToFiles unpacks the release chart into destDir. If opts.Canonical is set,
+// Chart.yaml and values.yaml are written with sorted keys.
+func saveReleaseToFiles(fs afero.Fs, release *helmrelease.Release, destDir string, opts ConvertOptions) error {
+	files, err := releaseChartFiles(release, opts)
+	if err != nil {
+		return errors.Wrap(err, "collect chart files")
+	}
+	if opts.MaxChartSize > 0 {
+		if err := checkChartSize(files, opts.MaxChartSize); err != nil {
+			return err
+		}
+	}
+
+	for _, chartFile := range files {
+		if err := checkChartFileName(chartFile.Name); err != nil {
+			return err
+		}
+
+		fileName := filepath.Join(destDir, chartFile.Name)
+		dir := filepath.Dir(fileName)
+		if err := fs.MkdirAll(dir, 0755); err != nil {
+			return errors.Wrapf(err, "create dir %s", dir)
+		}
+
+		mode := chartFileMode(chartFile.Name, opts)
+		if err := afero.WriteFile(fs, fileName, chartFile.Data, mode); err != nil {
+			return errors.Wrapf(err, "write file %s", fileName)
+		}
+		// WriteFile modes are subject to the umask
+		if err := fs.Chmod(fileName, mode); err != nil {
+			return errors.Wrapf(err, "set mode of %s", fileName)
+		}
 	}
 
+	return nil
+}
+
+type chartFile struct {
+	Name string
+	Data []byte
+}
+
+// releaseChartFiles returns the files of the release chart, relative to the chart dir.
+// The top-level values.yaml and values.schema.json are left out with opts.NoChartValues and opts.NoSchema.
+func releaseChartFiles(release *helmrelease.Release, opts ConvertOptions) ([]chartFile, error) {
+	files, err := chartFiles(release.Chart, opts.Canonical)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := []chartFile{}
+	for _, file := range files {
+		if (opts.NoChartValues && file.Name == "values.yaml") || (opts.NoSchema && file.Name == "values.schema.json") {
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept, nil
+}
+
+// chartFiles returns the files of c relative to its chart dir, including its dependencies under charts/<name>/.
+// Releases stored by Helm don't include dependencies, but charts passed to ConvertRelease may.
+func chartFiles(c *chart.Chart, canonical bool) ([]chartFile, error) {
 	files := []chartFile{}
-	for _, file := range release.Chart.Files {
+	// Files include the CRDs under crds/, which Helm keeps apart from the templates
+	for _, file := range c.Files {
 		files = append(files, chartFile{
 			Name: file.Name,
 			Data: file.Data,
 		})
 	}
 
-	for _, template := range release.Chart.Templates {
+	for _, template := range c.Templates {
 		files = append(files, chartFile{
 			Name: template.Name,
 			Data: template.Data,
 		})
 	}
 
-	chartMetadata, err := yaml.Marshal(release.Chart.Metadata)
+	var chartMetadata []byte
+	var err error
+	if canonical {
+		chartMetadata, err = marshalCanonicalMetadata(c.Metadata)
+	} else {
+		// Metadata only has json tags, marshal it like chartutil.SaveDir does
+		chartMetadata, err = sigsyaml.Marshal(c.Metadata)
+	}
 	if err != nil {
-		return errors.Wrap(err, "marshal chart metadata")
+		return nil, errors.Wrap(err, "marshal chart metadata")
 	}
 	files = append(files, chartFile{
 		Name: "Chart.yaml",
 		Data: chartMetadata,
 	})
 
-	chartValues, err := yaml.Marshal(release.Chart.Values)
+	values := c.Values
+	if values == nil {
+		// values.yaml must hold a map, null defaults break templates reading .Values
+		values = map[string]interface{}{}
+	}
+	var chartValues []byte
+	if canonical {
+		chartValues, err = marshalNormalizedValues(values)
+	} else {
+		chartValues, err = yaml.Marshal(values)
+	}
 	if err != nil {
-		return errors.Wrap(err, "marshal chart values")
+		return nil, errors.Wrap(err, "marshal chart values")
 	}
 	files = append(files, chartFile{
 		Name: "values.yaml",
 		Data: chartValues,
 	})
 
-	chartValuesSchema, err := json.Marshal(release.Chart.Schema)
-	if err != nil {
-		return errors.Wrap(err, "marshal chart values schema")
+	// Schema holds the raw schema file, marshalling it would base64 encode it.
+	// A null schema isn't a schema, helm would fail to validate values against it.
+	if schema := bytes.TrimSpace(c.Schema); len(schema) > 0 && string(schema) != "null" {
+		files = append(files, chartFile{
+			Name: "values.schema.json",
+			Data: c.Schema,
+		})
 	}
-	files = append(files, chartFile{
-		Name: "values.schema.json",
-		Data: chartValuesSchema,
-	})
 
-	for _, chartFile := range files {
-		fileName := filepath.Join(destDir, chartFile.Name)
-		dir := filepath.Dir(fileName)
-		if err := os.MkdirAll(dir, 0755); err != nil {
-			return errors.Wrapf(err, "create dir %s", dir)
+	for _, dependency := range c.Dependencies() {
+		dependencyFiles, err := chartFiles(dependency, canonical)
+		if err != nil {
+			return nil, errors.Wrapf(err, "collect files of dependency %s", dependency.Name())
 		}
-
-		if err := ioutil.WriteFile(fileName, chartFile.Data, 0644); err != nil {
-			return errors.Wrapf(err, "write file %s", fileName)
+		for _, file := range dependencyFiles {
+			file.Name = path.Join("charts", dependency.Name(), file.Name)
+			files = append(files, file)
 		}
 	}
 
-	return nil
+	return files, nil
 }
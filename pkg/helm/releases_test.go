@@ -0,0 +1,600 @@
+package helm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	goruntime "runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/afero"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+	helmtime "helm.sh/helm/v3/pkg/time"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// testRelease returns a revision of release app in namespace ns. Its config records the revision.
+func testRelease(revision int, status helmrelease.Status) *helmrelease.Release {
+	return &helmrelease.Release{
+		Name:      "app",
+		Namespace: "ns",
+		Version:   revision,
+		Info: &helmrelease.Info{
+			Status:       status,
+			LastDeployed: helmtime.Unix(int64(revision)*3600, 0),
+		},
+		Chart: &chart.Chart{
+			Metadata: &chart.Metadata{
+				APIVersion: chart.APIVersionV2,
+				Name:       "app",
+				Version:    fmt.Sprintf("0.%d.0", revision),
+				AppVersion: "1.0",
+			},
+			Templates: []*chart.File{
+				{Name: "templates/configmap.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Release.Name }}\n")},
+			},
+			Values: map[string]interface{}{"replicas": 1},
+		},
+		Config: map[string]interface{}{"revision": revision},
+	}
+}
+
+// releaseSecrets returns the secrets Helm stores the releases in, created an hour apart in the order given.
+func releaseSecrets(t testing.TB, releases ...*helmrelease.Release) []runtime.Object {
+	t.Helper()
+
+	objects := []runtime.Object{}
+	for i, release := range releases {
+		secret, err := ReleaseSecret(release)
+		if err != nil {
+			t.Fatalf("encode release %s revision %d: %v", release.Name, release.Version, err)
+		}
+		secret.CreationTimestamp = metav1.NewTime(time.Unix(int64(i)*3600, 0))
+		objects = append(objects, secret)
+	}
+	return objects
+}
+
+// fakeReleaseClient returns a client that reads releases from a fake clientset holding objects.
+func fakeReleaseClient(objects ...runtime.Object) *Client {
+	client := NewClient()
+	client.Clientset = fake.NewSimpleClientset(objects...)
+	client.Storage = StorageSecret
+	return client
+}
+
+func TestFindLatestReleaseVersion(t *testing.T) {
+	client := fakeReleaseClient(releaseSecrets(t,
+		testRelease(1, helmrelease.StatusSuperseded),
+		testRelease(2, helmrelease.StatusDeployed),
+		testRelease(3, helmrelease.StatusFailed),
+		testRelease(4, helmrelease.StatusPendingUpgrade),
+	)...)
+
+	tests := []struct {
+		name           string
+		status         helmrelease.Status
+		includePending bool
+		want           int
+	}{
+		{name: "any status", want: 4},
+		{name: "deployed", status: helmrelease.StatusDeployed, want: 2},
+		{name: "superseded", status: helmrelease.StatusSuperseded, want: 1},
+		{name: "failed", status: helmrelease.StatusFailed, want: 3},
+		{name: "deployed or pending", status: helmrelease.StatusDeployed, includePending: true, want: 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := client.FindLatestReleaseVersion(context.Background(), "ns", "app", test.status, test.includePending)
+			if err != nil {
+				t.Fatalf("FindLatestReleaseVersion: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("got revision %d, want %d", got, test.want)
+			}
+		})
+	}
+
+	if _, err := client.FindLatestReleaseVersion(context.Background(), "ns", "app", helmrelease.StatusUninstalled, false); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("uninstalled revision: got error %v, want revision not found", err)
+	}
+	if _, err := client.FindLatestReleaseVersion(context.Background(), "ns", "other", "", false); !errors.Is(err, ErrReleaseNotFound) {
+		t.Errorf("other release: got error %v, want release not found", err)
+	}
+	if _, err := client.FindLatestReleaseVersion(context.Background(), "other", "app", "", false); !errors.Is(err, ErrReleaseNotFound) {
+		t.Errorf("other namespace: got error %v, want release not found", err)
+	}
+}
+
+func TestGetReleaseDuplicateRevision(t *testing.T) {
+	older := testRelease(2, helmrelease.StatusFailed)
+	newer := testRelease(2, helmrelease.StatusDeployed)
+	newer.Config["copy"] = "newer"
+
+	objects := releaseSecrets(t, testRelease(1, helmrelease.StatusSuperseded), older, newer)
+	// a failed rollback leaves a second object for the revision behind
+	objects[2].(metav1.Object).SetName(objects[2].(metav1.Object).GetName() + "-copy")
+	client := fakeReleaseClient(objects...)
+
+	got, err := client.GetRelease(context.Background(), "ns", "app", 2)
+	if err != nil {
+		t.Fatalf("GetRelease: %v", err)
+	}
+	if got.Config["copy"] != "newer" {
+		t.Errorf("got release config %v, want the newest copy of revision 2", got.Config)
+	}
+
+	client.Strict = true
+	if _, err := client.GetRelease(context.Background(), "ns", "app", 2); err == nil {
+		t.Error("GetRelease with strict revisions: got no error for a duplicate revision")
+	}
+	if _, err := client.GetRelease(context.Background(), "ns", "app", 1); err != nil {
+		t.Errorf("GetRelease with strict revisions: got error %v for a unique revision", err)
+	}
+}
+
+func TestConvertReleaseVersionRoundTrip(t *testing.T) {
+	client := fakeReleaseClient(releaseSecrets(t,
+		testRelease(1, helmrelease.StatusSuperseded),
+		testRelease(2, helmrelease.StatusDeployed),
+	)...)
+
+	dir := t.TempDir()
+	result, err := client.ConvertReleaseVersion(context.Background(), "ns", "app", 2, ConvertOptions{DestDir: dir})
+	if err != nil {
+		t.Fatalf("ConvertReleaseVersion: %v", err)
+	}
+
+	if want := filepath.Join(dir, "app-0.2.0.tgz"); result.ChartPath != want {
+		t.Errorf("got chart path %s, want %s", result.ChartPath, want)
+	}
+	converted, err := loader.Load(result.ChartPath)
+	if err != nil {
+		t.Fatalf("load converted chart: %v", err)
+	}
+	if converted.Metadata.Name != "app" || converted.Metadata.Version != "0.2.0" || converted.Metadata.AppVersion != "1.0" {
+		t.Errorf("got chart metadata %+v, want app 0.2.0 with app version 1.0", converted.Metadata)
+	}
+	if len(converted.Templates) != 1 || converted.Templates[0].Name != "templates/configmap.yaml" {
+		t.Errorf("got templates %v, want templates/configmap.yaml", converted.Templates)
+	}
+	if converted.Values["replicas"] != float64(1) {
+		t.Errorf("got chart values %v, want the chart defaults", converted.Values)
+	}
+
+	data, err := ioutil.ReadFile(result.ValuesPath)
+	if err != nil {
+		t.Fatalf("read values file: %v", err)
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		t.Fatalf("unmarshal values file: %v", err)
+	}
+	if values["revision"] != 2 {
+		t.Errorf("got values %v, want the config of revision 2", values)
+	}
+}
+
+func TestEncodeRelease(t *testing.T) {
+	data, err := EncodeRelease(testRelease(7, helmrelease.StatusDeployed))
+	if err != nil {
+		t.Fatalf("EncodeRelease: %v", err)
+	}
+
+	decoded, err := DecodeRelease(data)
+	if err != nil {
+		t.Fatalf("DecodeRelease: %v", err)
+	}
+	if decoded.Name != "app" || decoded.Version != 7 || decoded.Config["revision"] != float64(7) {
+		t.Errorf("got release %s revision %d with config %v, want app revision 7", decoded.Name, decoded.Version, decoded.Config)
+	}
+}
+
+func TestConvertReleaseSchema(t *testing.T) {
+	// the schema is written as it was stored, keeping its key order and formatting
+	schema := []byte("{\n  \"$schema\": \"https://json-schema.org/draft-07/schema#\",\n  \"type\": \"object\",\n  \"properties\": {\n    \"replicas\": {\"type\": \"integer\", \"minimum\": 1}\n  }\n}\n")
+
+	withSchema := testRelease(1, helmrelease.StatusDeployed)
+	withSchema.Chart.Schema = schema
+	data, err := EncodeRelease(withSchema)
+	if err != nil {
+		t.Fatalf("EncodeRelease: %v", err)
+	}
+	decoded, err := DecodeRelease(data)
+	if err != nil {
+		t.Fatalf("DecodeRelease: %v", err)
+	}
+
+	for _, opts := range []ConvertOptions{{}, {ChartDir: true}} {
+		opts.DestDir = t.TempDir()
+		result, err := NewClient().ConvertRelease(context.Background(), decoded, opts)
+		if err != nil {
+			t.Fatalf("ConvertRelease: %v", err)
+		}
+
+		var got []byte
+		if opts.ChartDir {
+			got, err = ioutil.ReadFile(filepath.Join(result.ChartPath, "values.schema.json"))
+			if err != nil {
+				t.Fatalf("read values.schema.json: %v", err)
+			}
+		} else {
+			got = archiveFile(t, result.ChartPath, "values.schema.json")
+		}
+		if !bytes.Equal(got, schema) {
+			t.Errorf("chart dir %t: got values.schema.json\n%s\nwant\n%s", opts.ChartDir, got, schema)
+		}
+	}
+
+	for _, empty := range [][]byte{nil, []byte("null")} {
+		noSchema := testRelease(1, helmrelease.StatusDeployed)
+		noSchema.Chart.Schema = empty
+		result, err := NewClient().ConvertRelease(context.Background(), noSchema, ConvertOptions{DestDir: t.TempDir(), ChartDir: true})
+		if err != nil {
+			t.Fatalf("ConvertRelease: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(result.ChartPath, "values.schema.json")); !os.IsNotExist(err) {
+			t.Errorf("schema %q: got values.schema.json, want none (stat error %v)", empty, err)
+		}
+	}
+}
+
+func TestConvertReleaseVersionToBytes(t *testing.T) {
+	client := fakeReleaseClient(releaseSecrets(t, testRelease(1, helmrelease.StatusDeployed))...)
+
+	chartData, valuesData, err := client.ConvertReleaseVersionToBytes(context.Background(), "ns", "app", 1)
+	if err != nil {
+		t.Fatalf("ConvertReleaseVersionToBytes: %v", err)
+	}
+	converted, err := loader.LoadArchive(bytes.NewReader(chartData))
+	if err != nil {
+		t.Fatalf("load chart archive: %v", err)
+	}
+	if converted.Metadata.Name != "app" || converted.Metadata.Version != "0.1.0" {
+		t.Errorf("got chart metadata %+v, want app 0.1.0", converted.Metadata)
+	}
+	if string(valuesData) != "revision: 1\n" {
+		t.Errorf("got values %q, want the release config", valuesData)
+	}
+
+	noMetadata := testRelease(2, helmrelease.StatusDeployed)
+	noMetadata.Chart.Metadata = nil
+	_, _, err = releaseToBytes(noMetadata, ConvertOptions{})
+	if err == nil || err.Error() != "release has no chart metadata" {
+		t.Errorf("got error %v for a release without chart metadata, want release has no chart metadata", err)
+	}
+}
+
+func TestConvertReleaseStrictRoundtrip(t *testing.T) {
+	release := testRelease(1, helmrelease.StatusDeployed)
+	release.Chart.Metadata.Dependencies = []*chart.Dependency{
+		{Name: "common", Version: "1.0.0", Repository: "https://charts.example.com"},
+		{Name: "redis", Version: "17.0.0", Repository: "oci://registry.example.com/charts"},
+	}
+	release.Chart.AddDependency(&chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "common", Version: "1.0.0"},
+	})
+
+	_, err := NewClient().ConvertRelease(context.Background(), release, ConvertOptions{DestDir: t.TempDir(), StrictRoundtrip: true})
+	if err == nil {
+		t.Fatal("ConvertRelease with strict roundtrip: got no error for a missing dependency")
+	}
+	if !strings.HasSuffix(err.Error(), "dependencies missing from the converted chart: redis") {
+		t.Errorf("got error %q, want redis listed as the only missing dependency", err)
+	}
+}
+
+func TestGetReleaseCustomReleaseKey(t *testing.T) {
+	objects := releaseSecrets(t, testRelease(1, helmrelease.StatusDeployed))
+	secret := objects[0].(*corev1.Secret)
+	secret.Data = map[string][]byte{"payload": secret.Data["release"]}
+	client := fakeReleaseClient(objects...)
+	client.ReleaseKey = "payload"
+
+	got, err := client.GetRelease(context.Background(), "ns", "app", 1)
+	if err != nil {
+		t.Fatalf("GetRelease: %v", err)
+	}
+	if got.Name != "app" || got.Version != 1 {
+		t.Errorf("got release %s revision %d, want app revision 1", got.Name, got.Version)
+	}
+
+	client.ReleaseKey = "release"
+	_, err = client.GetRelease(context.Background(), "ns", "app", 1)
+	if err == nil {
+		t.Fatal("GetRelease: got no error for a secret without the release key")
+	}
+	if !strings.Contains(err.Error(), `no "release" key, use --release-key to select one of: payload`) {
+		t.Errorf("got error %q, want it to suggest the payload key", err)
+	}
+}
+
+func TestSaveReleaseToFilesMemFs(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	if err := saveReleaseToFiles(fs, testRelease(1, helmrelease.StatusDeployed), "/chart", ConvertOptions{}); err != nil {
+		t.Fatalf("saveReleaseToFiles: %v", err)
+	}
+
+	for _, name := range []string{"Chart.yaml", "values.yaml", "templates/configmap.yaml"} {
+		exists, err := afero.Exists(fs, filepath.Join("/chart", name))
+		if err != nil {
+			t.Fatalf("stat %s: %v", name, err)
+		}
+		if !exists {
+			t.Errorf("%s was not written to the filesystem", name)
+		}
+	}
+
+	data, err := afero.ReadFile(fs, "/chart/templates/configmap.yaml")
+	if err != nil {
+		t.Fatalf("read template: %v", err)
+	}
+	if want := testRelease(1, helmrelease.StatusDeployed).Chart.Templates[0].Data; !bytes.Equal(data, want) {
+		t.Errorf("got template\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestConvertReleaseOutputFs(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	destDir := filepath.Join(t.TempDir(), "out")
+	opts := ConvertOptions{DestDir: destDir, OutputFs: fs}
+
+	result, err := NewClient().ConvertRelease(context.Background(), testRelease(2, helmrelease.StatusDeployed), opts)
+	if err != nil {
+		t.Fatalf("ConvertRelease: %v", err)
+	}
+
+	if want := filepath.Join(destDir, "app-0.2.0.tgz"); result.ChartPath != want {
+		t.Errorf("got chart path %s, want %s", result.ChartPath, want)
+	}
+	if _, err := os.Stat(destDir); !os.IsNotExist(err) {
+		t.Errorf("dest dir was created on disk (stat error %v)", err)
+	}
+
+	archive, err := fs.Open(result.ChartPath)
+	if err != nil {
+		t.Fatalf("open chart archive: %v", err)
+	}
+	defer archive.Close()
+	converted, err := loader.LoadArchive(archive)
+	if err != nil {
+		t.Fatalf("load chart archive: %v", err)
+	}
+	if converted.Metadata.Name != "app" || converted.Metadata.Version != "0.2.0" {
+		t.Errorf("got chart %s %s, want app 0.2.0", converted.Metadata.Name, converted.Metadata.Version)
+	}
+
+	data, err := afero.ReadFile(fs, result.ValuesPath)
+	if err != nil {
+		t.Fatalf("read values file: %v", err)
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		t.Fatalf("unmarshal values file: %v", err)
+	}
+	if values["revision"] != 2 {
+		t.Errorf("got values %v, want the config of revision 2", values)
+	}
+
+	if _, err := NewClient().ConvertRelease(context.Background(), testRelease(2, helmrelease.StatusDeployed), opts); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("second ConvertRelease: got error %v, want the existing chart to be kept", err)
+	}
+	opts.Overwrite = true
+	if _, err := NewClient().ConvertRelease(context.Background(), testRelease(2, helmrelease.StatusDeployed), opts); err != nil {
+		t.Errorf("ConvertRelease with overwrite: %v", err)
+	}
+}
+
+func TestConvertReleaseUnsetValues(t *testing.T) {
+	release := testRelease(1, helmrelease.StatusDeployed)
+	release.Config = map[string]interface{}{
+		"database": map[string]interface{}{"host": "db.prod", "password": "secret"},
+		"replicas": 3,
+	}
+
+	result, err := NewClient().ConvertRelease(context.Background(), release, ConvertOptions{DestDir: t.TempDir(), UnsetValues: []string{"database.password"}})
+	if err != nil {
+		t.Fatalf("ConvertRelease: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(result.ValuesPath)
+	if err != nil {
+		t.Fatalf("read values file: %v", err)
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		t.Fatalf("unmarshal values file: %v", err)
+	}
+	want := map[string]interface{}{"database": map[string]interface{}{"host": "db.prod"}, "replicas": 3}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("got values %v, want %v", values, want)
+	}
+	if _, ok := release.Config["database"].(map[string]interface{})["password"]; !ok {
+		t.Error("the unset value was removed from the release config")
+	}
+}
+
+// largeReleaseData returns a release with a manifest of about 32MB, encoded like Helm stores it.
+func largeReleaseData(b *testing.B) []byte {
+	b.Helper()
+
+	release := testRelease(1, helmrelease.StatusDeployed)
+	var manifest strings.Builder
+	for i := 0; manifest.Len() < 32<<20; i++ {
+		fmt.Fprintf(&manifest, "---\n# Source: app/templates/configmap.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app-%d\ndata:\n  key: value-%d\n", i, i)
+	}
+	release.Manifest = manifest.String()
+
+	data, err := EncodeRelease(release)
+	if err != nil {
+		b.Fatalf("EncodeRelease: %v", err)
+	}
+	return data
+}
+
+// releaseDataReader returns the decompressed JSON of base64 encoded, gzipped release data.
+func releaseDataReader(data []byte) (io.ReadCloser, error) {
+	base64Reader := base64.NewDecoder(base64.StdEncoding, bytes.NewReader(data))
+	gzreader, err := gzip.NewReader(base64Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "create gzip reader")
+	}
+	return gzreader, nil
+}
+
+// BenchmarkDecodeRelease compares the allocations of DecodeRelease, which decompresses the release into a
+// buffer sized from the gzip trailer up to maxReleasePrealloc, with decoding from the gzip stream and with
+// reading it all first.
+func BenchmarkDecodeRelease(b *testing.B) {
+	data := largeReleaseData(b)
+
+	b.Run("sized buffer", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := DecodeRelease(data); err != nil {
+				b.Fatalf("DecodeRelease: %v", err)
+			}
+		}
+	})
+
+	b.Run("json decoder", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			reader, err := releaseDataReader(data)
+			if err != nil {
+				b.Fatalf("releaseDataReader: %v", err)
+			}
+			release := &helmrelease.Release{}
+			err = json.NewDecoder(reader).Decode(release)
+			reader.Close()
+			if err != nil {
+				b.Fatalf("decode release: %v", err)
+			}
+		}
+	})
+
+	b.Run("read all", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			reader, err := releaseDataReader(data)
+			if err != nil {
+				b.Fatalf("releaseDataReader: %v", err)
+			}
+			decompressed, err := ioutil.ReadAll(reader)
+			reader.Close()
+			if err != nil {
+				b.Fatalf("read release data: %v", err)
+			}
+			release := &helmrelease.Release{}
+			if err := json.Unmarshal(decompressed, release); err != nil {
+				b.Fatalf("unmarshal release: %v", err)
+			}
+		}
+	})
+}
+
+func TestDecodeReleaseTruncated(t *testing.T) {
+	releaseJSON, err := json.Marshal(testRelease(1, helmrelease.StatusDeployed))
+	if err != nil {
+		t.Fatalf("marshal release: %v", err)
+	}
+
+	tests := map[string][]byte{
+		// the JSON is cut off, the gzip stream is complete
+		"json": encodeReleaseJSON(t, string(releaseJSON[:len(releaseJSON)/2])),
+	}
+	data, err := EncodeRelease(testRelease(1, helmrelease.StatusDeployed))
+	if err != nil {
+		t.Fatalf("EncodeRelease: %v", err)
+	}
+	// the gzip stream is cut off, keeping whole base64 quanta
+	tests["gzip"] = data[:len(data)/2/4*4]
+
+	for name, data := range tests {
+		if _, err := DecodeRelease(data); err == nil || err.Error() != "release data is truncated" {
+			t.Errorf("truncated %s: got error %v, want release data is truncated", name, err)
+		}
+	}
+}
+
+func TestDecodeReleaseForgedSize(t *testing.T) {
+	// random data doesn't compress, so the trailer may claim up to maxDeflateRatio times its size
+	payload := make([]byte, 64<<10)
+	rand.New(rand.NewSource(1)).Read(payload)
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	gzipWriter.Write(payload)
+	gzipWriter.Close()
+	forged := compressed.Bytes()
+	binary.LittleEndian.PutUint32(forged[len(forged)-4:], uint32(maxDeflateRatio*len(forged)))
+	data := []byte(base64.StdEncoding.EncodeToString(forged))
+
+	var before, after goruntime.MemStats
+	goruntime.ReadMemStats(&before)
+	if _, err := DecodeRelease(data); err == nil {
+		t.Error("DecodeRelease: got no error for a gzip trailer with the wrong size")
+	}
+	goruntime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 16<<20 {
+		t.Errorf("allocated %d bytes for %d bytes of release data, want the trailer size to be capped", allocated, len(data))
+	}
+}
+
+func TestConvertReleaseDoesNotModifyRelease(t *testing.T) {
+	newRelease := func() *helmrelease.Release {
+		release := dependencyRelease()
+		release.Chart.Templates = append(release.Chart.Templates,
+			&chart.File{Name: "templates/job.yaml", Data: []byte("apiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n  annotations:\n    \"helm.sh/hook\": pre-install\n")},
+			&chart.File{Name: "templates/a.yaml", Data: []byte("apiVersion: v1\r\nkind: ConfigMap\r\nmetadata:\r\n  name: a\r\n")},
+		)
+		release.Chart.Files = []*chart.File{{Name: "files/b.conf", Data: []byte("b=true\r\n")}, {Name: "files/a.conf", Data: []byte("a=true\n")}}
+		release.Chart.Metadata.Annotations = map[string]string{"owner": "team"}
+		release.Chart.Metadata.Keywords = []string{"app"}
+		return release
+	}
+
+	release := newRelease()
+	opts := ConvertOptions{
+		DestDir:      t.TempDir(),
+		ChartVersion: "2.0.0",
+		AppVersion:   "2.0",
+		StripHooks:   true,
+		Canonical:    true,
+		ArtifactHub:  true,
+		ExcludeFiles: []string{"files/a.conf"},
+	}
+	result, err := NewClient().ConvertRelease(context.Background(), release, opts)
+	if err != nil {
+		t.Fatalf("ConvertRelease: %v", err)
+	}
+	if result.ChartVersion != "2.0.0" {
+		t.Errorf("got chart version %s, want 2.0.0", result.ChartVersion)
+	}
+	if !reflect.DeepEqual(release, newRelease()) {
+		t.Error("ConvertRelease modified the release")
+	}
+
+	release.Chart.Metadata = nil
+	if _, err := NewClient().ConvertRelease(context.Background(), release, ConvertOptions{DestDir: t.TempDir(), ChartVersion: "2.0.0"}); err == nil {
+		t.Error("got no error for a release without chart metadata")
+	}
+}
@@ -0,0 +1,160 @@
+package helm
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+const sourceCommentPrefix = "# Source: "
+
+// renderReleaseChart renders c with the values of the release, the same way Helm does on install.
+// .Release.Name, .Release.Namespace and .Release.Revision are taken from the decoded release,
+// so the output is comparable to the stored manifest.
+// Resources and hooks are returned keyed by their template path.
+func renderReleaseChart(release *helmrelease.Release, c *chart.Chart) (map[string]string, map[string]string, error) {
+	releaseOptions := chartutil.ReleaseOptions{
+		Name:      release.Name,
+		Namespace: release.Namespace,
+		Revision:  release.Version,
+		IsInstall: release.Version == 1,
+		IsUpgrade: release.Version > 1,
+	}
+
+	caps, err := releaseCapabilities(release)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "get release capabilities")
+	}
+
+	values, err := chartutil.ToRenderValues(c, release.Config, releaseOptions, caps)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "create render values")
+	}
+
+	files, err := engine.Render(c, values)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "render chart")
+	}
+
+	for name := range files {
+		if strings.HasSuffix(name, "NOTES.txt") {
+			delete(files, name)
+		}
+	}
+
+	hooks, manifests, err := releaseutil.SortManifests(files, caps.APIVersions, releaseutil.InstallOrder)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "sort manifests")
+	}
+
+	rendered := map[string][]string{}
+	for _, manifest := range manifests {
+		rendered[manifest.Name] = append(rendered[manifest.Name], manifest.Content)
+	}
+
+	renderedHooks := map[string][]string{}
+	for _, hook := range hooks {
+		renderedHooks[hook.Path] = append(renderedHooks[hook.Path], hook.Manifest)
+	}
+
+	return joinDocuments(rendered), joinDocuments(renderedHooks), nil
+}
+
+// releaseCapabilities returns the default capabilities extended with the API versions of the
+// resources in the stored manifest. Templates of custom resources are often guarded by
+// .Capabilities.APIVersions.Has, and the API was available when the release was deployed.
+func releaseCapabilities(release *helmrelease.Release) (*chartutil.Capabilities, error) {
+	resources, err := parseManifest(release.Manifest)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse manifest")
+	}
+
+	caps := *chartutil.DefaultCapabilities
+	caps.APIVersions = append(chartutil.VersionSet{}, chartutil.DefaultVersionSet...)
+	for _, resource := range resources {
+		if resource.APIVersion == "" || caps.APIVersions.Has(resource.APIVersion+"/"+resource.Kind) {
+			continue
+		}
+		caps.APIVersions = append(caps.APIVersions, resource.APIVersion, resource.APIVersion+"/"+resource.Kind)
+	}
+
+	return &caps, nil
+}
+
+// storedReleaseManifests returns the resources and hooks stored in the release, keyed by their template path.
+func storedReleaseManifests(release *helmrelease.Release) (map[string]string, map[string]string) {
+	stored := map[string][]string{}
+	for _, doc := range releaseutil.SplitManifests(release.Manifest) {
+		source, content := splitSourceComment(doc)
+		stored[source] = append(stored[source], content)
+	}
+
+	storedHooks := map[string][]string{}
+	for _, hook := range release.Hooks {
+		storedHooks[hook.Path] = append(storedHooks[hook.Path], hook.Manifest)
+	}
+
+	return joinDocuments(stored), joinDocuments(storedHooks)
+}
+
+// renderCheck renders c as the release and returns the template paths whose output differs from the stored release.
+func renderCheck(release *helmrelease.Release, c *chart.Chart) ([]string, error) {
+	rendered, renderedHooks, err := renderReleaseChart(release, c)
+	if err != nil {
+		return nil, errors.Wrap(err, "render release chart")
+	}
+
+	stored, storedHooks := storedReleaseManifests(release)
+
+	mismatched := append(differentDocuments(stored, rendered), differentDocuments(storedHooks, renderedHooks)...)
+	sort.Strings(mismatched)
+
+	return mismatched, nil
+}
+
+func differentDocuments(a map[string]string, b map[string]string) []string {
+	different := []string{}
+	for name, content := range a {
+		if b[name] != content {
+			different = append(different, name)
+		}
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			different = append(different, name)
+		}
+	}
+	return different
+}
+
+func splitSourceComment(doc string) (string, string) {
+	doc = strings.TrimSpace(doc)
+	if !strings.HasPrefix(doc, sourceCommentPrefix) {
+		return "", doc
+	}
+
+	source, content, _ := strings.Cut(strings.TrimPrefix(doc, sourceCommentPrefix), "\n")
+	return strings.TrimSpace(source), content
+}
+
+// joinDocuments normalizes the documents rendered from each template so they can be compared.
+func joinDocuments(docs map[string][]string) map[string]string {
+	joined := map[string]string{}
+	for name, contents := range docs {
+		trimmed := []string{}
+		for _, content := range contents {
+			if content = strings.TrimSpace(content); content != "" {
+				trimmed = append(trimmed, content)
+			}
+		}
+		sort.Strings(trimmed)
+		joined[name] = strings.Join(trimmed, "\n---\n")
+	}
+	return joined
+}
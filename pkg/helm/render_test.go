@@ -0,0 +1,71 @@
+package helm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// identityRelease returns a release of a chart whose ConfigMap records the release identity.
+func identityRelease(manifest string) *helmrelease.Release {
+	release := testRelease(3, helmrelease.StatusDeployed)
+	release.Name = "web"
+	release.Namespace = "prod"
+	release.Chart.Templates = []*chart.File{{
+		Name: "templates/configmap.yaml",
+		Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Release.Name }}\n  namespace: {{ .Release.Namespace }}\ndata:\n  revision: {{ .Release.Revision | quote }}\n"),
+	}}
+	release.Manifest = manifest
+	return release
+}
+
+func TestRenderReleaseChartIdentity(t *testing.T) {
+	release := identityRelease("")
+
+	rendered, _, err := renderReleaseChart(release, release.Chart)
+	if err != nil {
+		t.Fatalf("renderReleaseChart: %v", err)
+	}
+
+	got := rendered["app/templates/configmap.yaml"]
+	for _, want := range []string{"name: web", "namespace: prod", "revision: \"3\""} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered configmap has no %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestConvertReleaseRenderCheck(t *testing.T) {
+	deployed := "---\n# Source: app/templates/configmap.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n  namespace: prod\ndata:\n  revision: \"3\"\n"
+	if _, err := NewClient().ConvertRelease(context.Background(), identityRelease(deployed), ConvertOptions{DestDir: t.TempDir(), RenderCheck: true}); err != nil {
+		t.Errorf("ConvertRelease: got error %v for a chart that renders the stored manifest", err)
+	}
+
+	// the manifest of another revision of the release differs in the rendered revision only
+	otherRevision := strings.Replace(deployed, `revision: "3"`, `revision: "2"`, 1)
+	_, err := NewClient().ConvertRelease(context.Background(), identityRelease(otherRevision), ConvertOptions{DestDir: t.TempDir(), RenderCheck: true})
+	if err == nil || !strings.Contains(err.Error(), "app/templates/configmap.yaml") {
+		t.Errorf("ConvertRelease: got error %v, want a render check mismatch in app/templates/configmap.yaml", err)
+	}
+}
+
+func TestConvertReleaseCustomResource(t *testing.T) {
+	template := "{{- if .Capabilities.APIVersions.Has \"cert-manager.io/v1/Certificate\" }}\napiVersion: cert-manager.io/v1\nkind: Certificate\nmetadata:\n  name: {{ .Release.Name }}-tls\nspec:\n  secretName: {{ .Release.Name }}-tls\n  dnsNames:\n  - {{ .Values.host }}\n{{- end }}\n"
+
+	release := testRelease(1, helmrelease.StatusDeployed)
+	release.Chart.Templates = []*chart.File{{Name: "templates/certificate.yaml", Data: []byte(template)}}
+	release.Config = map[string]interface{}{"host": "app.example.com"}
+	release.Manifest = "---\n# Source: app/templates/certificate.yaml\napiVersion: cert-manager.io/v1\nkind: Certificate\nmetadata:\n  name: app-tls\nspec:\n  secretName: app-tls\n  dnsNames:\n  - app.example.com\n"
+
+	result, err := NewClient().ConvertRelease(context.Background(), release, ConvertOptions{DestDir: t.TempDir(), RenderCheck: true})
+	if err != nil {
+		t.Fatalf("ConvertRelease: %v", err)
+	}
+
+	if got := archiveFile(t, result.ChartPath, "templates/certificate.yaml"); string(got) != template {
+		t.Errorf("got custom resource template\n%s\nwant\n%s", got, template)
+	}
+}
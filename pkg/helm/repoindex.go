@@ -0,0 +1,23 @@
+package helm
+
+import (
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// updateRepoIndex writes an index.yaml for all charts in dir, as `helm repo index` does.
+func updateRepoIndex(dir string) error {
+	index, err := repo.IndexDirectory(dir, "")
+	if err != nil {
+		return errors.Wrap(err, "index directory")
+	}
+	index.SortEntries()
+
+	if err := index.WriteFile(filepath.Join(dir, "index.yaml"), 0644); err != nil {
+		return errors.Wrap(err, "write index file")
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+const (
+	ReportFormatMarkdown = "md"
+	ReportFormatText     = "txt"
+)
+
+type reportField struct {
+	Name  string
+	Value string
+}
+
+// writeReport writes a human-readable summary of the release to fileName.
+func writeReport(release *helmrelease.Release, fileName string, format string) error {
+	var data []byte
+	var err error
+
+	switch format {
+	case "", ReportFormatMarkdown:
+		data, err = markdownReport(release)
+	case ReportFormatText:
+		data, err = textReport(release)
+	default:
+		return errors.Errorf("unsupported report format %q", format)
+	}
+	if err != nil {
+		return errors.Wrap(err, "generate report")
+	}
+
+	if err := ioutil.WriteFile(fileName, data, 0644); err != nil {
+		return errors.Wrap(err, "write report file")
+	}
+
+	return nil
+}
+
+func markdownReport(release *helmrelease.Release) ([]byte, error) {
+	fields, resourceCounts, err := reportData(release)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Release %s\n\n", release.Name)
+
+	fmt.Fprintln(&b, "| Field | Value |")
+	fmt.Fprintln(&b, "| --- | --- |")
+	for _, field := range fields {
+		fmt.Fprintf(&b, "| %s | %s |\n", field.Name, field.Value)
+	}
+
+	fmt.Fprintln(&b, "\n## Resources")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "| Kind | Count |")
+	fmt.Fprintln(&b, "| --- | --- |")
+	for _, count := range resourceCounts {
+		fmt.Fprintf(&b, "| %s | %s |\n", count.Name, count.Value)
+	}
+
+	if notes := releaseNotes(release); notes != "" {
+		fmt.Fprintln(&b, "\n## Notes")
+		fmt.Fprintln(&b)
+		fmt.Fprintln(&b, "```")
+		fmt.Fprintln(&b, strings.TrimRight(notes, "\n"))
+		fmt.Fprintln(&b, "```")
+	}
+
+	return b.Bytes(), nil
+}
+
+func textReport(release *helmrelease.Release) ([]byte, error) {
+	fields, resourceCounts, err := reportData(release)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Release %s\n\n", release.Name)
+	for _, field := range fields {
+		fmt.Fprintf(&b, "%s: %s\n", field.Name, field.Value)
+	}
+
+	fmt.Fprintln(&b, "\nResources:")
+	for _, count := range resourceCounts {
+		fmt.Fprintf(&b, "  %s: %s\n", count.Name, count.Value)
+	}
+
+	if notes := releaseNotes(release); notes != "" {
+		fmt.Fprintln(&b, "\nNotes:")
+		fmt.Fprintln(&b, strings.TrimRight(notes, "\n"))
+	}
+
+	return b.Bytes(), nil
+}
+
+func reportData(release *helmrelease.Release) ([]reportField, []reportField, error) {
+	fields := []reportField{
+		{Name: "Name", Value: release.Name},
+		{Name: "Namespace", Value: release.Namespace},
+		{Name: "Revision", Value: fmt.Sprint(release.Version)},
+	}
+
+	if release.Info != nil {
+		fields = append(fields,
+			reportField{Name: "Status", Value: release.Info.Status.String()},
+			reportField{Name: "First deployed", Value: formatReportTime(release.Info.FirstDeployed.Time)},
+			reportField{Name: "Last deployed", Value: formatReportTime(release.Info.LastDeployed.Time)},
+			reportField{Name: "Description", Value: release.Info.Description},
+		)
+	}
+
+	if release.Chart != nil && release.Chart.Metadata != nil {
+		fields = append(fields,
+			reportField{Name: "Chart", Value: release.Chart.Metadata.Name},
+			reportField{Name: "Chart version", Value: release.Chart.Metadata.Version},
+			reportField{Name: "App version", Value: release.Chart.Metadata.AppVersion},
+		)
+	}
+
+	resources, err := parseManifest(release.Manifest)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "parse manifest")
+	}
+
+	counts := map[string]int{}
+	for _, resource := range resources {
+		counts[resource.Kind]++
+	}
+
+	kinds := []string{}
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	resourceCounts := []reportField{}
+	for _, kind := range kinds {
+		resourceCounts = append(resourceCounts, reportField{Name: kind, Value: fmt.Sprint(counts[kind])})
+	}
+
+	return fields, resourceCounts, nil
+}
+
+func releaseNotes(release *helmrelease.Release) string {
+	if release.Info == nil {
+		return ""
+	}
+	return release.Info.Notes
+}
+
+func formatReportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,40 @@
+package helm
+
+import (
+	"archive/tar"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// archiveModTime returns the modification time of archive entries. Reproducible archives use
+// SOURCE_DATE_EPOCH, or the Unix epoch if it's not set.
+func archiveModTime(reproducible bool) (time.Time, error) {
+	if !reproducible {
+		return time.Now(), nil
+	}
+
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Unix(0, 0).UTC(), nil
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "parse SOURCE_DATE_EPOCH")
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// makeReproducible clears the fields of a header taken from the file system that differ between runs.
+func makeReproducible(header *tar.Header, modTime time.Time) {
+	header.ModTime = modTime
+	header.AccessTime = time.Time{}
+	header.ChangeTime = time.Time{}
+	header.Uid = 0
+	header.Gid = 0
+	header.Uname = ""
+	header.Gname = ""
+	header.Format = tar.FormatPAX
+}
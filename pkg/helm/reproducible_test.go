@@ -0,0 +1,83 @@
+package helm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+func fileSHA256(t *testing.T, fileName string) [sha256.Size]byte {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("read %s: %v", fileName, err)
+	}
+	return sha256.Sum256(data)
+}
+
+func TestConvertReleaseReproducible(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+
+	tests := []struct {
+		name    string
+		release func() *helmrelease.Release
+		opts    ConvertOptions
+	}{
+		{name: "package", release: canonicalRelease, opts: ConvertOptions{Reproducible: true}},
+		{name: "stream package", release: canonicalRelease, opts: ConvertOptions{Reproducible: true, StreamPackage: true}},
+		{name: "dependencies", release: dependencyRelease, opts: ConvertOptions{Reproducible: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			convert := func() string {
+				opts := test.opts
+				opts.DestDir = t.TempDir()
+				result, err := NewClient().ConvertRelease(context.Background(), test.release(), opts)
+				if err != nil {
+					t.Fatalf("ConvertRelease: %v", err)
+				}
+				return result.ChartPath
+			}
+
+			first, second := convert(), convert()
+			if fileSHA256(t, first) != fileSHA256(t, second) {
+				t.Errorf("SHA256 of %s and %s differ", first, second)
+			}
+
+			f, err := os.Open(first)
+			if err != nil {
+				t.Fatalf("open chart archive: %v", err)
+			}
+			defer f.Close()
+			gzipReader, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatalf("read gzip: %v", err)
+			}
+			if !gzipReader.ModTime.IsZero() || gzipReader.Name != "" {
+				t.Errorf("gzip header has modification time %s and name %q, want none", gzipReader.ModTime, gzipReader.Name)
+			}
+			tarReader := tar.NewReader(gzipReader)
+			for {
+				header, err := tarReader.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("read tar: %v", err)
+				}
+				if !header.ModTime.Equal(time.Unix(1700000000, 0)) {
+					t.Errorf("%s has modification time %s, want SOURCE_DATE_EPOCH", header.Name, header.ModTime)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package helm
+
+import (
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// WorkloadResources are the requests and limits of one workload, for all of its pods.
+type WorkloadResources struct {
+	Kind string
+	Name string
+	// Pods is the number of pods the workload runs. DaemonSets count one pod per node.
+	Pods     int64
+	PerNode  bool
+	Requests corev1.ResourceList
+	Limits   corev1.ResourceList
+}
+
+// ResourceSummary totals CPU and memory requests and limits of the workloads in the manifest.
+// A pod needs the larger of its largest init container and the sum of its containers.
+func ResourceSummary(manifest string) ([]WorkloadResources, error) {
+	workloads := []WorkloadResources{}
+
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		doc := map[string]interface{}{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "decode manifest document")
+		}
+
+		spec := podSpec(doc)
+		if spec == nil {
+			continue
+		}
+
+		pod := corev1.PodSpec{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(spec, &pod); err != nil {
+			return nil, errors.Wrap(err, "convert pod spec")
+		}
+
+		kind, _ := doc["kind"].(string)
+		name, _ := nestedMap(doc, "metadata")["name"].(string)
+		workload := WorkloadResources{
+			Kind:    kind,
+			Name:    name,
+			Pods:    workloadPods(doc),
+			PerNode: kind == "DaemonSet",
+		}
+		workload.Requests = scaleResources(podResources(pod, func(r corev1.ResourceRequirements) corev1.ResourceList { return r.Requests }), workload.Pods)
+		workload.Limits = scaleResources(podResources(pod, func(r corev1.ResourceRequirements) corev1.ResourceList { return r.Limits }), workload.Pods)
+
+		workloads = append(workloads, workload)
+	}
+
+	return workloads, nil
+}
+
+// workloadPods returns the number of pods a workload runs at once.
+func workloadPods(doc map[string]interface{}) int64 {
+	var count interface{}
+	switch doc["kind"] {
+	case "Deployment", "StatefulSet", "ReplicaSet", "ReplicationController":
+		count = nestedMap(doc, "spec")["replicas"]
+	case "Job":
+		count = nestedMap(doc, "spec")["parallelism"]
+	case "CronJob":
+		count = nestedMap(doc, "spec", "jobTemplate", "spec")["parallelism"]
+	}
+
+	switch c := count.(type) {
+	case int:
+		return int64(c)
+	case int64:
+		return c
+	case float64:
+		return int64(c)
+	}
+	return 1
+}
+
+func podResources(pod corev1.PodSpec, list func(corev1.ResourceRequirements) corev1.ResourceList) corev1.ResourceList {
+	total := corev1.ResourceList{}
+	for _, container := range pod.Containers {
+		for name, quantity := range list(container.Resources) {
+			if current, ok := total[name]; ok {
+				current.Add(quantity)
+				total[name] = current
+			} else {
+				total[name] = quantity.DeepCopy()
+			}
+		}
+	}
+
+	for _, container := range pod.InitContainers {
+		for name, quantity := range list(container.Resources) {
+			if current, ok := total[name]; !ok || quantity.Cmp(current) > 0 {
+				total[name] = quantity.DeepCopy()
+			}
+		}
+	}
+
+	return total
+}
+
+func scaleResources(resources corev1.ResourceList, n int64) corev1.ResourceList {
+	scaled := corev1.ResourceList{}
+	for name, quantity := range resources {
+		total := resource.NewMilliQuantity(quantity.MilliValue()*n, quantity.Format)
+		if name != corev1.ResourceCPU {
+			total = resource.NewQuantity(quantity.Value()*n, quantity.Format)
+		}
+		scaled[name] = *total
+	}
+	return scaled
+}
@@ -0,0 +1,72 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/util/retry"
+)
+
+// retryTransient calls fn until it succeeds, fails with an error that isn't transient, or c.MaxRetries is reached.
+func (c *Client) retryTransient(ctx context.Context, description string, fn func() error) error {
+	backoff := wait.Backoff{
+		Steps:    c.MaxRetries + 1,
+		Duration: c.RetryDelay,
+		Factor:   2,
+		Jitter:   0.1,
+	}
+
+	attempt := 0
+	err := retry.OnError(backoff, func(err error) bool {
+		if ctx.Err() != nil || !isTransientError(err) {
+			return false
+		}
+		attempt++
+		if attempt <= c.MaxRetries {
+			fmt.Fprintf(os.Stderr, "Retrying %s (%d/%d): %v\n", description, attempt, c.MaxRetries, err)
+		}
+		return true
+	}, fn)
+	if err != nil && ctx.Err() == nil {
+		if timeout := c.requestTimeout(); timeout > 0 && isClientTimeout(err) {
+			return errors.Wrapf(err, "request timed out after %s, raise --request-timeout if the API server is slow", timeout)
+		}
+	}
+	return withPermissionHint(err)
+}
+
+// requestTimeout returns the --request-timeout of Kubernetes API calls, or 0 if they don't time out.
+func (c *Client) requestTimeout() time.Duration {
+	timeout, err := clientcmd.ParseTimeout(*c.ConfigFlags.Timeout)
+	if err != nil {
+		return 0
+	}
+	return timeout
+}
+
+// isClientTimeout reports whether err is a request that was cancelled by the client timeout.
+func isClientTimeout(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && urlErr.Timeout()
+}
+
+// isTransientError reports whether err is a server side or connection error that may succeed when retried.
+// Errors such as NotFound and Forbidden are not.
+func isTransientError(err error) bool {
+	return apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsInternalError(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsUnexpectedServerError(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsProbableEOF(err)
+}
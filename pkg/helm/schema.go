@@ -0,0 +1,101 @@
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chartutil"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// knownReleaseFields are the top-level JSON fields of a release stored by the supported Helm version.
+var knownReleaseFields = map[string]bool{
+	"name":      true,
+	"info":      true,
+	"chart":     true,
+	"config":    true,
+	"manifest":  true,
+	"hooks":     true,
+	"version":   true,
+	"namespace": true,
+}
+
+// checkReleaseSchema detects release JSON that was written by an unsupported Helm version,
+// which would otherwise decode into a partially empty release.
+func checkReleaseSchema(releaseJSON []byte, release *helmrelease.Release) error {
+	missing := []string{}
+	if release.Name == "" {
+		missing = append(missing, "name")
+	}
+	if release.Version == 0 {
+		missing = append(missing, "version")
+	}
+	if release.Info == nil {
+		missing = append(missing, "info")
+	}
+	if release.Chart == nil {
+		missing = append(missing, "chart")
+	} else if release.Chart.Metadata == nil {
+		missing = append(missing, "chart.metadata")
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	fields, err := releaseJSONFields(releaseJSON)
+	if err != nil {
+		return unsupportedSchemaError(err.Error())
+	}
+
+	unknown := []string{}
+	for _, field := range fields {
+		if !knownReleaseFields[field] {
+			unknown = append(unknown, field)
+		}
+	}
+	sort.Strings(unknown)
+
+	hints := []string{"missing fields: " + strings.Join(missing, ", ")}
+	if len(unknown) > 0 {
+		hints = append(hints, "unexpected fields: "+strings.Join(unknown, ", "))
+	}
+
+	return unsupportedSchemaError(strings.Join(hints, "; "))
+}
+
+// releaseJSONFields returns the top-level fields of the release JSON, decoding one value at a time.
+func releaseJSONFields(releaseJSON []byte) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(releaseJSON))
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('{') {
+		return nil, errors.New("release data is not a JSON object")
+	}
+
+	fields := []string{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		field, _ := token.(string)
+		fields = append(fields, field)
+
+		value := json.RawMessage{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+
+	return fields, nil
+}
+
+func unsupportedSchemaError(hint string) error {
+	return errors.Errorf("unsupported release schema (%s); this build of release2chart supports Helm %s releases, "+
+		"use a release2chart version that matches the Helm version that created the release",
+		hint, chartutil.DefaultCapabilities.HelmVersion.Version)
+}
@@ -0,0 +1,68 @@
+package helm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// encodeReleaseJSON returns release JSON encoded like Helm stores it, base64 encoded gzip.
+func encodeReleaseJSON(t *testing.T, releaseJSON string) []byte {
+	t.Helper()
+
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	if _, err := gzipWriter.Write([]byte(releaseJSON)); err != nil {
+		t.Fatalf("compress release: %v", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatalf("close gzip writer: %v", err)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(compressed.Bytes()))
+}
+
+func TestDecodeReleaseUnsupportedSchema(t *testing.T) {
+	tests := []struct {
+		name        string
+		releaseJSON string
+		hints       []string
+	}{
+		{
+			name:        "renamed fields",
+			releaseJSON: `{"releaseName":"app","revision":3,"info":{"status":"deployed"},"chart":{"metadata":{"name":"app","version":"1.0.0"}}}`,
+			hints:       []string{"missing fields: name, version", "unexpected fields: releaseName, revision"},
+		},
+		{
+			name:        "no chart metadata",
+			releaseJSON: `{"name":"app","version":1,"info":{"status":"deployed"},"chart":{"meta":{"name":"app"}}}`,
+			hints:       []string{"missing fields: chart.metadata"},
+		},
+		{
+			name:        "changed field type",
+			releaseJSON: `{"name":"app","version":"1","info":{"status":"deployed"},"chart":{"metadata":{"name":"app","version":"1.0.0"}}}`,
+			hints:       []string{"version", "string"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := DecodeRelease(encodeReleaseJSON(t, test.releaseJSON))
+			if err == nil {
+				t.Fatal("DecodeRelease: got no error for a release with an unsupported schema")
+			}
+			if !strings.Contains(err.Error(), "unsupported release schema") {
+				t.Errorf("got error %q, want an unsupported release schema error", err)
+			}
+			for _, hint := range test.hints {
+				if !strings.Contains(err.Error(), hint) {
+					t.Errorf("got error %q, want it to contain %q", err, hint)
+				}
+			}
+		})
+	}
+
+	if _, err := DecodeRelease(encodeReleaseJSON(t, `{"name":"app","version":1,"info":{"status":"deployed"},"chart":{"metadata":{"name":"app","version":"1.0.0"}}}`)); err != nil {
+		t.Errorf("DecodeRelease: got error %v for a supported schema", err)
+	}
+}
@@ -0,0 +1,118 @@
+package helm
+
+import (
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// releaseFileObject is the part of a Secret or ConfigMap manifest release data is read from.
+type releaseFileObject struct {
+	Kind       string               `yaml:"kind"`
+	Data       map[string]string    `yaml:"data"`
+	StringData map[string]string    `yaml:"stringData"`
+	Items      []*releaseFileObject `yaml:"items"`
+}
+
+// ReadReleaseFile decodes the release stored in a file without connecting to a cluster.
+// The file holds a release Secret or ConfigMap manifest in YAML or JSON, as printed by kubectl get -o yaml,
+// or only the "release" value of the secret in any encoding DecodeRelease accepts.
+func (c *Client) ReadReleaseFile(fileName string) (*helmrelease.Release, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, errors.Wrap(err, "read file")
+	}
+
+	return releaseFromFileData(data, c.ReleaseKey)
+}
+
+// ReadRelease decodes the release read from r, which holds what ReadReleaseFile accepts in a file,
+// e.g. the output of kubectl piped to stdin.
+func (c *Client) ReadRelease(r io.Reader) (*helmrelease.Release, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "read release data")
+	}
+	if len(data) == 0 {
+		return nil, errors.New("no release data")
+	}
+
+	return releaseFromFileData(data, c.ReleaseKey)
+}
+
+func releaseFromFileData(data []byte, releaseKey string) (*helmrelease.Release, error) {
+	releaseData, err := releaseDataFromManifest(data, releaseKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "read release data")
+	}
+
+	release, err := DecodeRelease(releaseData)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode release")
+	}
+	return release, nil
+}
+
+// releaseDataFromManifest returns the release value of a Secret or ConfigMap manifest, base64 encoded the way Helm stores it.
+// Data that isn't a manifest is returned as is.
+func releaseDataFromManifest(data []byte, releaseKey string) ([]byte, error) {
+	object := &releaseFileObject{}
+	if err := yaml.Unmarshal(data, object); err != nil || object.Kind == "" {
+		return data, nil
+	}
+
+	if object.Kind == "List" {
+		if len(object.Items) != 1 {
+			return nil, errors.Errorf("file holds %d objects, expected a single release secret", len(object.Items))
+		}
+		object = object.Items[0]
+	}
+
+	switch object.Kind {
+	case "Secret":
+		if value, ok := object.StringData[releaseKey]; ok {
+			return []byte(value), nil
+		}
+		// secret data is base64 encoded on top of Helm's own encoding
+		data := map[string][]byte{}
+		for key, value := range object.Data {
+			if key != releaseKey && !strings.HasPrefix(key, releaseKey+"-") {
+				continue
+			}
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "decode secret data %s", key)
+			}
+			data[key] = decoded
+		}
+		return fileReleaseData("secret", data, releaseKey)
+	case "ConfigMap":
+		data := map[string][]byte{}
+		for key, value := range object.Data {
+			data[key] = []byte(value)
+		}
+		return fileReleaseData("configmap", data, releaseKey)
+	default:
+		return nil, errors.Errorf("unsupported kind %s, expected Secret or ConfigMap", object.Kind)
+	}
+}
+
+// fileReleaseData returns the release key of the data of a kind object, or its reassembled chunks.
+func fileReleaseData(kind string, data map[string][]byte, releaseKey string) ([]byte, error) {
+	if value, ok := data[releaseKey]; ok {
+		return value, nil
+	}
+	chunks, err := releaseChunks(data, releaseKey)
+	if err != nil {
+		return nil, err
+	}
+	if chunks == nil {
+		return nil, errors.Errorf("%s has no %q key", kind, releaseKey)
+	}
+	return chunks, nil
+}
@@ -0,0 +1,88 @@
+package helm
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// securityFindings lists references to security APIs that newer clusters no longer serve,
+// with what to use instead.
+func securityFindings(manifest string) ([]string, error) {
+	findings := []string{}
+
+	decoder := yaml.NewDecoder(strings.NewReader(manifest))
+	for {
+		doc := map[string]interface{}{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "decode manifest document")
+		}
+
+		kind, _ := doc["kind"].(string)
+		if kind == "" {
+			continue
+		}
+		apiVersion, _ := doc["apiVersion"].(string)
+		name, _ := nestedMap(doc, "metadata")["name"].(string)
+		resource := fmt.Sprintf("%s %s", kind, name)
+
+		switch {
+		case kind == "PodSecurityPolicy":
+			findings = append(findings, fmt.Sprintf("%s: PodSecurityPolicy was removed in Kubernetes 1.25, use Pod Security Admission namespace labels instead", resource))
+		case kind == "NetworkPolicy" && apiVersion != "networking.k8s.io/v1":
+			findings = append(findings, fmt.Sprintf("%s: %s NetworkPolicy was removed in Kubernetes 1.16, use networking.k8s.io/v1", resource, apiVersion))
+		case kind == "Role" || kind == "ClusterRole":
+			if rulesReferencePSP(doc["rules"]) {
+				findings = append(findings, fmt.Sprintf("%s: grants use of podsecuritypolicies, which have no effect since Kubernetes 1.25 and can be removed", resource))
+			}
+		}
+
+		for _, annotations := range []map[string]interface{}{nestedMap(doc, "metadata", "annotations"), podTemplateAnnotations(doc)} {
+			keys := []string{}
+			for key := range annotations {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				if key == "seccomp.security.alpha.kubernetes.io/pod" || strings.HasPrefix(key, "container.seccomp.security.alpha.kubernetes.io/") {
+					findings = append(findings, fmt.Sprintf("%s: annotation %s is ignored since Kubernetes 1.27, set securityContext.seccompProfile instead", resource, key))
+				}
+			}
+		}
+	}
+
+	return findings, nil
+}
+
+// podTemplateAnnotations returns the pod annotations of a workload resource, or nil if the resource has none.
+func podTemplateAnnotations(doc map[string]interface{}) map[string]interface{} {
+	switch doc["kind"] {
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "ReplicationController", "Job":
+		return nestedMap(doc, "spec", "template", "metadata", "annotations")
+	case "CronJob":
+		return nestedMap(doc, "spec", "jobTemplate", "spec", "template", "metadata", "annotations")
+	}
+	return nil
+}
+
+func rulesReferencePSP(rules interface{}) bool {
+	list, _ := rules.([]interface{})
+	for _, rule := range list {
+		r, _ := rule.(map[string]interface{})
+		resources, _ := r["resources"].([]interface{})
+		for _, resource := range resources {
+			if resource == "podsecuritypolicies" {
+				return true
+			}
+		}
+	}
+	return false
+}
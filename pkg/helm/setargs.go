@@ -0,0 +1,101 @@
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValuesToSetArgs flattens values into Helm --set, --set-string and --set-json arguments.
+// Strings that --set would parse as another type are passed with --set-string, and numbers that --set
+// would parse as a string, such as 1.5, with --set-json.
+// Values that can't be represented safely are skipped and reported as warnings.
+func ValuesToSetArgs(values map[string]interface{}) ([]string, []string) {
+	args := []string{}
+	warnings := []string{}
+	appendSetArgs("", values, &args, &warnings)
+	return args, warnings
+}
+
+func appendSetArgs(path string, value interface{}, args *[]string, warnings *[]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			if path != "" {
+				*warnings = append(*warnings, fmt.Sprintf("%s is an empty map and can't be set with --set", path))
+			}
+			return
+		}
+		keys := []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			childPath := escapeSetKey(key)
+			if path != "" {
+				childPath = path + "." + childPath
+			}
+			appendSetArgs(childPath, v[key], args, warnings)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			*warnings = append(*warnings, fmt.Sprintf("%s is an empty list and can't be set with --set", path))
+			return
+		}
+		for i, item := range v {
+			appendSetArgs(fmt.Sprintf("%s[%d]", path, i), item, args, warnings)
+		}
+	case nil:
+		*args = append(*args, "--set", path+"=null")
+	case string:
+		if strings.Contains(v, "\n") {
+			*warnings = append(*warnings, fmt.Sprintf("%s is a multi-line string and can't be set with --set", path))
+			return
+		}
+		flag := "--set"
+		if setValueNeedsString(v) {
+			flag = "--set-string"
+		}
+		*args = append(*args, flag, path+"="+escapeSetValue(v))
+	case float64:
+		// --set only parses integers, other numbers would become strings
+		if v == math.Trunc(v) && math.Abs(v) < math.MaxInt64 {
+			*args = append(*args, "--set", path+"="+strconv.FormatFloat(v, 'f', -1, 64))
+			return
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			*warnings = append(*warnings, fmt.Sprintf("%s is the number %v and can't be set with --set-json", path, v))
+			return
+		}
+		*args = append(*args, "--set-json", path+"="+string(data))
+	default:
+		*args = append(*args, "--set", path+"="+escapeSetValue(fmt.Sprint(v)))
+	}
+}
+
+// setValueNeedsString reports whether --set would not parse s as a string.
+func setValueNeedsString(s string) bool {
+	for _, keyword := range []string{"", "true", "false", "null"} {
+		// --set ignores the case of keywords
+		if strings.EqualFold(s, keyword) {
+			return true
+		}
+	}
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return true
+	}
+	return false
+}
+
+func escapeSetKey(key string) string {
+	return strings.NewReplacer(`\`, `\\`, `.`, `\.`, `[`, `\[`, `=`, `\=`, `,`, `\,`).Replace(key)
+}
+
+func escapeSetValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `,`, `\,`).Replace(value)
+}
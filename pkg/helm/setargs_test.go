@@ -0,0 +1,96 @@
+package helm
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/strvals"
+)
+
+// parseSetArgs applies --set, --set-string and --set-json arguments like helm install does.
+func parseSetArgs(t *testing.T, args []string) map[string]interface{} {
+	t.Helper()
+
+	values := map[string]interface{}{}
+	for i := 0; i+1 < len(args); i += 2 {
+		var err error
+		switch args[i] {
+		case "--set":
+			err = strvals.ParseInto(args[i+1], values)
+		case "--set-string":
+			err = strvals.ParseIntoString(args[i+1], values)
+		case "--set-json":
+			err = strvals.ParseJSON(args[i+1], values)
+		default:
+			t.Fatalf("unexpected flag %s", args[i])
+		}
+		if err != nil {
+			t.Fatalf("parse %s %s: %v", args[i], args[i+1], err)
+		}
+	}
+	return values
+}
+
+// jsonValues returns values as they are decoded from a release, with every number a float64.
+func jsonValues(t *testing.T, values map[string]interface{}) map[string]interface{} {
+	t.Helper()
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		t.Fatalf("marshal values: %v", err)
+	}
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal values: %v", err)
+	}
+	return decoded
+}
+
+func TestValuesToSetArgsRoundTrip(t *testing.T) {
+	values := jsonValues(t, map[string]interface{}{
+		"replicas":   3,
+		"negative":   -2,
+		"ratio":      1.5,
+		"large":      1e21,
+		"cpu":        0.25,
+		"enabled":    true,
+		"tag":        "1.25",
+		"port":       "8080",
+		"empty":      "",
+		"yes":        "TRUE",
+		"none":       "Null",
+		"nothing":    nil,
+		"list":       []interface{}{1, 2.5, "3", "a,b"},
+		"dotted.key": map[string]interface{}{"weight": 0.1},
+	})
+
+	args, warnings := ValuesToSetArgs(values)
+	if len(warnings) > 0 {
+		t.Errorf("got warnings %v, want none", warnings)
+	}
+
+	if got := jsonValues(t, parseSetArgs(t, args)); !reflect.DeepEqual(got, values) {
+		t.Errorf("args %v set values\n%v\nwant\n%v", args, got, values)
+	}
+}
+
+func TestValuesToSetArgsFlags(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  []string
+	}{
+		{value: float64(3), want: []string{"--set", "a=3"}},
+		{value: 1.5, want: []string{"--set-json", "a=1.5"}},
+		{value: 1e21, want: []string{"--set-json", "a=1e+21"}},
+		{value: "1.5", want: []string{"--set", "a=1.5"}},
+		{value: "3", want: []string{"--set-string", "a=3"}},
+		{value: "False", want: []string{"--set-string", "a=False"}},
+	}
+	for _, test := range tests {
+		args, _ := ValuesToSetArgs(map[string]interface{}{"a": test.value})
+		if !reflect.DeepEqual(args, test.want) {
+			t.Errorf("value %#v: got args %v, want %v", test.value, args, test.want)
+		}
+	}
+}
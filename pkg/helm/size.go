@@ -0,0 +1,53 @@
+package helm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// largestFilesReported is how many of the largest files a size error lists.
+const largestFilesReported = 5
+
+// checkChartSize fails if the chart files add up to more than maxSize bytes, listing the largest files.
+// It runs before packaging, which can be slow for very large charts.
+func checkChartSize(files []chartFile, maxSize int64) error {
+	total := int64(0)
+	for _, file := range files {
+		total += int64(len(file.Data))
+	}
+	debugf("chart files add up to %s", formatSize(total))
+	if total <= maxSize {
+		return nil
+	}
+
+	largest := append([]chartFile{}, files...)
+	sort.SliceStable(largest, func(i, j int) bool {
+		return len(largest[i].Data) > len(largest[j].Data)
+	})
+	if len(largest) > largestFilesReported {
+		largest = largest[:largestFilesReported]
+	}
+	names := []string{}
+	for _, file := range largest {
+		names = append(names, fmt.Sprintf("%s (%s)", file.Name, formatSize(int64(len(file.Data)))))
+	}
+
+	return errors.Errorf("chart files add up to %s, more than the maximum size %s. Largest files: %s", formatSize(total), formatSize(maxSize), strings.Join(names, ", "))
+}
+
+// formatSize formats a byte count with a binary unit, e.g. 1.5MiB.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	value, exponent := float64(size)/unit, 0
+	for value >= unit && exponent < 3 {
+		value /= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f%ciB", value, "KMGT"[exponent])
+}
@@ -0,0 +1,112 @@
+package helm
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	// registers the postgres driver, the only dialect Helm's SQL storage supports
+	_ "github.com/lib/pq"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// sqlReleaseQuery selects the columns of the table Helm's SQL driver keeps releases in.
+const sqlReleaseQuery = `SELECT key, namespace, name, version, status, owner, createdAt, body FROM releases_v1`
+
+// sqlStorage reads releases from the PostgreSQL table of Helm's SQL storage driver.
+// Rows have no labels, they are built from the name, version, status, owner and createdAt columns.
+type sqlStorage struct {
+	connectionString string
+	// releaseKey is the data key rows are returned under, so they are decoded like secrets
+	releaseKey string
+}
+
+func newSQLStorage(connectionString string, releaseKey string) (releaseStorage, error) {
+	if connectionString == "" {
+		return nil, errors.New("sql storage requires --sql-dsn or HELM_DRIVER_SQL_CONNECTION_STRING")
+	}
+	return sqlStorage{connectionString: connectionString, releaseKey: releaseKey}, nil
+}
+
+func (s sqlStorage) List(ctx context.Context, namespace string, selector labels.Selector) ([]storedRelease, error) {
+	db, err := sql.Open("postgres", s.connectionString)
+	if err != nil {
+		return nil, errors.Wrap(err, "open database")
+	}
+	defer db.Close()
+
+	query := sqlReleaseQuery
+	args := []interface{}{}
+	if namespace != "" {
+		query += ` WHERE namespace = $1`
+		args = append(args, namespace)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query releases")
+	}
+	defer rows.Close()
+
+	releases := []storedRelease{}
+	for rows.Next() {
+		release, err := scanSQLRelease(rows, s.releaseKey)
+		if err != nil {
+			return nil, err
+		}
+		if selector.Matches(labels.Set(release.Labels)) {
+			releases = append(releases, *release)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "read releases")
+	}
+	return releases, nil
+}
+
+// ListMetadata is List, rows are always read with their body.
+func (s sqlStorage) ListMetadata(ctx context.Context, namespace string, selector labels.Selector) ([]storedRelease, error) {
+	return s.List(ctx, namespace, selector)
+}
+
+func (s sqlStorage) Get(ctx context.Context, namespace string, name string) (*storedRelease, error) {
+	db, err := sql.Open("postgres", s.connectionString)
+	if err != nil {
+		return nil, errors.Wrap(err, "open database")
+	}
+	defer db.Close()
+
+	row := db.QueryRowContext(ctx, sqlReleaseQuery+` WHERE key = $1 AND namespace = $2`, name, namespace)
+	release, err := scanSQLRelease(row, s.releaseKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "get release row")
+	}
+	return release, nil
+}
+
+// scanSQLRelease reads a row of sqlReleaseQuery. The body column holds the same base64 encoded release as a secret.
+func scanSQLRelease(row interface{ Scan(...interface{}) error }, releaseKey string) (*storedRelease, error) {
+	var key, namespace, name, status, owner, body string
+	var version int
+	var createdAt int64
+	if err := row.Scan(&key, &namespace, &name, &version, &status, &owner, &createdAt, &body); err != nil {
+		return nil, errors.Wrap(err, "scan release row")
+	}
+
+	return &storedRelease{
+		Kind:      "row",
+		Namespace: namespace,
+		Name:      key,
+		Labels: map[string]string{
+			"owner":     owner,
+			"name":      name,
+			"version":   strconv.Itoa(version),
+			"status":    status,
+			"createdAt": strconv.FormatInt(createdAt, 10),
+		},
+		Created: time.Unix(createdAt, 0),
+		Data:    map[string][]byte{releaseKey: []byte(body)},
+	}, nil
+}
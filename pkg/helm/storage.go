@@ -0,0 +1,428 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+)
+
+const (
+	StorageSecret    = "secret"
+	StorageConfigMap = "configmap"
+	StorageSQL       = "sql"
+)
+
+// storageDriverFromEnv returns the storage driver HELM_DRIVER selects. Helm also accepts the plural
+// names, and secrets when it is unset.
+func storageDriverFromEnv() string {
+	switch driver := os.Getenv("HELM_DRIVER"); driver {
+	case "", "secrets":
+		return StorageSecret
+	case "configmaps":
+		return StorageConfigMap
+	default:
+		return driver
+	}
+}
+
+// releaseSelector returns the selector of release objects with the given labels and the owner, labels and
+// selector of c.
+func (c *Client) releaseSelector(set map[string]string) (labels.Selector, error) {
+	selectorLabels := labels.Set{}
+	for _, label := range c.Labels {
+		key, value, ok := strings.Cut(label, "=")
+		if !ok || key == "" {
+			return nil, errors.Errorf("invalid label %q, use key=value", label)
+		}
+		if key == "owner" || key == "name" || key == "version" || key == "status" {
+			return nil, errors.Errorf("label %q is set by Helm, use --owner, --revision or --status to select it", key)
+		}
+		if previous, ok := selectorLabels[key]; ok && previous != value {
+			return nil, errors.Errorf("label %q is given more than once with different values", key)
+		}
+		selectorLabels[key] = value
+	}
+	if c.Owner != "" {
+		selectorLabels["owner"] = c.Owner
+	}
+	for key, value := range set {
+		selectorLabels[key] = value
+	}
+	selector, err := labels.ValidatedSelectorFromSet(selectorLabels)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid label")
+	}
+
+	if c.Selector == "" {
+		return selector, nil
+	}
+	extra, err := labels.Parse(c.Selector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse selector %q", c.Selector)
+	}
+	requirements, _ := extra.Requirements()
+	return selector.Add(requirements...), nil
+}
+
+// storedRelease is a Helm storage object holding one release revision.
+type storedRelease struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Labels    map[string]string
+	Created   time.Time
+	Data      map[string][]byte
+}
+
+// releaseStorage reads the objects a Helm storage driver keeps releases in.
+type releaseStorage interface {
+	List(ctx context.Context, namespace string, selector labels.Selector) ([]storedRelease, error)
+	// ListMetadata is List without the release data, which is much smaller when only labels are needed.
+	ListMetadata(ctx context.Context, namespace string, selector labels.Selector) ([]storedRelease, error)
+	Get(ctx context.Context, namespace string, name string) (*storedRelease, error)
+}
+
+const (
+	// listPageSize limits the objects per list request. A release can be close to the 1MiB object size limit.
+	listPageSize = 50
+	// metadataListPageSize limits the objects per metadata list request.
+	metadataListPageSize = 500
+)
+
+// storageFor returns the storage of driver. It is created once per client, so conversions of many releases
+// share its clients.
+func (c *Client) storageFor(driver string) (releaseStorage, error) {
+	c.storageLock.Lock()
+	defer c.storageLock.Unlock()
+
+	if storage, ok := c.storage[driver]; ok {
+		return storage, nil
+	}
+	storage, err := c.createReleaseStorage(driver)
+	if err != nil {
+		return nil, err
+	}
+	if c.storage == nil {
+		c.storage = map[string]releaseStorage{}
+	}
+	c.storage[driver] = storage
+	return storage, nil
+}
+
+func (c *Client) createReleaseStorage(driver string) (releaseStorage, error) {
+	switch driver {
+	case StorageSecret, StorageConfigMap:
+	case StorageSQL:
+		return newSQLStorage(c.SQLConnectionString, c.ReleaseKey)
+	default:
+		return nil, errors.Errorf("unsupported storage driver %q, use %s, %s or %s", driver, StorageSecret, StorageConfigMap, StorageSQL)
+	}
+
+	clientSet, err := c.GetClientset()
+	if err != nil {
+		return nil, errors.Wrap(err, "create clientset")
+	}
+	var metadataClient metadata.Interface
+	if c.Clientset == nil {
+		cfg, err := c.GetClusterConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "get cluster config")
+		}
+		metadataClient, err = metadata.NewForConfig(cfg)
+		if err != nil {
+			return nil, errors.Wrap(err, "create metadata client")
+		}
+	}
+
+	if driver == StorageConfigMap {
+		return configMapStorage{client: c, clientSet: clientSet, metadataClient: metadataClient}, nil
+	}
+	return secretStorage{client: c, clientSet: clientSet, metadataClient: metadataClient}, nil
+}
+
+// listStoredReleases lists the release objects of the configured storage driver. With the default secret
+// driver, releases stored in configmaps are found as well when there are no matching secrets.
+func (c *Client) listStoredReleases(ctx context.Context, namespace string, selector labels.Selector) ([]storedRelease, error) {
+	return c.listFromStorage(ctx, namespace, selector, func(storage releaseStorage, namespace string) ([]storedRelease, error) {
+		return storage.List(ctx, namespace, selector)
+	})
+}
+
+// listStoredReleaseMetadata is listStoredReleases without the release data. Use loadStoredRelease to decode a release.
+func (c *Client) listStoredReleaseMetadata(ctx context.Context, namespace string, selector labels.Selector) ([]storedRelease, error) {
+	return c.listFromStorage(ctx, namespace, selector, func(storage releaseStorage, namespace string) ([]storedRelease, error) {
+		return storage.ListMetadata(ctx, namespace, selector)
+	})
+}
+
+// listFunc lists the release objects of storage in namespace.
+type listFunc func(storage releaseStorage, namespace string) ([]storedRelease, error)
+
+func (c *Client) listFromStorage(ctx context.Context, namespace string, selector labels.Selector, list listFunc) ([]storedRelease, error) {
+	storage, err := c.storageFor(c.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	debugf("listing %s storage in namespace %q with selector %q", c.Storage, namespace, selector.String())
+	releases, err := c.listInNamespaces(ctx, storage, namespace, list)
+	if err != nil {
+		return nil, err
+	}
+	debugf("%d objects matched", len(releases))
+	warnRevisionMismatch(releases)
+	if len(releases) > 0 || c.Storage != StorageSecret {
+		return releases, nil
+	}
+
+	configMaps, err := c.storageFor(StorageConfigMap)
+	if err != nil {
+		return nil, nil
+	}
+	releases, err = c.listInNamespaces(ctx, configMaps, namespace, list)
+	if err != nil {
+		// the secret driver found nothing, a configmap error shouldn't hide that
+		debugf("listing configmaps failed: %v", err)
+		return nil, nil
+	}
+	debugf("%d configmaps matched", len(releases))
+	warnRevisionMismatch(releases)
+	if len(releases) > 0 {
+		fmt.Fprintln(os.Stderr, "No release secrets found, reading releases from configmaps (use --storage configmap to skip this check)")
+	}
+	return releases, nil
+}
+
+var (
+	// releaseObjectName matches the names Helm gives release objects, sh.helm.release.v1.<name>.v<revision>.
+	releaseObjectName = regexp.MustCompile(`^sh\.helm\.release\.v1\..+\.v(\d+)$`)
+	// revisionMismatches records the objects warned about, so listing them again doesn't repeat the warning.
+	revisionMismatches sync.Map
+	// nameMismatches records the objects warnReleaseNameMismatch warned about.
+	nameMismatches sync.Map
+)
+
+// warnReleaseNameMismatch warns if the release decoded from a stored object has another name than the release
+// it was looked up as, its name label. The install command uses the decoded name, so both are printed.
+func warnReleaseNameMismatch(stored *storedRelease, requestedName string, release *helmrelease.Release) {
+	if release.Name == requestedName {
+		return
+	}
+	if _, warned := nameMismatches.LoadOrStore(stored.Kind+"/"+stored.Namespace+"/"+stored.Name, true); warned {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: release %s was requested, but %s %s/%s holds release %s. The install command uses %s, check that it is the name to install under or use --rename\n", requestedName, stored.Kind, stored.Namespace, stored.Name, release.Name, release.Name)
+}
+
+// warnRevisionMismatch warns if the version label of a release object disagrees with the revision in its name,
+// which points to an object copied or renamed by hand. Objects not named the way Helm names them aren't checked.
+func warnRevisionMismatch(releases []storedRelease) {
+	for _, release := range releases {
+		match := releaseObjectName.FindStringSubmatch(release.Name)
+		if match == nil || match[1] == release.Labels["version"] {
+			continue
+		}
+		if _, warned := revisionMismatches.LoadOrStore(release.Kind+"/"+release.Namespace+"/"+release.Name, true); warned {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s %s/%s has version label %q, but its name has revision %s\n", release.Kind, release.Namespace, release.Name, release.Labels["version"], match[1])
+	}
+}
+
+// loadStoredRelease decodes the release of a stored object, fetching its data first if it was listed without it.
+func (c *Client) loadStoredRelease(ctx context.Context, stored *storedRelease) (*helmrelease.Release, error) {
+	if stored.Data == nil {
+		storage, err := c.storageFor(stored.Kind)
+		if err != nil {
+			return nil, err
+		}
+		fetched, err := storage.Get(ctx, stored.Namespace, stored.Name)
+		if err != nil {
+			return nil, err
+		}
+		stored.Data = fetched.Data
+	}
+
+	return releaseFromStorage(stored, c.ReleaseKey)
+}
+
+// listPages calls list with a page size and the continue token of the previous page until all pages are read.
+// Every page is retried on transient errors.
+func (c *Client) listPages(ctx context.Context, description string, selector labels.Selector, pageSize int64, list func(opts metav1.ListOptions) (string, error)) error {
+	opts := metav1.ListOptions{LabelSelector: selector.String(), Limit: pageSize}
+	for {
+		var continueToken string
+		err := c.retryTransient(ctx, description, func() (err error) {
+			continueToken, err = list(opts)
+			return err
+		})
+		if err != nil {
+			return errors.Wrap(err, description)
+		}
+		if continueToken == "" {
+			return nil
+		}
+		opts.Continue = continueToken
+	}
+}
+
+// listMetadata lists the metadata of a core v1 resource, e.g. secrets, as stored releases of the given kind.
+func (c *Client) listMetadata(ctx context.Context, client metadata.Interface, resource string, kind string, namespace string, selector labels.Selector) ([]storedRelease, error) {
+	releases := []storedRelease{}
+	err := c.listPages(ctx, "list "+resource+" metadata", selector, metadataListPageSize, func(opts metav1.ListOptions) (string, error) {
+		objects, err := client.Resource(corev1.SchemeGroupVersion.WithResource(resource)).Namespace(namespace).List(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+		for _, object := range objects.Items {
+			releases = append(releases, storedRelease{
+				Kind:      kind,
+				Namespace: object.Namespace,
+				Name:      object.Name,
+				Labels:    object.Labels,
+				Created:   object.CreationTimestamp.Time,
+			})
+		}
+		return objects.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+type secretStorage struct {
+	// client retries failed calls with its settings
+	client         *Client
+	clientSet      kubernetes.Interface
+	metadataClient metadata.Interface
+}
+
+func (s secretStorage) List(ctx context.Context, namespace string, selector labels.Selector) ([]storedRelease, error) {
+	releases := []storedRelease{}
+	err := s.client.listPages(ctx, "list secrets", selector, listPageSize, func(opts metav1.ListOptions) (string, error) {
+		secrets, err := s.clientSet.CoreV1().Secrets(namespace).List(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+		for i := range secrets.Items {
+			releases = append(releases, secretRelease(&secrets.Items[i]))
+		}
+		return secrets.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+func (s secretStorage) ListMetadata(ctx context.Context, namespace string, selector labels.Selector) ([]storedRelease, error) {
+	if s.metadataClient == nil {
+		// storage of an injected clientset
+		return s.List(ctx, namespace, selector)
+	}
+	return s.client.listMetadata(ctx, s.metadataClient, "secrets", StorageSecret, namespace, selector)
+}
+
+func (s secretStorage) Get(ctx context.Context, namespace string, name string) (*storedRelease, error) {
+	var secret *corev1.Secret
+	err := s.client.retryTransient(ctx, "get secret", func() (err error) {
+		secret, err = s.clientSet.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "get secret")
+	}
+
+	release := secretRelease(secret)
+	return &release, nil
+}
+
+func secretRelease(secret *corev1.Secret) storedRelease {
+	return storedRelease{
+		Kind:      StorageSecret,
+		Namespace: secret.Namespace,
+		Name:      secret.Name,
+		Labels:    secret.Labels,
+		Created:   secret.CreationTimestamp.Time,
+		Data:      secret.Data,
+	}
+}
+
+type configMapStorage struct {
+	client         *Client
+	clientSet      kubernetes.Interface
+	metadataClient metadata.Interface
+}
+
+func (s configMapStorage) List(ctx context.Context, namespace string, selector labels.Selector) ([]storedRelease, error) {
+	releases := []storedRelease{}
+	err := s.client.listPages(ctx, "list configmaps", selector, listPageSize, func(opts metav1.ListOptions) (string, error) {
+		configMaps, err := s.clientSet.CoreV1().ConfigMaps(namespace).List(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+		for i := range configMaps.Items {
+			releases = append(releases, configMapRelease(&configMaps.Items[i]))
+		}
+		return configMaps.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+func (s configMapStorage) ListMetadata(ctx context.Context, namespace string, selector labels.Selector) ([]storedRelease, error) {
+	if s.metadataClient == nil {
+		// storage of an injected clientset
+		return s.List(ctx, namespace, selector)
+	}
+	return s.client.listMetadata(ctx, s.metadataClient, "configmaps", StorageConfigMap, namespace, selector)
+}
+
+func (s configMapStorage) Get(ctx context.Context, namespace string, name string) (*storedRelease, error) {
+	var configMap *corev1.ConfigMap
+	err := s.client.retryTransient(ctx, "get configmap", func() (err error) {
+		configMap, err = s.clientSet.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "get configmap")
+	}
+
+	release := configMapRelease(configMap)
+	return &release, nil
+}
+
+func configMapRelease(configMap *corev1.ConfigMap) storedRelease {
+	return storedRelease{
+		Kind:      StorageConfigMap,
+		Namespace: configMap.Namespace,
+		Name:      configMap.Name,
+		Labels:    configMap.Labels,
+		Created:   configMap.CreationTimestamp.Time,
+		Data:      configMapData(configMap.Data),
+	}
+}
+
+// configMapData converts configmap data to the secret data type. Both hold the same base64 encoded release.
+func configMapData(data map[string]string) map[string][]byte {
+	converted := map[string][]byte{}
+	for key, value := range data {
+		converted[key] = []byte(value)
+	}
+	return converted
+}
@@ -0,0 +1,94 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	helmrelease "helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// releaseConfigMaps returns the configmaps Helm's configmap driver stores the releases in.
+func releaseConfigMaps(t *testing.T, releases ...*helmrelease.Release) []runtime.Object {
+	t.Helper()
+
+	objects := []runtime.Object{}
+	for _, secret := range releaseSecrets(t, releases...) {
+		secret := secret.(*corev1.Secret)
+		data := map[string]string{}
+		for key, value := range secret.Data {
+			data[key] = string(value)
+		}
+		objects = append(objects, &corev1.ConfigMap{ObjectMeta: secret.ObjectMeta, Data: data})
+	}
+	return objects
+}
+
+func TestClientsetConfigMaps(t *testing.T) {
+	objects := releaseConfigMaps(t, testRelease(1, helmrelease.StatusSuperseded), testRelease(2, helmrelease.StatusDeployed))
+
+	for _, driver := range []string{StorageConfigMap, StorageSecret} {
+		t.Run(driver, func(t *testing.T) {
+			client := fakeReleaseClient(objects...)
+			// the secret driver falls back to configmaps when no secret holds the release
+			client.Storage = driver
+
+			revision, err := client.FindLatestReleaseVersion(context.Background(), "ns", "app", helmrelease.StatusDeployed, false)
+			if err != nil {
+				t.Fatalf("FindLatestReleaseVersion: %v", err)
+			}
+			if revision != 2 {
+				t.Errorf("got revision %d, want 2", revision)
+			}
+
+			release, err := client.GetRelease(context.Background(), "ns", "app", 1)
+			if err != nil {
+				t.Fatalf("GetRelease: %v", err)
+			}
+			if release.Config["revision"] != float64(1) {
+				t.Errorf("got release config %v, want the config of revision 1", release.Config)
+			}
+		})
+	}
+}
+
+func TestClientsetPerClient(t *testing.T) {
+	first := fakeReleaseClient(releaseSecrets(t, testRelease(1, helmrelease.StatusDeployed))...)
+	second := fakeReleaseClient(releaseSecrets(t, testRelease(1, helmrelease.StatusDeployed), testRelease(2, helmrelease.StatusDeployed))...)
+
+	errs := make(chan error, 2)
+	for client, want := range map[*Client]int{first: 1, second: 2} {
+		go func(client *Client, want int) {
+			revision, err := client.FindLatestReleaseVersion(context.Background(), "ns", "app", "", false)
+			if err == nil && revision != want {
+				err = fmt.Errorf("got revision %d, want %d", revision, want)
+			}
+			errs <- err
+		}(client, want)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestConvertImmutableSecret(t *testing.T) {
+	objects := releaseSecrets(t, testRelease(1, helmrelease.StatusDeployed))
+	immutable := true
+	objects[0].(*corev1.Secret).Immutable = &immutable
+	client := fakeReleaseClient(objects...)
+
+	if _, err := client.ConvertReleaseVersion(context.Background(), "ns", "app", 1, ConvertOptions{DestDir: t.TempDir()}); err != nil {
+		t.Fatalf("ConvertReleaseVersion: %v", err)
+	}
+
+	for _, action := range client.Clientset.(*fake.Clientset).Actions() {
+		if action.GetVerb() != "get" && action.GetVerb() != "list" {
+			t.Errorf("got %s %s, want release secrets to be only read", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package helm
+
+import (
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// extractSubchart unpacks the named subchart of the chart in chartDir next to it and returns its dir
+// along with a copy of the release that has the subchart as its chart.
+// The values are the ones the umbrella chart and the release passed to the subchart, including globals.
+// The manifest and hooks belong to the umbrella chart and are dropped.
+func extractSubchart(release *helmrelease.Release, chartDir string, name string) (string, *helmrelease.Release, error) {
+	umbrella, err := loader.LoadDir(chartDir)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "load chart")
+	}
+
+	var subchart *chart.Chart
+	available := []string{}
+	for _, dependency := range umbrella.Dependencies() {
+		available = append(available, dependency.Name())
+		if dependency.Name() == name {
+			subchart = dependency
+		}
+	}
+	if subchart == nil {
+		return "", nil, errors.Errorf("subchart %s not found, available subcharts: %v", name, available)
+	}
+
+	subchartDir := filepath.Join(chartDir, "subchart")
+	if err := chartutil.SaveDir(subchart, subchartDir); err != nil {
+		return "", nil, errors.Wrap(err, "save subchart")
+	}
+
+	// release values take precedence over the umbrella chart defaults
+	key := subchartValuesKey(umbrella, name)
+	config := map[string]interface{}{}
+	for _, values := range []map[string]interface{}{release.Config, release.Chart.Values} {
+		if subchartValues, ok := values[key].(map[string]interface{}); ok {
+			config = chartutil.CoalesceTables(config, copyValues(subchartValues))
+		}
+		if global, ok := values["global"].(map[string]interface{}); ok {
+			configGlobal, _ := config["global"].(map[string]interface{})
+			if configGlobal == nil {
+				configGlobal = map[string]interface{}{}
+			}
+			config["global"] = chartutil.CoalesceTables(configGlobal, copyValues(global))
+		}
+	}
+
+	return filepath.Join(subchartDir, subchart.Name()), &helmrelease.Release{
+		Name:      release.Name,
+		Namespace: release.Namespace,
+		Version:   release.Version,
+		Info:      release.Info,
+		Chart:     subchart,
+		Config:    config,
+	}, nil
+}
+
+// subchartValuesKey returns the key the parent chart passes the subchart values under, which is its alias if it has one.
+func subchartValuesKey(parent *chart.Chart, name string) string {
+	for _, dependency := range parent.Metadata.Dependencies {
+		if dependency.Name == name && dependency.Alias != "" {
+			return dependency.Alias
+		}
+	}
+	return name
+}
+
+// copyValues deep copies values so coalescing doesn't modify the release.
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	copied := map[string]interface{}{}
+	for key, value := range values {
+		copied[key] = copyValue(value)
+	}
+	return copied
+}
+
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return copyValues(v)
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	default:
+		return v
+	}
+}
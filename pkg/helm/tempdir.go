@@ -0,0 +1,25 @@
+package helm
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	// KeepTempNever removes temp dirs when the conversion ends.
+	KeepTempNever = "never"
+	// KeepTempOnFailure keeps temp dirs of failed conversions for inspection.
+	KeepTempOnFailure = "on-failure"
+	// KeepTempAlways keeps all temp dirs.
+	KeepTempAlways = "always"
+)
+
+// removeTempDir removes dir unless keep says to keep it after a conversion that failed or not,
+// in which case its path is printed to stderr.
+func removeTempDir(dir string, keep string, failed bool) {
+	if keep == KeepTempAlways || (keep == KeepTempOnFailure && failed) {
+		fmt.Fprintf(os.Stderr, "Kept temp dir %s\n", dir)
+		return
+	}
+	os.RemoveAll(dir)
+}
@@ -0,0 +1,126 @@
+package helm
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+const (
+	FileStatusMatch         = "match"
+	FileStatusDiffers       = "differs"
+	FileStatusNotInUpstream = "not in upstream"
+	FileStatusNotInRelease  = "not in release"
+	upstreamValuesFileName  = "values.yaml"
+)
+
+type FileDiff struct {
+	Name   string
+	Status string
+}
+
+// DiffWithUpstream compares the files of the deployed chart with the same chart version
+// downloaded from repoURL.
+func (c *Client) DiffWithUpstream(ctx context.Context, namespace string, releaseName string, revision int, repoURL string) ([]FileDiff, error) {
+	helmRelease, err := c.GetRelease(ctx, namespace, releaseName, revision)
+	if err != nil {
+		return nil, errors.Wrap(err, "get release")
+	}
+
+	if helmRelease.Chart == nil || helmRelease.Chart.Metadata == nil {
+		return nil, errors.New("release has no chart metadata")
+	}
+
+	upstream, err := downloadChart(repoURL, helmRelease.Chart.Metadata.Name, helmRelease.Chart.Metadata.Version)
+	if err != nil {
+		return nil, errors.Wrap(err, "download upstream chart")
+	}
+
+	return diffChartFiles(helmRelease.Chart, upstream), nil
+}
+
+func downloadChart(repoURL string, chartName string, chartVersion string) (*chart.Chart, error) {
+	getters := getter.All(cli.New())
+
+	chartURL, err := repo.FindChartInRepoURL(repoURL, chartName, chartVersion, "", "", "", getters)
+	if err != nil {
+		return nil, errors.Wrap(err, "find chart in repo")
+	}
+
+	u, err := url.Parse(chartURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse chart url")
+	}
+
+	g, err := getters.ByScheme(u.Scheme)
+	if err != nil {
+		return nil, errors.Wrap(err, "get getter")
+	}
+
+	data, err := g.Get(chartURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "download %s", chartURL)
+	}
+
+	c, err := loader.LoadArchive(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "load chart archive")
+	}
+
+	return c, nil
+}
+
+func diffChartFiles(deployed *chart.Chart, upstream *chart.Chart) []FileDiff {
+	deployedFiles := chartFileData(deployed)
+	upstreamFiles := chartFileData(upstream)
+
+	diffs := []FileDiff{}
+	for name, data := range deployedFiles {
+		upstreamData, ok := upstreamFiles[name]
+		switch {
+		case !ok:
+			diffs = append(diffs, FileDiff{Name: name, Status: FileStatusNotInUpstream})
+		case bytes.Equal(data, upstreamData):
+			diffs = append(diffs, FileDiff{Name: name, Status: FileStatusMatch})
+		default:
+			diffs = append(diffs, FileDiff{Name: name, Status: FileStatusDiffers})
+		}
+	}
+	for name := range upstreamFiles {
+		if _, ok := deployedFiles[name]; !ok {
+			diffs = append(diffs, FileDiff{Name: name, Status: FileStatusNotInRelease})
+		}
+	}
+
+	valuesStatus := FileStatusDiffers
+	if reflect.DeepEqual(deployed.Values, upstream.Values) {
+		valuesStatus = FileStatusMatch
+	}
+	diffs = append(diffs, FileDiff{Name: upstreamValuesFileName, Status: valuesStatus})
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Name < diffs[j].Name
+	})
+
+	return diffs
+}
+
+func chartFileData(c *chart.Chart) map[string][]byte {
+	files := map[string][]byte{}
+	for _, file := range c.Files {
+		files[file.Name] = file.Data
+	}
+	for _, template := range c.Templates {
+		files[template.Name] = template.Data
+	}
+	return files
+}
@@ -0,0 +1,102 @@
+package helm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+// validateRelease returns warnings about inconsistencies in the decoded release data.
+func validateRelease(release *helmrelease.Release) []string {
+	warnings := []string{}
+
+	if warning := validateChartLabels(release); warning != "" {
+		warnings = append(warnings, warning)
+	}
+
+	return warnings
+}
+
+// validateChartLabels compares the helm.sh/chart labels recorded in the rendered manifest
+// with the decoded chart and its subcharts. A label naming a different chart usually means
+// the release data is corrupted. Labels truncated to 63 characters, as the chart helpers of helm create
+// do, match too.
+func validateChartLabels(release *helmrelease.Release) string {
+	if release.Chart == nil || release.Chart.Metadata == nil {
+		return ""
+	}
+
+	resources, err := parseManifest(release.Manifest)
+	if err != nil {
+		return ""
+	}
+
+	known := map[string]bool{}
+	addChartLabels(release.Chart, known)
+
+	unknown := map[string]bool{}
+	for _, resource := range resources {
+		label := resource.Metadata.Labels["helm.sh/chart"]
+		if label != "" && !known[label] {
+			unknown[label] = true
+		}
+	}
+
+	if len(unknown) == 0 {
+		return ""
+	}
+
+	labels := []string{}
+	for label := range unknown {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	return fmt.Sprintf("release chart %s does not match chart recorded in manifest (%s); release data may be corrupted",
+		chartLabel(release.Chart.Metadata), strings.Join(labels, ", "))
+}
+
+func addChartLabels(c *chart.Chart, labels map[string]bool) {
+	if c.Metadata != nil {
+		label := chartLabel(c.Metadata)
+		labels[label] = true
+		labels[truncateLabel(label)] = true
+	}
+	for _, dependency := range c.Dependencies() {
+		addChartLabels(dependency, labels)
+	}
+}
+
+// chartLabel returns the value of the conventional helm.sh/chart label for the chart.
+func chartLabel(metadata *chart.Metadata) string {
+	return metadata.Name + "-" + strings.ReplaceAll(metadata.Version, "+", "_")
+}
+
+// truncateLabel shortens a label value to the 63 characters Kubernetes allows, like the chart helper
+// of helm create does with trunc 63 | trimSuffix "-".
+func truncateLabel(label string) string {
+	if len(label) <= 63 {
+		return label
+	}
+	return strings.TrimSuffix(label[:63], "-")
+}
+
+// missingDependencies returns the dependencies declared in the chart metadata that are not bundled in the chart.
+func missingDependencies(c *chart.Chart) []string {
+	bundled := map[string]bool{}
+	for _, dependency := range c.Dependencies() {
+		bundled[dependency.Name()] = true
+	}
+
+	missing := []string{}
+	for _, dependency := range c.Metadata.Dependencies {
+		if !bundled[dependency.Name] {
+			missing = append(missing, dependency.Name)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,43 @@
+package helm
+
+import (
+	"strings"
+	"testing"
+
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+func TestValidateChartLabels(t *testing.T) {
+	longName := "chart-name-that-is-long-enough-to-be-cut-from-the-chart-label"
+	tests := []struct {
+		name      string
+		chartName string
+		version   string
+		label     string
+		warning   bool
+	}{
+		{name: "matching", chartName: "app", version: "1.2.3", label: "app-1.2.3"},
+		{name: "build metadata", chartName: "app", version: "1.2.3+build", label: "app-1.2.3_build"},
+		{name: "other chart", chartName: "app", version: "1.2.3", label: "nginx-1.2.3", warning: true},
+		{name: "other version", chartName: "app", version: "1.2.3", label: "app-1.2.4", warning: true},
+		{name: "truncated", chartName: longName, version: "10.20.30", label: longName + "-1"},
+		{name: "truncated before a dash", chartName: strings.Repeat("a", 62), version: "1.0.0", label: strings.Repeat("a", 62)},
+		{name: "truncated other version", chartName: longName, version: "10.20.30", label: longName + "-9.9.9", warning: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			release := testRelease(1, helmrelease.StatusDeployed)
+			release.Chart.Metadata.Name = test.chartName
+			release.Chart.Metadata.Version = test.version
+			release.Manifest = "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n  labels:\n    helm.sh/chart: " + test.label + "\n"
+
+			warning := validateChartLabels(release)
+			if test.warning && !strings.Contains(warning, test.label) {
+				t.Errorf("got warning %q, want one naming %s", warning, test.label)
+			}
+			if !test.warning && warning != "" {
+				t.Errorf("got warning %q, want none", warning)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chartutil"
+	helmrelease "helm.sh/helm/v3/pkg/release"
+)
+
+const (
+	// ValuesModeUser writes the values supplied at install or upgrade, the release config.
+	ValuesModeUser = "user"
+	// ValuesModeComputed writes the chart defaults merged with the user supplied values.
+	ValuesModeComputed = "computed"
+	// ValuesModeNone writes no values file.
+	ValuesModeNone = "none"
+)
+
+const (
+	ValueAdded   = "added"
+	ValueChanged = "changed"
+	ValueRemoved = "removed"
+)
+
+type ValueDiff struct {
+	Path   string      `json:"path" yaml:"path"`
+	Change string      `json:"change" yaml:"change"`
+	Old    interface{} `json:"old,omitempty" yaml:"old,omitempty"`
+	New    interface{} `json:"new,omitempty" yaml:"new,omitempty"`
+}
+
+// releaseValues returns the values of the release to write in the values file for mode.
+func releaseValues(release *helmrelease.Release, mode string) (map[string]interface{}, error) {
+	switch mode {
+	case "", ValuesModeUser:
+		return release.Config, nil
+	case ValuesModeComputed:
+		values, err := chartutil.CoalesceValues(release.Chart, copyValues(release.Config))
+		if err != nil {
+			return nil, errors.Wrap(err, "coalesce values")
+		}
+		return values, nil
+	case ValuesModeNone:
+		return nil, nil
+	}
+	return nil, errors.Errorf("unknown values mode %q, use %s, %s or %s", mode, ValuesModeUser, ValuesModeComputed, ValuesModeNone)
+}
+
+// DiffValues compares two values trees leaf by leaf and returns the changes needed to go from oldValues to newValues.
+func DiffValues(oldValues map[string]interface{}, newValues map[string]interface{}) []ValueDiff {
+	oldLeaves := flattenValues(oldValues)
+	newLeaves := flattenValues(newValues)
+
+	diffs := []ValueDiff{}
+	for path, oldValue := range oldLeaves {
+		newValue, ok := newLeaves[path]
+		switch {
+		case !ok:
+			diffs = append(diffs, ValueDiff{Path: path, Change: ValueRemoved, Old: oldValue})
+		case !reflect.DeepEqual(oldValue, newValue):
+			diffs = append(diffs, ValueDiff{Path: path, Change: ValueChanged, Old: oldValue, New: newValue})
+		}
+	}
+	for path, newValue := range newLeaves {
+		if _, ok := oldLeaves[path]; !ok {
+			diffs = append(diffs, ValueDiff{Path: path, Change: ValueAdded, New: newValue})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Path < diffs[j].Path
+	})
+
+	return diffs
+}
+
+// flattenValues maps dotted paths (with [i] for list items) to leaf values.
+// Empty maps and lists are kept as leaves.
+func flattenValues(values map[string]interface{}) map[string]interface{} {
+	leaves := map[string]interface{}{}
+	flattenValue("", values, leaves)
+	return leaves
+}
+
+func flattenValue(path string, value interface{}, leaves map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && path != "" {
+			leaves[path] = v
+			return
+		}
+		for key, child := range v {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			flattenValue(childPath, child, leaves)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			leaves[path] = v
+			return
+		}
+		for i, child := range v {
+			flattenValue(fmt.Sprintf("%s[%d]", path, i), child, leaves)
+		}
+	default:
+		leaves[path] = v
+	}
+}
+
+// marshalNormalizedValues renders values with keys sorted at every level, including maps nested in lists,
+// and a consistent two space indentation, so the output is stable across conversions.
+func marshalNormalizedValues(values map[string]interface{}) ([]byte, error) {
+	node := &yaml.Node{}
+	if err := node.Encode(values); err != nil {
+		return nil, errors.Wrap(err, "encode values")
+	}
+	sortYAMLNode(node)
+	return encodeValuesNode(node, 2)
+}
+
+// marshalAnnotatedValues renders values like marshalValues, with the keys set in overrides annotated.
+func marshalAnnotatedValues(values map[string]interface{}, normalize bool, overrides map[string]interface{}) ([]byte, error) {
+	node := &yaml.Node{}
+	if err := node.Encode(values); err != nil {
+		return nil, errors.Wrap(err, "encode values")
+	}
+	// yaml.Marshal indents by four spaces
+	indent := 4
+	if normalize {
+		sortYAMLNode(node)
+		indent = 2
+	}
+	annotateOverrides(node, overrides)
+	return encodeValuesNode(node, indent)
+}
+
+// annotateOverrides adds a "user-override" line comment to the keys of the mapping node that overrides sets.
+// Maps are descended into, so only the keys set in them are annotated and an empty map overrides nothing.
+func annotateOverrides(node *yaml.Node, overrides map[string]interface{}) {
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		override, ok := overrides[key.Value]
+		if !ok {
+			continue
+		}
+		if nested, isMap := override.(map[string]interface{}); isMap && value.Kind == yaml.MappingNode {
+			annotateOverrides(value, nested)
+			continue
+		}
+		key.LineComment = "user-override"
+	}
+}
+
+func encodeValuesNode(node *yaml.Node, indent int) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(indent)
+	if err := encoder.Encode(node); err != nil {
+		return nil, errors.Wrap(err, "marshal values")
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, errors.Wrap(err, "close encoder")
+	}
+
+	return b.Bytes(), nil
+}
+
+func sortYAMLNode(node *yaml.Node) {
+	for _, child := range node.Content {
+		sortYAMLNode(child)
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+
+	type pair struct {
+		key   *yaml.Node
+		value *yaml.Node
+	}
+	pairs := []pair{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		pairs = append(pairs, pair{key: node.Content[i], value: node.Content[i+1]})
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].key.Value < pairs[j].key.Value
+	})
+
+	node.Content = node.Content[:0]
+	for _, p := range pairs {
+		node.Content = append(node.Content, p.key, p.value)
+	}
+}
+
+// unsetValue removes the value at a dotted path such as a.b[0].c from values.
+// Removing a list item shifts the items after it. It returns false if the path doesn't exist.
+func unsetValue(values map[string]interface{}, path string) (bool, error) {
+	segments, err := parseValuePath(path)
+	if err != nil {
+		return false, errors.Wrapf(err, "parse path %q", path)
+	}
+	_, found := unsetSegments(values, segments)
+	return found, nil
+}
+
+// unsetSegments returns the container with the value removed, since removing a list item creates a new slice.
+func unsetSegments(container interface{}, segments []interface{}) (interface{}, bool) {
+	last := len(segments) == 1
+	switch segment := segments[0].(type) {
+	case string:
+		m, ok := container.(map[string]interface{})
+		if !ok {
+			return container, false
+		}
+		child, ok := m[segment]
+		if !ok {
+			return container, false
+		}
+		if last {
+			delete(m, segment)
+			return m, true
+		}
+		updated, found := unsetSegments(child, segments[1:])
+		m[segment] = updated
+		return m, found
+	case int:
+		list, ok := container.([]interface{})
+		if !ok || segment >= len(list) {
+			return container, false
+		}
+		if last {
+			return append(list[:segment:segment], list[segment+1:]...), true
+		}
+		updated, found := unsetSegments(list[segment], segments[1:])
+		list[segment] = updated
+		return list, found
+	}
+	return container, false
+}
+
+// parseValuePath splits a dotted path into map keys (strings) and list indexes (ints).
+func parseValuePath(path string) ([]interface{}, error) {
+	segments := []interface{}{}
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		indexes := ""
+		if i := strings.Index(part, "["); i != -1 {
+			key, indexes = part[:i], part[i:]
+		}
+		if key == "" {
+			return nil, errors.New("empty key")
+		}
+		segments = append(segments, key)
+
+		for indexes != "" {
+			end := strings.Index(indexes, "]")
+			if !strings.HasPrefix(indexes, "[") || end == -1 {
+				return nil, errors.Errorf("malformed index in %q", part)
+			}
+			index, err := strconv.Atoi(indexes[1:end])
+			if err != nil || index < 0 {
+				return nil, errors.Errorf("invalid index in %q", part)
+			}
+			segments = append(segments, index)
+			indexes = indexes[end+1:]
+		}
+	}
+	return segments, nil
+}
+
+// setValue sets the value at a dotted path such as a.b[0].c, creating missing maps.
+// List items must already exist.
+func setValue(values map[string]interface{}, path string, value interface{}) error {
+	segments, err := parseValuePath(path)
+	if err != nil {
+		return errors.Wrapf(err, "parse path %q", path)
+	}
+
+	var container interface{} = values
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch s := segment.(type) {
+		case string:
+			m, ok := container.(map[string]interface{})
+			if !ok {
+				return errors.Errorf("%s is not a map", path)
+			}
+			if last {
+				m[s] = value
+				return nil
+			}
+			if _, ok := m[s]; !ok {
+				m[s] = map[string]interface{}{}
+			}
+			container = m[s]
+		case int:
+			list, ok := container.([]interface{})
+			if !ok || s >= len(list) {
+				return errors.Errorf("%s is not an existing list item", path)
+			}
+			if last {
+				list[s] = value
+				return nil
+			}
+			container = list[s]
+		}
+	}
+	return nil
+}
+
+// nameOverrideKeys are the values charts commonly use to override the names of their resources.
+var nameOverrideKeys = map[string]bool{
+	"fullnameOverride": true,
+	"nameOverride":     true,
+}
+
+// renameReleaseValues replaces oldName with newName in the name override values of values and of the maps
+// nested in it, such as subchart values, and returns the dotted paths of the values it changed. Only values
+// that are oldName or start with "<oldName>-" are renamed, so that a short release name such as "a" isn't
+// replaced inside other names.
+func renameReleaseValues(values map[string]interface{}, oldName string, newName string, path string) []string {
+	renamed := []string{}
+	for key, value := range values {
+		childPath := key
+		if path != "" {
+			childPath = path + "." + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			renamed = append(renamed, renameReleaseValues(v, oldName, newName, childPath)...)
+		case string:
+			if !nameOverrideKeys[key] {
+				continue
+			}
+			if v == oldName {
+				values[key] = newName
+				renamed = append(renamed, childPath)
+			} else if strings.HasPrefix(v, oldName+"-") {
+				values[key] = newName + strings.TrimPrefix(v, oldName)
+				renamed = append(renamed, childPath)
+			}
+		}
+	}
+	sort.Strings(renamed)
+	return renamed
+}
@@ -0,0 +1,109 @@
+package helm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenameReleaseValues(t *testing.T) {
+	values := map[string]interface{}{
+		"fullnameOverride": "a",
+		"nameOverride":     "a-web",
+		"image":            "a",
+		"subchart": map[string]interface{}{
+			"fullnameOverride": "cache-a",
+			"nameOverride":     "database",
+		},
+		"other": map[string]interface{}{
+			"nameOverride": "a-db",
+		},
+	}
+
+	renamed := renameReleaseValues(values, "a", "b", "")
+
+	if want := []string{"fullnameOverride", "nameOverride", "other.nameOverride"}; !reflect.DeepEqual(renamed, want) {
+		t.Errorf("got renamed paths %v, want %v", renamed, want)
+	}
+	want := map[string]interface{}{
+		"fullnameOverride": "b",
+		"nameOverride":     "b-web",
+		"image":            "a",
+		"subchart": map[string]interface{}{
+			"fullnameOverride": "cache-a",
+			"nameOverride":     "database",
+		},
+		"other": map[string]interface{}{
+			"nameOverride": "b-db",
+		},
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("got values %v, want %v", values, want)
+	}
+}
+
+func TestUnsetValue(t *testing.T) {
+	newValues := func() map[string]interface{} {
+		return map[string]interface{}{
+			"image": map[string]interface{}{"repository": "nginx", "tag": "1.25"},
+			"ingress": map[string]interface{}{
+				"hosts": []interface{}{
+					map[string]interface{}{"host": "a.example.com", "tls": true},
+					map[string]interface{}{"host": "b.example.com"},
+				},
+			},
+			"env": []interface{}{"A", "B", "C"},
+		}
+	}
+
+	tests := []struct {
+		path  string
+		found bool
+		want  func(values map[string]interface{})
+	}{
+		{path: "image.tag", found: true, want: func(values map[string]interface{}) {
+			values["image"] = map[string]interface{}{"repository": "nginx"}
+		}},
+		{path: "image", found: true, want: func(values map[string]interface{}) {
+			delete(values, "image")
+		}},
+		{path: "ingress.hosts[0].tls", found: true, want: func(values map[string]interface{}) {
+			delete(values["ingress"].(map[string]interface{})["hosts"].([]interface{})[0].(map[string]interface{}), "tls")
+		}},
+		{path: "ingress.hosts[0]", found: true, want: func(values map[string]interface{}) {
+			values["ingress"] = map[string]interface{}{"hosts": []interface{}{map[string]interface{}{"host": "b.example.com"}}}
+		}},
+		{path: "env[1]", found: true, want: func(values map[string]interface{}) {
+			values["env"] = []interface{}{"A", "C"}
+		}},
+		{path: "image.digest"},
+		{path: "image.tag.suffix"},
+		{path: "env[3]"},
+		{path: "ingress.hosts.host"},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			values := newValues()
+			found, err := unsetValue(values, test.path)
+			if err != nil {
+				t.Fatalf("unsetValue: %v", err)
+			}
+			if found != test.found {
+				t.Errorf("got found %t, want %t", found, test.found)
+			}
+
+			want := newValues()
+			if test.want != nil {
+				test.want(want)
+			}
+			if !reflect.DeepEqual(values, want) {
+				t.Errorf("got values %v, want %v", values, want)
+			}
+		})
+	}
+
+	for _, path := range []string{"", "a..b", "a[x]", "a[-1]", "a[0"} {
+		if _, err := unsetValue(newValues(), path); err == nil {
+			t.Errorf("unsetValue %q: got no error for an invalid path", path)
+		}
+	}
+}